@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+var catalogFile string
+
+func init() {
+	catalogShowCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to show (required)")
+	catalogExportCmd.PersistentFlags().StringVarP(&catalogFile, "output", "o", "",
+		"file to export the catalog to, as JSON (required)")
+	catalogImportCmd.PersistentFlags().StringVarP(&catalogFile, "input", "f", "",
+		"file to import catalog entries from, as exported (required)")
+
+	if err := catalogShowCmd.MarkPersistentFlagRequired("file-id"); err != nil {
+		panic("Failed to mark 'file-id' flag as required")
+	} else if err := catalogExportCmd.MarkPersistentFlagRequired("output"); err != nil {
+		panic("Failed to mark 'output' flag as required")
+	} else if err := catalogImportCmd.MarkPersistentFlagRequired("input"); err != nil {
+		panic("Failed to mark 'input' flag as required")
+	}
+
+	catalogCmd.AddCommand(catalogListCmd, catalogShowCmd, catalogExportCmd, catalogImportCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Browse the local catalog of stored files",
+	Long: "Browse the local catalog, recording every file stored from this machine: its checksum, shape, cipher,\n" +
+		"placement, and last verification. Commands default to the catalog's checksum when none is given.",
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored files",
+	Run: func(cmd *cobra.Command, args []string) {
+		cat := mustOpenCatalog()
+		defer cat.Close()
+
+		entries, err := cat.List()
+		if err != nil {
+			zap.L().Fatal("Failed to list catalog", zap.Error(err))
+		}
+
+		for _, entry := range entries {
+			zap.L().Info("File", zap.String("FileID", entry.FileID), zap.String("Path", entry.Path),
+				zap.String("Name", entry.Name), zap.Int64("Size", entry.Size), zap.String("Checksum", entry.Checksum),
+				zap.Time("CreatedAt", entry.CreatedAt))
+		}
+		zap.L().Info("Catalog listed.", zap.Int("Files", len(entries)))
+	},
+}
+
+var catalogShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show a stored file's record",
+	Run: func(cmd *cobra.Command, args []string) {
+		cat := mustOpenCatalog()
+		defer cat.Close()
+
+		entry, err := cat.Get(fileID)
+		if err != nil {
+			zap.L().Fatal("Failed to find file in catalog", zap.Error(err))
+		}
+
+		fields := []zap.Field{
+			zap.String("FileID", entry.FileID),
+			zap.String("Path", entry.Path),
+			zap.String("Name", entry.Name),
+			zap.Int64("Size", entry.Size),
+			zap.String("Checksum", entry.Checksum),
+			zap.String("Scheme", entry.Scheme),
+			zap.String("Content", entry.Content),
+			zap.Uint8("ShareCount", entry.ShareCount),
+			zap.Uint8("Threshold", entry.MinSharesThreshold),
+			zap.String("Cipher", entry.Cipher),
+			zap.Time("CreatedAt", entry.CreatedAt),
+		}
+		if entry.VerifiedAt != nil && entry.Healthy != nil {
+			fields = append(fields, zap.Time("VerifiedAt", *entry.VerifiedAt), zap.Bool("Healthy", *entry.Healthy))
+		}
+		zap.L().Info("File", fields...)
+
+		for _, placement := range entry.Placements {
+			zap.L().Info("Share placement", zap.String("ShareID", placement.ShareID),
+				zap.String("StoreType", placement.StoreType), zap.String("StoreName", placement.StoreName))
+		}
+	},
+}
+
+var catalogExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the catalog as JSON",
+	Run: func(cmd *cobra.Command, args []string) {
+		cat := mustOpenCatalog()
+		defer cat.Close()
+
+		file, err := os.OpenFile(catalogFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			zap.L().Fatal("Failed to create export file", zap.String("Path", catalogFile), zap.Error(err))
+		}
+
+		if err := cat.Export(file); err != nil {
+			_ = file.Close()
+			zap.L().Fatal("Failed to export catalog", zap.Error(err))
+		} else if err := file.Close(); err != nil {
+			zap.L().Fatal("Failed to write export file", zap.String("Path", catalogFile), zap.Error(err))
+		}
+
+		zap.L().Info("Catalog exported successfully.", zap.String("Path", catalogFile))
+	},
+}
+
+var catalogImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import catalog entries from JSON",
+	Long:  "Import catalog entries exported by 'catalog export', replacing the entries of the same file ids",
+	Run: func(cmd *cobra.Command, args []string) {
+		cat := mustOpenCatalog()
+		defer cat.Close()
+
+		file, err := os.Open(catalogFile)
+		if err != nil {
+			zap.L().Fatal("Failed to open import file", zap.String("Path", catalogFile), zap.Error(err))
+		}
+		defer file.Close()
+
+		imported, err := cat.Import(file)
+		if err != nil {
+			zap.L().Fatal("Failed to import catalog", zap.Error(err))
+		}
+
+		zap.L().Info("Catalog imported successfully.", zap.Int("Files", imported))
+	},
+}
+
+// Resolves the catalog path: the flag if set, otherwise the default one.
+func catalogPathOrDefault() (string, error) {
+	if catalogPath != "" {
+		return catalogPath, nil
+	}
+	return catalog.DefaultPath()
+}
+
+func mustOpenCatalog() *catalog.Catalog {
+	path, err := catalogPathOrDefault()
+	if err != nil {
+		zap.L().Fatal("Failed to locate catalog", zap.Error(err))
+	}
+
+	cat, err := catalog.Open(path)
+	if err != nil {
+		zap.L().Fatal("Failed to open catalog", zap.Error(err))
+	}
+	return cat
+}
+
+// Runs fn against the catalog, unless it's turned off. Catalog failures are only logged, as the catalog is a
+// convenience: stores remain the source of truth.
+func withCatalog(fn func(cat *catalog.Catalog) error) {
+	if noCatalog {
+		return
+	}
+
+	path, err := catalogPathOrDefault()
+	if err != nil {
+		zap.L().Warn("Failed to locate catalog", zap.Error(err))
+		return
+	}
+
+	cat, err := catalog.Open(path)
+	if err != nil {
+		zap.L().Warn("Failed to open catalog", zap.Error(err))
+		return
+	}
+	defer cat.Close()
+
+	if err := fn(cat); err != nil {
+		zap.L().Warn("Failed to update catalog", zap.Error(err))
+	}
+}
+
+// Returns a file's checksum recorded in the catalog, or an empty string if it isn't known.
+func catalogChecksum(id string) string {
+	checksum := ""
+	withCatalog(func(cat *catalog.Catalog) error {
+		if entry, err := cat.Get(id); err == nil {
+			checksum = entry.Checksum
+		}
+		return nil
+	})
+	return checksum
+}
+
+// Defaults the checksum flag to the catalog's, for commands requiring one.
+func resolveChecksum() {
+	if checksum != "" {
+		return
+	} else if checksum = catalogChecksum(fileID); checksum == "" {
+		zap.L().Fatal("Checksum is required, as file isn't in catalog", zap.String("FileID", fileID))
+	}
+	zap.L().Debug("Using checksum from catalog", zap.String("FileID", fileID), zap.String("Checksum", checksum))
+}
+
+// Records a newly stored file in the catalog.
+func catalogStored(sharedFile *sharesPkg.SharedFile, placements []*pkg.Placement, path string, size int64,
+	settings *encryption.Settings) {
+	withCatalog(func(cat *catalog.Catalog) error {
+		return cat.Put(&catalog.Entry{
+			FileID:             sharedFile.ID,
+			Path:               path,
+			Name:               objectName,
+			Size:               size,
+			Checksum:           sharedFile.Checksum,
+			Scheme:             sharedFile.Manifest.SchemeOrDefault(),
+			Content:            sharedFile.Manifest.Content,
+			ShareCount:         sharedFile.Manifest.ShareCount,
+			MinSharesThreshold: sharedFile.Manifest.MinSharesThreshold,
+			Cipher:             cipherName(settings),
+			Placements:         catalogPlacements(placements),
+			CreatedAt:          time.Now().UTC(),
+		})
+	})
+}
+
+// Updates a file's catalog entry after its shares were replaced (e.g. refreshed, reshaped, or rekeyed). Placements
+// are forgotten until the next verification. Files which aren't in catalog are ignored.
+func catalogReplaced(oldFileID string, sharedFile *sharesPkg.SharedFile, settings *encryption.Settings) {
+	withCatalog(func(cat *catalog.Catalog) error {
+		err := cat.Update(oldFileID, func(entry *catalog.Entry) {
+			entry.FileID = sharedFile.ID
+			entry.ShareCount = sharedFile.Manifest.ShareCount
+			entry.MinSharesThreshold = sharedFile.Manifest.MinSharesThreshold
+			entry.Placements = nil
+			if settings != nil {
+				entry.Cipher = cipherName(settings)
+			}
+		})
+		if errors.Cause(err) == catalog.ErrEntryNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// Adds a file's repaired shares' placement to its catalog entry. Files which aren't in catalog are ignored.
+func catalogRepaired(id string, repaired []*pkg.Placement) {
+	withCatalog(func(cat *catalog.Catalog) error {
+		err := cat.Update(id, func(entry *catalog.Entry) {
+			entry.Placements = append(entry.Placements, catalogPlacements(repaired)...)
+		})
+		if errors.Cause(err) == catalog.ErrEntryNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// Records a file's verification in the catalog, along with its healthy shares' placement. Files which aren't in
+// catalog are ignored.
+func catalogVerified(cat *catalog.Catalog, health *pkg.FileHealth) error {
+	err := cat.Update(health.FileID, func(entry *catalog.Entry) {
+		verifiedAt, healthy := time.Now().UTC(), health.Recoverable() && health.Margin() > 0
+		entry.VerifiedAt, entry.Healthy = &verifiedAt, &healthy
+		entry.Placements = catalogPlacements(health.Healthy)
+	})
+	if errors.Cause(err) == catalog.ErrEntryNotFound {
+		return nil
+	}
+	return err
+}
+
+// Rebuilds a discovered file's catalog entry, keeping what discovery can't tell (e.g. the path it was stored from).
+// Chunks, histories, and files lacking a manifest aren't cataloged. Returns whether file was.
+func catalogDiscovered(cat *catalog.Catalog, file *pkg.DiscoveredFile, named *discoveredVersion,
+	settings *encryption.Settings) (bool, error) {
+	if file.Manifest == nil || file.Manifest.Content == sharesPkg.ContentChunk ||
+		file.Manifest.Content == sharesPkg.ContentHistory {
+		return false, nil
+	}
+
+	entry, err := cat.Get(file.FileID)
+	if errors.Cause(err) == catalog.ErrEntryNotFound {
+		entry = &catalog.Entry{FileID: file.FileID}
+	} else if err != nil {
+		return false, err
+	}
+
+	entry.Scheme = file.Manifest.SchemeOrDefault()
+	entry.Content = file.Manifest.Content
+	entry.ShareCount = file.Manifest.ShareCount
+	entry.MinSharesThreshold = file.Manifest.MinSharesThreshold
+	entry.Placements = catalogPlacements(file.Current)
+
+	if file.Checksum != "" {
+		entry.Checksum = file.Checksum
+		entry.Cipher = cipherName(settings)
+		if file.Size > 0 {
+			entry.Size = file.Size
+		}
+		if entry.Path == "" && file.Metadata != nil {
+			entry.Path = file.Metadata.Name
+		}
+	}
+
+	if named != nil {
+		entry.Name = named.Name
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = named.Version.Time
+		}
+	}
+	return true, cat.Put(entry)
+}
+
+func catalogPlacements(placements []*pkg.Placement) []*catalog.Placement {
+	catalogPlacements := make([]*catalog.Placement, 0, len(placements))
+	for _, placement := range placements {
+		catalogPlacements = append(catalogPlacements, &catalog.Placement{
+			ShareID:   placement.Share.ID,
+			StoreType: placement.Store.Type(),
+			StoreName: placement.Store.Name(),
+		})
+	}
+	return catalogPlacements
+}
+
+// Names the cipher files are encrypted with under the given settings.
+func cipherName(settings *encryption.Settings) string {
+	if !settings.TurnedOn {
+		return "none"
+	}
+	return fmt.Sprintf("aes-%d-gcm", len(settings.Salt)*8)
+}
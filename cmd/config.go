@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gasper/pkg/placement"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/middleware"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// Stores config top-level attributes.
+const (
+	storesAttr         = "stores"
+	policyAttr         = "policy"
+	placementRulesAttr = "placement-rules"
+)
+
+// Store config entry attributes shared by all store types, on top of their own.
+var storeCommonAttrs = []string{"type", "labels", middleware.MiddlewareAttr, middleware.RetryAttr,
+	middleware.CircuitBreakerAttr}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the stores config",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the stores config, reporting every problem found",
+	Long: "Validate the stores config against store types' schemas, middleware, labels, access policy and placement\n" +
+		"rules, reporting every problem found along with its path (e.g. 'stores[2].directory-path').\n" +
+		"Nothing is written, and stores aren't contacted (plugins aren't launched).",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readStoresConfig()
+
+		problems := validateConfig(config)
+		if len(problems) > 0 {
+			logConfigProblems(problems)
+			zap.L().Fatal("Stores config is invalid", zap.String("Path", storesFile), zap.Int("Problems",
+				len(problems)))
+		}
+
+		storesConfig, _ := config.Get(storesAttr).([]interface{})
+		zap.L().Info("Stores config is valid.", zap.String("Path", storesFile), zap.Int("Stores", len(storesConfig)))
+	},
+}
+
+// Problem found in the stores config, at an attribute path (e.g. 'stores[2].directory-path').
+type configProblem struct {
+	Path string
+	Err  error
+}
+
+// Checks the whole stores config, without creating any store. Returns every problem found.
+func validateConfig(config *viper.Viper) []*configProblem {
+	problems := make([]*configProblem, 0)
+
+	for _, attr := range sortedKeys(config.AllSettings()) {
+		if attr != storesAttr && attr != policyAttr && attr != placementRulesAttr {
+			problems = append(problems, &configProblem{Path: attr, Err: storesPkg.ErrUnknownAttr})
+		}
+	}
+
+	storesConfigRaw := config.Get(storesAttr)
+	storesConfig, ok := storesConfigRaw.([]interface{})
+	if storesConfigRaw == nil {
+		problems = append(problems, &configProblem{Path: storesAttr, Err: errors.New("missing stores list")})
+	} else if !ok {
+		problems = append(problems, &configProblem{Path: storesAttr, Err: errors.New("should be a list of stores")})
+	} else if len(storesConfig) == 0 {
+		problems = append(problems, &configProblem{Path: storesAttr, Err: errors.New("no stores")})
+	}
+
+	cacheDirectories := make(map[string]string)
+	for i, storeConfig := range storesConfig {
+		path := fmt.Sprintf("%s[%d]", storesAttr, i)
+		storeConfigMap, ok := storeConfig.(map[string]interface{})
+		if !ok {
+			problems = append(problems, &configProblem{Path: path, Err: errors.New("should be an object")})
+			continue
+		}
+		problems = append(problems, validateStoreConfig(path, storeConfigMap)...)
+
+		for _, directory := range middleware.CacheDirectories(storeConfigMap) {
+			if user, ok := cacheDirectories[directory.Path]; ok {
+				problems = append(problems, &configProblem{Path: path + "." + directory.Attr, Err: errors.WithMessagef(
+					middleware.ErrSharedCacheDirectory, "'%s' is used by '%s'", directory.Path, user)})
+			}
+			cacheDirectories[directory.Path] = path
+		}
+	}
+
+	if policyConfigRaw := config.Get(policyAttr); policyConfigRaw != nil {
+		if _, err := parsePolicy(policyConfigRaw); err != nil {
+			problems = append(problems, &configProblem{Path: policyAttr, Err: err})
+		}
+	}
+
+	if rulesConfigRaw := config.Get(placementRulesAttr); rulesConfigRaw != nil {
+		_, rulesProblems := parseRules(rulesConfigRaw)
+		problems = append(problems, rulesProblems...)
+	}
+	return problems
+}
+
+// Checks a store config entry: its type, its type's own attributes, labels and middleware.
+func validateStoreConfig(path string, storeConfig map[string]interface{}) []*configProblem {
+	problems := make([]*configProblem, 0)
+
+	var registration *storesPkg.Registration
+	if storeTypeRaw, ok := storeConfig["type"]; !ok {
+		problems = append(problems, &configProblem{Path: path + ".type", Err: storesPkg.ErrMissingStoreTypeAttr})
+	} else if storeType, _ := storeTypeRaw.(string); storesPkg.Registered(storeType) == nil {
+		problems = append(problems, &configProblem{Path: path + ".type", Err: errors.WithMessagef(
+			storesPkg.ErrInvalidStoreType, "'%v'", storeTypeRaw)})
+	} else {
+		registration = storesPkg.Registered(storeType)
+	}
+
+	known := make(map[string]bool)
+	for _, attr := range storeCommonAttrs {
+		known[attr] = true
+	}
+
+	if registration != nil {
+		attrs, err := storesPkg.Attrs(registration)
+		if err != nil {
+			problems = append(problems, &configProblem{Path: path + ".type", Err: err})
+		}
+
+		for _, attr := range attrs {
+			known[attr.Name] = true
+		}
+
+		if _, err := storesPkg.Decode(registration, storeConfig); err != nil {
+			configErrors, ok := err.(storesPkg.ConfigErrors)
+			if !ok {
+				problems = append(problems, &configProblem{Path: path, Err: err})
+			}
+
+			for _, configErr := range configErrors {
+				problems = append(problems, &configProblem{Path: path + "." + configErr.Attr,
+					Err: errors.New(configErr.Reason())})
+			}
+		}
+
+		for _, attr := range sortedKeys(storeConfig) {
+			if !known[attr] {
+				problems = append(problems, &configProblem{Path: path + "." + attr, Err: storesPkg.ErrUnknownAttr})
+			}
+		}
+	}
+
+	if _, err := storeLabels(storeConfig); err != nil {
+		problems = append(problems, &configProblem{Path: path + ".labels", Err: err})
+	}
+
+	for _, configErr := range middleware.Validate(storeConfig) {
+		problems = append(problems, &configProblem{Path: path + "." + configErr.Attr, Err: configErr.Err})
+	}
+	return problems
+}
+
+func logConfigProblems(problems []*configProblem) {
+	for _, problem := range problems {
+		zap.L().Error("Stores config problem", zap.String("Path", problem.Path), zap.Error(problem.Err))
+	}
+}
+
+// Parses the stores config's access policy, and validates it.
+func parsePolicy(policyConfigRaw interface{}) (*sharesPkg.Policy, error) {
+	policyBytes, err := json.Marshal(policyConfigRaw)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid policy configuration scheme")
+	}
+
+	policy := &sharesPkg.Policy{}
+	if err := json.Unmarshal(policyBytes, policy); err != nil {
+		return nil, errors.WithMessage(err, "invalid policy configuration scheme")
+	} else if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Parses the stores config's placement rules, and validates them. Returns every problem found.
+func parseRules(rulesConfigRaw interface{}) ([]*placement.Rule, []*configProblem) {
+	rulesConfig, ok := rulesConfigRaw.([]interface{})
+	if !ok {
+		return nil, []*configProblem{{Path: placementRulesAttr, Err: errors.New("should be a list of rules")}}
+	}
+
+	rules := make([]*placement.Rule, 0, len(rulesConfig))
+	problems := make([]*configProblem, 0)
+	for i, ruleConfig := range rulesConfig {
+		path := fmt.Sprintf("%s[%d]", placementRulesAttr, i)
+		ruleBytes, err := json.Marshal(ruleConfig)
+		if err != nil {
+			problems = append(problems, &configProblem{Path: path, Err: err})
+			continue
+		}
+
+		rule := &placement.Rule{}
+		if err := json.Unmarshal(ruleBytes, rule); err != nil {
+			problems = append(problems, &configProblem{Path: path, Err: errors.WithMessage(err,
+				"invalid placement rule configuration scheme")})
+		} else if err := rule.Validate(); err != nil {
+			problems = append(problems, &configProblem{Path: path, Err: err})
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, problems
+}
+
+func sortedKeys(config map[string]interface{}) []string {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/gasper/internal/encryption"
 	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
 	storesPkg "github.com/gasper/pkg/storage/stores"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -32,6 +33,7 @@ var deleteCmd = &cobra.Command{
 		}
 
 		deletedShares := 0
+		unconfirmedStores := 0 // Stores which may still hold a share: unavailable ones, or ones which failed to delete.
 
 		zap.L().Info("Delete shares from stores")
 		for _, store := range gasper.Stores() {
@@ -39,6 +41,7 @@ var deleteCmd = &cobra.Command{
 			storeType := store.Type()
 
 			if skip := checkStoreAvailability(store); skip {
+				unconfirmedStores++
 				continue
 			}
 
@@ -52,12 +55,25 @@ var deleteCmd = &cobra.Command{
 
 				zap.L().Error("Failed to delete share from store", zap.String("StoreType", storeType),
 					zap.Error(err))
+				unconfirmedStores++
 				continue // Best effort - keep trying other stores...
 			}
 
 			deletedShares++
 		}
 
+		// The catalog entry (checksum and shape) is kept as long as shares may remain, so that they can still be told
+		// apart and retrieved.
+		if deletedShares > 0 && unconfirmedStores == 0 {
+			withCatalog(func(cat *catalog.Catalog) error {
+				return cat.Delete(fileID)
+			})
+		} else if !noCatalog {
+			zap.L().Warn("Not every share could be confirmed deleted, keeping the file in the catalog",
+				zap.String("FileID", fileID), zap.Int("DeletedShares", deletedShares),
+				zap.Int("UnconfirmedStores", unconfirmedStores))
+		}
+
 		if deletedShares == 0 {
 			zap.L().Warn("No shares were found/deleted")
 			return
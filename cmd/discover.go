@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var discoverChecksums bool
+
+func init() {
+	discoverCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether files were encrypted before storing them (default: false)")
+	discoverCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	discoverCmd.PersistentFlags().BoolVarP(&discoverChecksums, "checksums", "", false,
+		"recover every file in memory to learn its checksum, size, and name (default: false)")
+	discoverCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false,
+		"only report what was found, without updating the catalog (default: false)")
+
+	rootCmd.AddCommand(discoverCmd)
+}
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Find the files held by stores and rebuild the catalog",
+	Long: "Scan every store for shares, group them by file id, and report how many are reachable versus required " +
+		"to recover each file.\nFiles which can't be recovered anymore, stale shares, and stray entries which " +
+		"aren't shares are reported.\nCatalog entries are rebuilt from what was found. Shares don't hold files' " +
+		"checksum, so recover files with --checksums (and their decryption settings) to learn it.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		settings := &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		}
+		gasper, err := pkg.NewGasper(extractStores(), settings)
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		stores := availableStores(gasper.Stores())
+		if len(stores) < len(gasper.Stores()) {
+			zap.L().Warn("Not every store is available, reachable share counts may be short",
+				zap.Int("Available", len(stores)), zap.Int("Stores", len(gasper.Stores())))
+		}
+
+		zap.L().Info("Discover files in stores")
+		report, storeErrors := gasper.Discover(stores, discoverChecksums)
+		logStoreErrors("Failed to list store", storeErrors)
+
+		// Named objects' histories tell which of the files are their versions.
+		versions := make(map[string]*discoveredVersion)
+		for _, file := range report.Files {
+			if file.History == nil {
+				continue
+			}
+
+			for _, version := range file.History.Versions {
+				versions[version.FileID] = &discoveredVersion{Name: file.History.Name, Version: version}
+			}
+		}
+
+		orphans, chunks := 0, 0
+		for _, file := range report.Files {
+			if !logDiscoveredFile(file) {
+				orphans++
+			}
+
+			if file.Manifest != nil && file.Manifest.Content == sharesPkg.ContentChunk {
+				chunks++
+			}
+		}
+
+		for _, stray := range report.Strays {
+			zap.L().Warn("Stray entry, not a readable share", zap.String("StoreType", stray.Store.Type()),
+				zap.String("StoreName", stray.Store.Name()), zap.String("Name", stray.Name), zap.Error(stray.Err))
+		}
+
+		cataloged := 0
+		if !dryRun {
+			withCatalog(func(cat *catalog.Catalog) error {
+				for _, file := range report.Files {
+					if ok, err := catalogDiscovered(cat, file, versions[file.FileID], settings); err != nil {
+						return err
+					} else if ok {
+						cataloged++
+					}
+				}
+				return nil
+			})
+		}
+
+		zap.L().Info("Discovery finished.", zap.Bool("DryRun", dryRun), zap.Int("Files", len(report.Files)-chunks),
+			zap.Int("Chunks", chunks), zap.Int("Orphans", orphans), zap.Int("Strays", len(report.Strays)),
+			zap.Int("Cataloged", cataloged))
+	},
+}
+
+// A version of a named object, found in its discovered history.
+type discoveredVersion struct {
+	Name    string
+	Version *pkg.Version
+}
+
+// Logs what was discovered about a file. Returns whether it can be recovered.
+func logDiscoveredFile(file *pkg.DiscoveredFile) bool {
+	for _, placement := range file.Stale {
+		zap.L().Warn("Share of a stale generation", zap.String("FileID", file.FileID),
+			zap.String("StoreType", placement.Store.Type()), zap.String("StoreName", placement.Store.Name()),
+			zap.String("ShareID", placement.Share.ID), zap.Uint64("Generation", placement.Share.Generation()))
+	}
+
+	if file.Manifest == nil {
+		zap.L().Warn("Shares hold no manifest, cannot tell how many are required", zap.String("FileID", file.FileID),
+			zap.Int("Reachable", file.Reachable))
+		return false
+	}
+
+	fields := []zap.Field{
+		zap.String("FileID", file.FileID),
+		zap.String("Content", file.Manifest.Content),
+		zap.Int("Reachable", file.Reachable),
+		zap.Int("Required", file.Required),
+		zap.Uint8("ShareCount", file.Manifest.ShareCount),
+	}
+	if !file.Recoverable() {
+		zap.L().Warn("Orphan shares, not enough are reachable to recover file", fields...)
+		return false
+	}
+
+	if file.RecoverErr != nil {
+		zap.L().Warn("Failed to recover file", append(fields, zap.Error(file.RecoverErr))...)
+	} else if file.Checksum != "" {
+		fields = append(fields, zap.String("Checksum", file.Checksum))
+	}
+
+	if file.Manifest.Content == sharesPkg.ContentChunk {
+		zap.L().Debug("Chunk", fields...)
+	} else {
+		zap.L().Info("File", fields...)
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var dryRun bool
+
+func init() {
+	gcCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether files were encrypted before storing them (default: false)")
+	gcCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	gcCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false,
+		"only list unreferenced chunks, without deleting them (default: false)")
+
+	rootCmd.AddCommand(gcCmd)
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete unreferenced chunks",
+	Long: "Delete the chunks which no stored chunk index references anymore (e.g. after deleting chunked files).\n" +
+		"Every store must be available, and every chunk index readable with the given decryption settings, " +
+		"otherwise nothing is deleted.\nDon't run it while chunked files are being stored.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		// An index held only by an unavailable store would go unnoticed, and its chunks deleted.
+		stores := gasper.Stores()
+		if len(availableStores(stores)) < len(stores) {
+			zap.L().Fatal("Every store must be available to tell which chunks are unreferenced")
+		}
+
+		zap.L().Info("Collect unreferenced chunks")
+		report, storeErrors, err := gasper.CollectGarbage(stores, dryRun)
+		logStoreErrors("Failed to list, read, or delete in store", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to collect garbage", zap.Error(err))
+		}
+
+		for _, id := range report.Unreferenced {
+			zap.L().Debug("Unreferenced chunk", zap.String("ChunkID", id))
+		}
+
+		zap.L().Info("Garbage collected.", zap.Bool("DryRun", dryRun), zap.Int("Indexes", report.Indexes),
+			zap.Int("ReferencedChunks", report.ReferencedChunks), zap.Int("UnreferencedChunks",
+				len(report.Unreferenced)), zap.Int("DeletedShares", report.DeletedShares))
+	},
+}
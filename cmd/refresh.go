@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	refreshCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to refresh (required)")
+	refreshCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
+		"checksum of the shared file (default: the catalog's, required otherwise)")
+	refreshCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether file was encrypted before storing it (default: false)")
+	refreshCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	refreshCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
+		"share count, for files stored without a manifest (default: 2)")
+	refreshCmd.PersistentFlags().Int8VarP(&minSharesThreshold, "shares-threshold", "t", 2,
+		"threshold of minimum shares, for files stored without a manifest (default: 2)")
+
+	if err := refreshCmd.MarkPersistentFlagRequired("file-id"); err != nil {
+		panic("Failed to mark 'file-id' flag as required")
+	}
+
+	rootCmd.AddCommand(refreshCmd)
+}
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh a file's shares",
+	Long: "Replace every share of a file with brand-new ones, without changing the file itself.\n" +
+		"Previously leaked shares become useless, as they cannot be combined with the new ones.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		resolveChecksum()
+
+		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		stores := availableStores(gasper.Stores())
+
+		zap.L().Info("Collect shares from stores")
+		placements, current := collectShares(gasper, fileID, stores)
+
+		if len(current) == 0 {
+			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+			return
+		}
+
+		count, threshold := sharesParameters(cmd, current)
+		if manifest := pkg.PlacementsManifest(current); manifest != nil {
+			count, threshold = manifest.ShareCount, manifest.MinSharesThreshold
+		} else if threshold > count {
+			zap.L().Fatal("Minimum shares threshold cannot be larger than share count")
+		}
+
+		zap.L().Info("Refresh shares")
+		sharedFile, storeErrors, err := gasper.Refresh(fileID, checksum, placements, stores, count, threshold)
+		logStoreErrors("Store operation failed during refresh", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to refresh file", zap.String("FileID", fileID), zap.Error(err))
+		}
+
+		catalogReplaced(fileID, sharedFile, nil)
+
+		zap.L().Info("File shares refreshed successfully.", zap.String("FileID", sharedFile.ID),
+			zap.Uint64("Generation", sharedFile.Manifest.Generation))
+	},
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	newEncryptionSalt string
+	newFileID         bool
+)
+
+func init() {
+	rekeyCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to rekey (required)")
+	rekeyCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
+		"checksum of the shared file (default: the catalog's, required otherwise)")
+	rekeyCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether file was encrypted before storing it (default: false)")
+	rekeyCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"current decryption salt (required if decryption mode is turned on)")
+	rekeyCmd.PersistentFlags().StringVarP(&newEncryptionSalt, "new-salt", "n", "",
+		"new 32-byte long encryption salt (required)")
+	rekeyCmd.PersistentFlags().BoolVarP(&newFileID, "new-file-id", "r", false,
+		"whether to produce a new file id instead of keeping the current one (default: false)")
+	rekeyCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
+		"share count (default: current share count, or 2)")
+	rekeyCmd.PersistentFlags().Int8VarP(&minSharesThreshold, "shares-threshold", "t", 2,
+		"threshold of minimum shares which can be used for retrieval (default: current threshold, or 2)")
+
+	if err := rekeyCmd.MarkPersistentFlagRequired("file-id"); err != nil {
+		panic("Failed to mark 'file-id' flag as required")
+	} else if err := rekeyCmd.MarkPersistentFlagRequired("new-salt"); err != nil {
+		panic("Failed to mark 'new-salt' flag as required")
+	}
+
+	rootCmd.AddCommand(rekeyCmd)
+}
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Rekey a file",
+	Long: "Re-encrypt a file with a new key and re-split it, without writing its plaintext to disk.\n" +
+		"Old shares are deleted only after the new ones were verified readable.\n" +
+		"Version histories holding the file are rewritten to follow its new file id and salt.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		resolveChecksum()
+
+		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		stores := availableStores(gasper.Stores())
+
+		zap.L().Info("Collect shares from stores")
+		placements, current := collectShares(gasper, fileID, stores)
+
+		if len(current) == 0 {
+			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+			return
+		}
+
+		count, threshold := sharesParameters(cmd, current)
+		if threshold > count {
+			zap.L().Fatal("Minimum shares threshold cannot be larger than share count")
+		}
+
+		zap.L().Info("Rekey shares")
+		sharedFile, storeErrors, err := gasper.Rekey(fileID, checksum, placements, stores, &pkg.RekeyOptions{
+			Encryption: &encryption.Settings{
+				TurnedOn: true,
+				Salt:     newEncryptionSalt,
+			},
+			KeepFileID:         !newFileID,
+			ShareCount:         count,
+			MinSharesThreshold: threshold,
+		})
+		logStoreErrors("Store operation failed during rekey", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to rekey file", zap.String("FileID", fileID), zap.Error(err))
+		}
+
+		catalogReplaced(fileID, sharedFile, &encryption.Settings{TurnedOn: true, Salt: newEncryptionSalt})
+
+		zap.L().Info("Success! Keep the following info for later use", zap.String("FileID", sharedFile.ID),
+			zap.String("Checksum", sharedFile.Checksum))
+	},
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	repairCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to repair (required)")
+	repairCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
+		"checksum of the shared file (default: the catalog's, required otherwise)")
+	repairCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether file was encrypted before storing it (default: false)")
+	repairCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	repairCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
+		"share count, for files stored without a manifest (default: 2)")
+
+	if err := repairCmd.MarkPersistentFlagRequired("file-id"); err != nil {
+		panic("Failed to mark 'file-id' flag as required")
+	}
+
+	rootCmd.AddCommand(repairCmd)
+}
+
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Repair a file",
+	Long: "Regenerate a file's missing or corrupt shares from the surviving ones,\n" +
+		"and place them on healthy stores which don't already hold a share of the file.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		resolveChecksum()
+
+		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		stores := availableStores(gasper.Stores())
+
+		zap.L().Info("Collect shares from stores")
+		placements, current := collectShares(gasper, fileID, stores)
+
+		if len(current) == 0 {
+			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+			return
+		}
+
+		zap.L().Info("Repair shares")
+		report, storeErrors, err := gasper.Repair(fileID, checksum, placements, stores, byte(shareCount))
+		logStoreErrors("Store operation failed during repair", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to repair file", zap.String("FileID", fileID), zap.Error(err))
+		}
+
+		if len(report.MissingShareIDs) == 0 {
+			zap.L().Info("No missing shares, nothing to repair.", zap.String("FileID", fileID))
+			return
+		}
+
+		zap.L().Info("Found missing shares", zap.Strings("ShareIDs", report.MissingShareIDs))
+		for _, placement := range report.Repaired {
+			zap.L().Info("Repaired share", zap.String("ShareID", placement.Share.ID),
+				zap.String("StoreType", placement.Store.Type()))
+		}
+
+		catalogRepaired(fileID, report.Repaired)
+
+		if len(report.Unplaced) > 0 {
+			zap.L().Fatal("Not enough healthy stores to place all repaired shares",
+				zap.Strings("UnplacedShareIDs", report.Unplaced))
+		}
+
+		zap.L().Info("File repaired successfully.", zap.String("FileID", fileID))
+	},
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func init() {
+	reshapeCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to reshape (required)")
+	reshapeCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
+		"checksum of the shared file (default: the catalog's, required otherwise)")
+	reshapeCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether file was encrypted before storing it (default: false)")
+	reshapeCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	reshapeCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
+		"new share count (required)")
+	reshapeCmd.PersistentFlags().Int8VarP(&minSharesThreshold, "shares-threshold", "t", 2,
+		"new threshold of minimum shares which can be used for retrieval (required, alias: --threshold)")
+	reshapeCmd.SetGlobalNormalizationFunc(func(flags *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "threshold" {
+			name = "shares-threshold"
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	if err := reshapeCmd.MarkPersistentFlagRequired("file-id"); err != nil {
+		panic("Failed to mark 'file-id' flag as required")
+	} else if err := reshapeCmd.MarkPersistentFlagRequired("share-count"); err != nil {
+		panic("Failed to mark 'share-count' flag as required")
+	} else if err := reshapeCmd.MarkPersistentFlagRequired("shares-threshold"); err != nil {
+		panic("Failed to mark 'shares-threshold' flag as required")
+	}
+
+	rootCmd.AddCommand(reshapeCmd)
+}
+
+var reshapeCmd = &cobra.Command{
+	Use:   "reshape",
+	Short: "Change a file's share count and threshold",
+	Long: "Re-split a file using a new share count and threshold, without re-uploading it.\n" +
+		"New shares are distributed across all available stores (add stores to the stores config to grow the store\n" +
+		"set), and old shares are cleaned up only after the new ones were verified readable.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if minSharesThreshold > shareCount {
+			zap.L().Fatal("Minimum shares threshold cannot be larger than share count")
+		} else if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		resolveChecksum()
+
+		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		stores := availableStores(gasper.Stores())
+		if len(stores) < int(shareCount) {
+			zap.L().Fatal("Not enough available stores", zap.Int8("Need", shareCount),
+				zap.Int("Got", len(stores)))
+		}
+
+		zap.L().Info("Collect shares from stores")
+		placements, current := collectShares(gasper, fileID, stores)
+
+		if len(current) == 0 {
+			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+			return
+		}
+
+		zap.L().Info("Reshape shares")
+		sharedFile, storeErrors, err := gasper.Reshape(fileID, checksum, placements, stores, byte(shareCount),
+			byte(minSharesThreshold))
+		logStoreErrors("Store operation failed during reshape", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to reshape file", zap.String("FileID", fileID), zap.Error(err))
+		}
+
+		catalogReplaced(fileID, sharedFile, nil)
+
+		zap.L().Info("File reshaped successfully.", zap.String("FileID", sharedFile.ID),
+			zap.Uint8("ShareCount", sharedFile.Manifest.ShareCount),
+			zap.Uint8("Threshold", sharedFile.Manifest.MinSharesThreshold),
+			zap.Uint64("Generation", sharedFile.Manifest.Generation))
+	},
+}
@@ -3,8 +3,8 @@ package cmd
 import (
 	"github.com/gasper/internal/encryption"
 	"github.com/gasper/pkg"
+	metadataPkg "github.com/gasper/pkg/metadata"
 	sharesPkg "github.com/gasper/pkg/shares"
-	storesPkg "github.com/gasper/pkg/storage/stores"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -15,26 +15,39 @@ var (
 	checksum           string
 	decryptionTurnedOn bool
 	decryptionSalt     string
+	subPath            string
+	restoreOwner       bool
+	restoreXattrs      bool
 )
 
 func init() {
 	retrieveCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
-		"file id to retrieve (required)")
+		"file id to retrieve (required, unless a name is given)")
 	retrieveCmd.PersistentFlags().StringVarP(&destination, "destination", "d", "",
-		"where to save the retrieved file (required)")
+		"where to save the retrieved file (or into which directory, under its original name), or directory tree "+
+			"(required)")
 	retrieveCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
-		"checksum of the shared file (required)")
+		"checksum of the shared file (default: the catalog's, required along with a file id otherwise)")
 	retrieveCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
 		"whether file was encrypted before storing it (default: false)")
 	retrieveCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
 		"decryption salt (required if decryption mode is turned on)")
-
-	if err := retrieveCmd.MarkPersistentFlagRequired("file-id"); err != nil {
-		panic("Failed to mark 'file-id' flag as required")
-	} else if err := retrieveCmd.MarkPersistentFlagRequired("destination"); err != nil {
+	retrieveCmd.PersistentFlags().StringVarP(&subPath, "sub-path", "p", "",
+		"restore only this relative path out of a stored directory tree (default: the whole tree)")
+	retrieveCmd.PersistentFlags().BoolVarP(&restoreOwner, "restore-owner", "", false,
+		"whether to restore file's ownership, if it was captured (default: false)")
+	retrieveCmd.PersistentFlags().BoolVarP(&restoreXattrs, "restore-xattrs", "", false,
+		"whether to restore file's extended attributes, if they were captured (default: false)")
+
+	retrieveCmd.PersistentFlags().StringVarP(&objectName, "name", "n", "",
+		"logical name to retrieve a version of, instead of a file id")
+	retrieveCmd.PersistentFlags().IntVarP(&versionNumber, "version", "", 0,
+		"version number to retrieve, along with a name (default: the latest)")
+	retrieveCmd.PersistentFlags().StringVarP(&versionTime, "at", "", "",
+		"retrieve the latest version stored at or before this date (YYYY-MM-DD) or RFC 3339 time, along with a name")
+
+	if err := retrieveCmd.MarkPersistentFlagRequired("destination"); err != nil {
 		panic("Failed to mark 'destination' flag as required")
-	} else if err := retrieveCmd.MarkPersistentFlagRequired("checksum"); err != nil {
-		panic("Failed to mark 'checksum' flag as required")
 	}
 
 	rootCmd.AddCommand(retrieveCmd)
@@ -47,6 +60,10 @@ var retrieveCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if decryptionTurnedOn && decryptionSalt == "" {
 			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		} else if (fileID == "") == (objectName == "") {
+			zap.L().Fatal("Either a file id or a name is required")
+		} else if objectName == "" && (versionNumber != 0 || versionTime != "") {
+			zap.L().Fatal("Version and date are only supported along with a name")
 		}
 
 		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
@@ -57,54 +74,79 @@ var retrieveCmd = &cobra.Command{
 			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
 		}
 
-		sharedFile := &sharesPkg.SharedFile{
-			ID:       fileID,
-			Checksum: checksum,
-			Shares:   make([]*sharesPkg.Share, 0),
+		if objectName != "" {
+			version := findVersion(gasper)
+			fileID, checksum = version.FileID, version.Checksum
+		} else {
+			resolveChecksum()
 		}
 
 		zap.L().Info("Collect shares from stores")
-		for _, store := range gasper.Stores() {
-			store := store
-			storeType := store.Type()
+		_, placements := collectShares(gasper, fileID, availableStores(gasper.Stores()))
 
-			if skip := checkStoreAvailability(store); skip {
-				continue
-			}
+		if len(placements) == 0 {
+			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+			return
+		} else if manifest := pkg.PlacementsManifest(placements); manifest != nil &&
+			len(placements) < int(manifest.MinSharesThreshold) {
+			zap.L().Warn("Didn't find enough shares", zap.Uint8("Need", manifest.MinSharesThreshold),
+				zap.Int("Got", len(placements)))
+			return
+		} else if missing := pkg.PolicyMissing(placements); missing != "" {
+			zap.L().Warn("Found shares don't satisfy the access policy", zap.String("Missing", missing))
+			return
+		}
+
+		sharedFile := pkg.SharedFileFromPlacements(fileID, checksum, placements)
+
+		zap.L().Debug("Combine shares")
+		recovery, err := gasper.Recover(sharedFile)
+		logBadShares(placements, recovery)
+		if err != nil {
+			zap.L().Error("Failed to combine shares", zap.String("FileID", fileID), zap.Error(err))
+			return
+		}
 
-			zap.L().Debug("Available! Search share in store", zap.String("StoreType", storeType))
-			share, err := store.Get(fileID)
+		data, isDirectory := recovery.Data, false
+		manifest := pkg.PlacementsManifest(placements)
+		if manifest != nil && manifest.IsChunkIndex() {
+			zap.L().Debug("Reassemble chunks")
+			reassembled, index, err := gasper.Reassemble(recovery.Data, availableStores(gasper.Stores()))
 			if err != nil {
-				if err == storesPkg.ErrShareNotExists {
-					zap.L().Debug("No match found in store, trying the next one", zap.String("StoreType",
-						storeType))
-					continue
-				}
-
-				zap.L().Error("Failed to search share in store", zap.String("StoreType", storeType),
-					zap.Error(err))
-				continue
+				zap.L().Error("Failed to reassemble chunks", zap.String("FileID", fileID), zap.Error(err))
+				return
 			}
-
-			sharedFile.Shares = append(sharedFile.Shares, share)
+			data, isDirectory = reassembled, index.Content == sharesPkg.ContentDirectory
+		} else if manifest != nil {
+			isDirectory = manifest.IsDirectory()
 		}
 
-		if len(sharedFile.Shares) == 0 {
-			zap.L().Warn("No shares found for requested file ID", zap.String("FileID", fileID))
+		if isDirectory {
+			zap.L().Debug("Dump shared directory tree")
+			if err := gasper.DumpDirectory(data, destination, subPath); err != nil {
+				zap.L().Error("Failed dump shared directory tree", zap.String("FileID", fileID),
+					zap.String("Destination", destination), zap.Error(err))
+				return
+			}
+
+			zap.L().Info("Directory tree retrieved successfully.", zap.String("FileID", fileID))
 			return
-		} else if len(sharedFile.Shares) < int(minSharesThreshold) {
-			zap.L().Warn("Didn't find enough shares", zap.Int8("Need", minSharesThreshold),
-				zap.Int("Got", len(sharedFile.Shares)))
+		} else if subPath != "" {
+			zap.L().Error("Sub-path is only supported for directory trees", zap.String("FileID", fileID))
 			return
 		}
 
 		zap.L().Debug("Dump shared file")
-		if err := gasper.DumpSharedFile(sharedFile, destination); err != nil {
+		dumpedPath, err := gasper.DumpData(data, recovery.Metadata, destination, &metadataPkg.Options{
+			Ownership: restoreOwner,
+			Xattrs:    restoreXattrs,
+		})
+		if err != nil {
 			zap.L().Error("Failed dump shared file", zap.String("FileID", fileID),
 				zap.String("Destination", destination), zap.Error(err))
 			return
 		}
 
-		zap.L().Info("File retrieved successfully.", zap.String("FileID", fileID))
+		zap.L().Info("File retrieved successfully.", zap.String("FileID", fileID), zap.String("Path", dumpedPath))
 	},
 }
@@ -3,16 +3,35 @@ package cmd
 import (
 	"fmt"
 	"github.com/gasper/internal/logging"
+	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
+	"github.com/gasper/pkg/placement"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/middleware"
 	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
 	"os"
 )
 
 var (
-	storesFile string
-	verbose    bool
+	storesFile  string
+	verbose     bool
+	catalogPath string
+	noCatalog   bool
+)
+
+var (
+	// Targets extracted from stores config, so that stores (and plugins) are only created once.
+	extractedTargets []*placement.Target
+
+	// Stores to close once the command is done - whether it succeeds or fails (e.g. plugin stores, so that their
+	// plugins exit).
+	closableStores []storesPkg.Store
 )
 
 var rootCmd = &cobra.Command{
@@ -26,57 +45,201 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
-		zap.ReplaceGlobals(logger)
+		// Note: fatal logs exit right away, so stores are closed beforehand.
+		zap.ReplaceGlobals(logger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if entry.Level == zapcore.FatalLevel {
+				closeStores()
+			}
+			return nil
+		})))
 		return nil
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		closeStores()
 		_ = zap.L().Sync()
 		_ = zap.S().Sync()
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&storesFile, "stores-config", "c", "", "stores config file (required)")
+	rootCmd.PersistentFlags().StringVarP(&storesFile, "stores-config", "c", "",
+		"stores config file (required by commands using stores)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "extra verbosity")
+	rootCmd.PersistentFlags().StringVarP(&catalogPath, "catalog", "", "",
+		"local catalog of stored files (default: 'gasper/catalog.db' under the user config directory)")
+	rootCmd.PersistentFlags().BoolVarP(&noCatalog, "no-catalog", "", false,
+		"neither read nor record anything in the local catalog (default: false)")
+}
 
-	if err := rootCmd.MarkPersistentFlagRequired("stores-config"); err != nil {
-		panic("Failed to mark 'stores-config' flag as required")
+// Note: 'stores-config' flag is checked here rather than marked as required, as some commands (e.g. share verify)
+// don't need any store.
+func readStoresConfig() *viper.Viper {
+	if storesFile == "" {
+		zap.L().Fatal("Stores config file is required")
 	}
-}
 
-func extractStores() []storesPkg.Store {
 	config := viper.New()
 	config.SetConfigFile(storesFile)
 	if err := config.ReadInConfig(); err != nil {
-		zap.L().Fatal("Failed to read stores config file", zap.String("Path", filePath), zap.Error(err))
+		zap.L().Fatal("Failed to read stores config file", zap.String("Path", storesFile), zap.Error(err))
 	}
+	return config
+}
 
-	storesConfigRaw := config.Get("stores")
-	if storesConfigRaw == nil {
-		zap.L().Fatal("Empty stores config")
-	}
+func extractStores() []storesPkg.Store {
+	return placement.Stores(extractTargets())
+}
 
-	storesConfig, ok := storesConfigRaw.([]interface{})
-	if !ok {
-		zap.L().Fatal("Invalid configuration scheme")
+// Extracts stores from stores config, along with their labels.
+// The whole config is validated first, so that every problem is reported at once. Unknown attributes (e.g. ones
+// from a newer version, or typos) are only warned about here - 'config validate' refuses them. Stores are created only
+// once.
+func extractTargets() []*placement.Target {
+	if extractedTargets != nil {
+		return extractedTargets
 	}
 
-	stores := make([]storesPkg.Store, 0)
-	for _, storeConfig := range storesConfig {
-		storeConfigMap, ok := storeConfig.(map[string]interface{})
-		if !ok {
-			zap.L().Fatal("Invalid configuration scheme")
+	config := readStoresConfig()
+	problems := make([]*configProblem, 0)
+	for _, problem := range validateConfig(config) {
+		if problem.Err == storesPkg.ErrUnknownAttr {
+			zap.L().Warn("Stores config problem", zap.String("Path", problem.Path), zap.Error(problem.Err))
+		} else {
+			problems = append(problems, problem)
 		}
+	}
+
+	if len(problems) > 0 {
+		logConfigProblems(problems)
+		zap.L().Fatal("Invalid stores config", zap.String("Path", storesFile), zap.Int("Problems", len(problems)))
+	}
 
+	storesConfig := config.Get(storesAttr).([]interface{})
+	targets := make([]*placement.Target, 0, len(storesConfig))
+	for _, storeConfig := range storesConfig {
+		storeConfigMap := storeConfig.(map[string]interface{})
 		store, err := storesPkg.FromConfig(storeConfigMap)
 		if err != nil {
 			zap.L().Fatal("Failed to create store from config", zap.Any("RawConfig", storeConfig),
 				zap.Error(err))
 		}
 
-		stores = append(stores, store)
+		if _, ok := store.(io.Closer); ok {
+			closableStores = append(closableStores, store)
+		}
+
+		if store, err = middleware.FromConfig(store, storeConfigMap); err != nil {
+			zap.L().Fatal("Failed to configure store middleware", zap.Any("RawConfig", storeConfig),
+				zap.Error(err))
+		}
+
+		labels, err := storeLabels(storeConfigMap)
+		if err != nil {
+			zap.L().Fatal("Invalid store labels", zap.Any("RawConfig", storeConfig), zap.Error(err))
+		}
+
+		targets = append(targets, &placement.Target{Store: store, Labels: labels})
+	}
+
+	extractedTargets = targets
+	return targets
+}
+
+// Closes the stores which need it, once.
+func closeStores() {
+	stores := closableStores
+	closableStores = nil
+
+	for _, store := range stores {
+		if err := store.(io.Closer).Close(); err != nil {
+			zap.L().Warn("Failed to close store", zap.String("StoreType", store.Type()),
+				zap.String("StoreName", store.Name()), zap.Error(err))
+		}
 	}
-	return stores
+}
+
+// Extracts a store's labels (its 'labels' attribute, mapping label names to string values), if any.
+func storeLabels(storeConfig map[string]interface{}) (map[string]string, error) {
+	labelsRaw, ok := storeConfig["labels"]
+	if !ok {
+		return nil, nil
+	}
+
+	labelsMap, ok := labelsRaw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("'labels' attribute should map label names to values")
+	}
+
+	labels := make(map[string]string, len(labelsMap))
+	for label, valueRaw := range labelsMap {
+		value, ok := valueRaw.(string)
+		if !ok {
+			return nil, errors.Errorf("label '%s' should have a string value", label)
+		}
+		labels[label] = value
+	}
+	return labels, nil
+}
+
+// Extracts the access policy from stores config, or returns nil if there's none.
+func extractPolicy() *sharesPkg.Policy {
+	config := readStoresConfig()
+
+	policyConfigRaw := config.Get(policyAttr)
+	if policyConfigRaw == nil {
+		return nil
+	}
+
+	policy, err := parsePolicy(policyConfigRaw)
+	if err != nil {
+		zap.L().Fatal("Invalid policy", zap.Error(err))
+	}
+	return policy
+}
+
+// Extracts placement rules from stores config, or returns nil if there are none.
+func extractRules() []*placement.Rule {
+	config := readStoresConfig()
+
+	rulesConfigRaw := config.Get(placementRulesAttr)
+	if rulesConfigRaw == nil {
+		return nil
+	}
+
+	rules, problems := parseRules(rulesConfigRaw)
+	if len(problems) > 0 {
+		logConfigProblems(problems)
+		zap.L().Fatal("Invalid placement rules")
+	}
+	return rules
+}
+
+// Returns the targets of the given stores (stores without one get a label-less target).
+func storesTargets(stores []storesPkg.Store, targets []*placement.Target) []*placement.Target {
+	byStore := make(map[storesPkg.Store]*placement.Target, len(targets))
+	for _, target := range targets {
+		byStore[target.Store] = target
+	}
+
+	storesTargets := make([]*placement.Target, 0, len(stores))
+	for _, store := range stores {
+		target, ok := byStore[store]
+		if !ok {
+			target = &placement.Target{Store: store}
+		}
+		storesTargets = append(storesTargets, target)
+	}
+	return storesTargets
+}
+
+// Logs placement rules violations. Returns whether there were none.
+func logViolations(msg, id string, violations []*placement.Violation) bool {
+	for _, violation := range violations {
+		zap.L().Warn(msg, zap.String("FileID", id), zap.String("Label", violation.Rule.Label),
+			zap.String("Value", violation.Value), zap.Int("Shares", violation.Shares),
+			zap.Int("Limit", violation.Limit))
+	}
+	return len(violations) == 0
 }
 
 func checkStoreAvailability(store storesPkg.Store) bool {
@@ -95,6 +258,96 @@ func checkStoreAvailability(store storesPkg.Store) bool {
 	return false
 }
 
+func availableStores(stores []storesPkg.Store) []storesPkg.Store {
+	available := make([]storesPkg.Store, 0, len(stores))
+	for _, store := range stores {
+		if skip := checkStoreAvailability(store); skip {
+			continue
+		}
+
+		available = append(available, store)
+	}
+	return available
+}
+
+func availableTargets(targets []*placement.Target) []*placement.Target {
+	available := make([]*placement.Target, 0, len(targets))
+	for _, target := range targets {
+		if skip := checkStoreAvailability(target.Store); skip {
+			continue
+		}
+
+		available = append(available, target)
+	}
+	return available
+}
+
+// Collects a file's shares from the given stores, logging failures and stale shares.
+// Returns all collected placements, alongside the ones of the current share generation.
+func collectShares(gasper *pkg.Gasper, fileID string, stores []storesPkg.Store) (all, current []*pkg.Placement) {
+	all, storeErrors := gasper.CollectShares(fileID, stores)
+	logStoreErrors("Failed to search share in store", storeErrors)
+
+	current, stale := pkg.SelectGeneration(all)
+	for _, placement := range stale {
+		zap.L().Warn("Ignoring share of a stale generation", zap.String("StoreType", placement.Store.Type()),
+			zap.String("ShareID", placement.Share.ID), zap.Uint64("Generation", placement.Share.Generation()))
+	}
+	return all, current
+}
+
+// Resolves share count and minimum shares threshold: explicitly set flags take precedence over the collected
+// shares' manifest, which takes precedence over the catalog, which takes precedence over flag defaults.
+func sharesParameters(cmd *cobra.Command, placements []*pkg.Placement) (byte, byte) {
+	count, threshold := byte(shareCount), byte(minSharesThreshold)
+
+	manifest := pkg.PlacementsManifest(placements)
+	if manifest == nil {
+		withCatalog(func(cat *catalog.Catalog) error {
+			if entry, err := cat.Get(fileID); err == nil {
+				manifest = &sharesPkg.Manifest{
+					ShareCount:         entry.ShareCount,
+					MinSharesThreshold: entry.MinSharesThreshold,
+				}
+			}
+			return nil
+		})
+	}
+
+	if manifest != nil {
+		if !cmd.Flags().Changed("share-count") {
+			count = manifest.ShareCount
+		}
+		if !cmd.Flags().Changed("shares-threshold") {
+			threshold = manifest.MinSharesThreshold
+		}
+	}
+	return count, threshold
+}
+
+// Logs which stores returned bad shares, excluded from recovery.
+func logBadShares(placements []*pkg.Placement, recovery *pkg.Recovery) {
+	if recovery == nil {
+		return
+	}
+
+	for _, share := range recovery.Bad {
+		for _, placement := range placements {
+			if placement.Share == share {
+				zap.L().Warn("Store returned a bad share, excluding it", zap.String("StoreType",
+					placement.Store.Type()), zap.String("StoreName", placement.Store.Name()),
+					zap.String("ShareID", share.ID))
+			}
+		}
+	}
+}
+
+func logStoreErrors(msg string, storeErrors []*pkg.StoreError) {
+	for _, storeError := range storeErrors {
+		zap.L().Error(msg, zap.String("StoreType", storeError.Store.Type()), zap.Error(storeError.Err))
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/gasper/pkg"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"io/ioutil"
+)
+
+var (
+	shareFilePath   string
+	commitmentsPath string
+)
+
+func init() {
+	shareVerifyCmd.PersistentFlags().StringVarP(&shareFilePath, "share", "", "",
+		"share file to verify, named '<file-id>.<share-id>.gasper' (required)")
+	shareVerifyCmd.PersistentFlags().StringVarP(&commitmentsPath, "commitments", "", "",
+		"commitments published along with the share set (default: the ones committed in the share's manifest)")
+
+	if err := shareVerifyCmd.MarkPersistentFlagRequired("share"); err != nil {
+		panic("Failed to mark 'share' flag as required")
+	}
+
+	shareCmd.AddCommand(shareVerifyCmd)
+	rootCmd.AddCommand(shareCmd)
+}
+
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Inspect a single share",
+	Long:  "Inspect a single share file, as held by a custodian",
+}
+
+var shareVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify a single share",
+	Long: "Check a share split using verifiable secret sharing against the published commitments,\n" +
+		"without reconstructing anything. Exits with a non-zero status when the share is invalid.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fileID, shareID, err := storesPkg.ParseShareFilename(shareFilePath)
+		if err != nil {
+			zap.L().Fatal("Failed to parse share filename", zap.String("Path", shareFilePath), zap.Error(err))
+		}
+
+		data, err := ioutil.ReadFile(shareFilePath)
+		if err != nil {
+			zap.L().Fatal("Failed to read share", zap.String("Path", shareFilePath), zap.Error(err))
+		}
+
+		share, err := sharesPkg.Decode(fileID, shareID, data)
+		if err != nil {
+			zap.L().Fatal("Failed to decode share", zap.String("Path", shareFilePath), zap.Error(err))
+		}
+
+		var commitments *pkg.Commitments
+		if commitmentsPath != "" {
+			if commitments, err = readCommitments(commitmentsPath); err != nil {
+				zap.L().Fatal("Failed to read commitments", zap.String("Path", commitmentsPath), zap.Error(err))
+			}
+		} else {
+			zap.L().Warn("No published commitments given, checking share against its own manifest only")
+		}
+
+		if err := pkg.VerifyShare(share, commitments); err != nil {
+			zap.L().Fatal("Share is invalid", zap.String("FileID", fileID), zap.String("ShareID", shareID),
+				zap.Error(err))
+		}
+
+		zap.L().Info("Share is valid", zap.String("FileID", fileID), zap.String("ShareID", shareID),
+			zap.Uint64("Generation", share.Generation()))
+	},
+}
+
+func readCommitments(path string) (*pkg.Commitments, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read file '%s'", path)
+	}
+
+	commitments := &pkg.Commitments{}
+	if err := json.Unmarshal(data, commitments); err != nil {
+		return nil, errors.WithMessage(err, "unmarshal commitments")
+	}
+	return commitments, nil
+}
+
+func writeCommitments(commitments *pkg.Commitments, path string) error {
+	data, err := json.MarshalIndent(commitments, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "marshal commitments")
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.WithMessagef(err, "write file '%s'", path)
+	}
+	return nil
+}
@@ -3,22 +3,46 @@ package cmd
 import (
 	"github.com/gasper/internal/encryption"
 	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/chunking"
+	"github.com/gasper/pkg/compression"
+	metadataPkg "github.com/gasper/pkg/metadata"
+	"github.com/gasper/pkg/placement"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/middleware"
 	storesPkg "github.com/gasper/pkg/storage/stores"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"time"
 )
 
 var (
 	filePath           string
+	directoryPath      string
 	shareCount         int8
 	minSharesThreshold int8
 	encryptionTurnedOn bool
 	encryptionSalt     string
+	scheme             string
+	commitmentsOut     string
+	preserveOwner      bool
+	preserveXattrs     bool
+	compressionCodec   string
+	chunked            bool
+	objectName         string
+	fileIDAlias        bool
+	placementStrategy  string
+	spreadLabels       []string
+	pins               map[string]string
+	durability         int8
 )
 
 func init() {
 	storeCmd.PersistentFlags().StringVarP(&filePath, "file", "f", "",
-		"file to store (required)")
+		"file to store (required, unless a directory is given)")
+	storeCmd.PersistentFlags().StringVarP(&directoryPath, "dir", "r", "",
+		"directory to store recursively, as a single bundle (instead of a file)")
 	storeCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
 		"share count (default: 2)")
 	storeCmd.PersistentFlags().Int8VarP(&minSharesThreshold, "shares-threshold", "t", 2,
@@ -27,79 +51,409 @@ func init() {
 		"whether to encrypt file (AES) before storing it (default: false)")
 	storeCmd.PersistentFlags().StringVarP(&encryptionSalt, "salt", "s", "",
 		"32-byte long encryption salt (required if encryption mode is turned on)")
-
-	if err := storeCmd.MarkPersistentFlagRequired("file"); err != nil {
-		panic("Failed to mark 'file' flag as required")
-	}
+	storeCmd.PersistentFlags().StringVarP(&scheme, "scheme", "", sharesPkg.SchemeShamir,
+		"secret sharing scheme: 'shamir', 'vss' for verifiable secret sharing, 'ssms' for space-efficient "+
+			"secret sharing made short, or 'policy' to split along the stores config's access policy (default: shamir)")
+	storeCmd.PersistentFlags().StringVarP(&commitmentsOut, "commitments-out", "", "",
+		"where to publish verifiable secret sharing commitments, for custodians to check their shares against")
+	storeCmd.PersistentFlags().BoolVarP(&preserveOwner, "preserve-owner", "", false,
+		"whether to capture file's ownership (uid/gid) along with its name, mode and modification time "+
+			"(default: false)")
+	storeCmd.PersistentFlags().BoolVarP(&preserveXattrs, "preserve-xattrs", "", false,
+		"whether to capture file's extended attributes (default: false)")
+	storeCmd.PersistentFlags().StringVarP(&compressionCodec, "compression", "z", compression.CodecNone,
+		"compression before encryption: 'none', 'gzip', 'zstd', or 'auto' to skip already compressed data "+
+			"(default: none)")
+	storeCmd.PersistentFlags().BoolVarP(&chunked, "chunked", "", false,
+		"whether to cut data into content-defined chunks, stored once each, so that successive backups only ship "+
+			"new chunks (default: false)")
+	storeCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to store the file under (default: a new random one)")
+	storeCmd.PersistentFlags().BoolVarP(&fileIDAlias, "alias", "", true,
+		"whether a new random file id starts with a human-friendly alias, e.g. 'brave-otter-...' (default: true)")
+	storeCmd.PersistentFlags().StringVarP(&objectName, "name", "n", "",
+		"logical name (e.g. a path) to store the file as a new version of, retrievable by name later")
+	storeCmd.PersistentFlags().StringVarP(&placementStrategy, "placement", "", placement.StrategyOrdered,
+		"how shares are assigned to available stores: 'ordered' (config order), 'round-robin' (starting from a "+
+			"different store for each file), 'spread' (across failure domains, as told by store labels), or "+
+			"'capacity' (stores with the most free space first) (default: ordered)")
+	storeCmd.PersistentFlags().StringSliceVarP(&spreadLabels, "spread-labels", "", placement.DefaultSpreadLabels,
+		"store labels telling failure domains apart under 'spread' placement, most significant first")
+	storeCmd.PersistentFlags().Int8VarP(&durability, "durability", "", 0,
+		"how many shares must be put for storing to succeed, otherwise put shares are rolled back "+
+			"(default: shares threshold)")
+	storeCmd.PersistentFlags().StringToStringVarP(&pins, "pin", "", nil,
+		"pin shares to stores, by share id and store name (e.g. '1=/mnt/backup'), whatever the placement")
+	storeCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false,
+		"only print the storing plan (shares, settings, which share goes to which store, and estimated bytes per "+
+			"store), without writing anything (default: false)")
 
 	rootCmd.AddCommand(storeCmd)
 }
 
 var storeCmd = &cobra.Command{
 	Use:   "store",
-	Short: "Store a file",
-	Long:  "Store a file on the provided stores",
+	Short: "Store a file or a directory",
+	Long:  "Store a file, or a directory tree, on the provided stores",
 	Run: func(cmd *cobra.Command, args []string) {
-		if minSharesThreshold > shareCount {
+		if (filePath == "") == (directoryPath == "") {
+			zap.L().Fatal("Either a file or a directory is required")
+		} else if minSharesThreshold > shareCount {
 			zap.L().Fatal("Minimum shares threshold cannot be larger than share count")
 		} else if encryptionTurnedOn && encryptionSalt == "" {
 			zap.L().Fatal("Encryption salt is required when encryption mode is turned on")
+		} else if commitmentsOut != "" && scheme != sharesPkg.SchemeVSS {
+			zap.L().Fatal("Commitments are only published under verifiable secret sharing ('vss' scheme)")
+		} else if err := compression.Validate(compressionCodec); err != nil {
+			zap.L().Fatal("Invalid compression codec", zap.Error(err))
 		}
 
-		gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
-			TurnedOn: decryptionTurnedOn,
-			Salt:     decryptionSalt,
-		})
+		encryptionSettings := &encryption.Settings{
+			TurnedOn: encryptionTurnedOn,
+			Salt:     encryptionSalt,
+		}
+		targets := extractTargets()
+		gasper, err := pkg.NewGasper(placement.Stores(targets), encryptionSettings)
 		if err != nil {
 			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
 		}
 
-		zap.L().Info("Getting file shares")
-		sharedFile, err := gasper.SharesFromFile(filePath, byte(shareCount), byte(minSharesThreshold))
-		if err != nil {
-			zap.L().Fatal("Failed to get file shares", zap.Error(err))
+		template := &sharesPkg.Manifest{
+			Scheme:             scheme,
+			ShareCount:         byte(shareCount),
+			MinSharesThreshold: byte(minSharesThreshold),
+		}
+		if scheme == sharesPkg.SchemePolicy {
+			if template.Policy = extractPolicy(); template.Policy == nil {
+				zap.L().Fatal("Stores config has no access policy")
+			}
 		}
 
-		zap.L().Info("Check general stores availability")
-		stores := gasper.Stores()
-		availableStores := make([]storesPkg.Store, 0, len(stores))
-		for _, store := range stores {
-			if skip := checkStoreAvailability(store); skip {
-				continue
+		count, threshold := int(template.ShareCount), int(template.MinSharesThreshold)
+		if template.Policy != nil {
+			count, threshold = len(template.Policy.Parties()), template.Policy.MinParties()
+		}
+		if durability != 0 && (int(durability) < threshold || int(durability) > count) {
+			zap.L().Fatal("Durability must lie between shares threshold and share count",
+				zap.Int8("Durability", durability), zap.Int("Threshold", threshold), zap.Int("ShareCount", count))
+		}
+
+		chosenFileID := fileID != ""
+		if !chosenFileID {
+			if fileID, err = pkg.NewFileID(fileIDAlias); err != nil {
+				zap.L().Fatal("Failed to generate file id", zap.Error(err))
 			}
+		} else if err := pkg.ValidateFileID(fileID); err != nil {
+			zap.L().Fatal("Invalid file id", zap.Error(err))
+		}
 
-			availableStores = append(availableStores, store)
+		zap.L().Info("Check general stores availability")
+		available := availableTargets(targets)
+		plan := planPlacement(template, targets, writableTargets(available))
+
+		// A chosen file id may already be used by shares in unavailable stores, so it must be checked against every
+		// store. Generated ones are random enough for available stores to do.
+		zap.L().Info("Check file id is available", zap.String("FileID", fileID))
+		checked := available
+		if chosenFileID {
+			if len(available) < len(targets) {
+				zap.L().Fatal("File id cannot be checked against every store, as some are unavailable",
+					zap.String("FileID", fileID), zap.Int("Unavailable", len(targets)-len(available)))
+			}
+			checked = targets
+		}
+		if err := gasper.CheckFileIDAvailable(fileID, placement.Stores(checked)); err != nil {
+			zap.L().Fatal("File id is unavailable", zap.Error(err))
 		}
 
-		availableStoresCount := len(availableStores)
-		if int(minSharesThreshold) > availableStoresCount {
-			zap.L().Error("Not enough available stores", zap.Int8("Need", minSharesThreshold),
-				zap.Int("Got", availableStoresCount), zap.Int8("Recommended", shareCount))
+		if dryRun {
+			logStorePlan(template, plan, targets)
 			return
 		}
 
-		zap.L().Info("Put shares in stores")
-		i := 0
-		for _, share := range sharedFile.Shares {
-			if i > len(stores)-1 {
-				zap.L().Error("All stores exhausted")
+		zap.L().Info("Getting file shares")
+		metadataOptions := &metadataPkg.Options{
+			Ownership:   preserveOwner,
+			Xattrs:      preserveXattrs,
+			Compression: compressionCodec,
+		}
+
+		var sharedFile *sharesPkg.SharedFile
+		if chunked {
+			sharedFile = storeChunks(gasper, template, metadataOptions, placement.Stores(available), plan)
+		} else if directoryPath != "" {
+			sharedFile, err = gasper.SplitDirectory(fileID, directoryPath, template, metadataOptions)
+		} else {
+			sharedFile, err = gasper.SplitFile(fileID, filePath, template, metadataOptions)
+		}
+		if err != nil {
+			zap.L().Fatal("Failed to get file shares", zap.Error(err))
+		}
+
+		var stores []storesPkg.Store
+		if sharedFile.Manifest.Policy != nil {
+			if stores, err = pkg.PolicyStores(sharedFile, gasper.Stores()); err != nil {
+				zap.L().Fatal("Failed to match policy parties with stores", zap.Error(err))
+			}
+
+			availableStoresCount := len(availableStores(stores))
+			if int(sharedFile.Manifest.MinSharesThreshold) > availableStoresCount {
+				zap.L().Error("Not enough available stores", zap.Uint8("Need",
+					sharedFile.Manifest.MinSharesThreshold), zap.Int("Got", availableStoresCount),
+					zap.Uint8("Recommended", sharedFile.Manifest.ShareCount))
 				return
 			}
+		} else if stores, err = plan.Stores(sharedFile.Shares); err != nil {
+			zap.L().Fatal("Shares don't match placement plan", zap.Error(err))
+		}
 
-			store := stores[i]
-			storeType := store.Type()
-			i++
+		zap.L().Info("Put shares in stores")
+		placements, storeErrors, err := gasper.DistributeShares(sharedFile, stores, int(durability))
+		logStoreErrors("Failed to put share in store", storeErrors)
+		if distributionErr, ok := err.(*pkg.DistributionError); ok {
+			logStoreErrors("Failed to put share in store", distributionErr.PutErrors)
+			logStoreErrors("Failed to roll back share, left behind in store", distributionErr.RollbackErrors)
+			zap.L().Fatal("Failed to store file, put shares were rolled back", zap.Error(err))
+		} else if err != nil {
+			zap.L().Fatal("Failed to store file", zap.Error(err))
+		}
 
-			zap.L().Debug("Available! Put share in store", zap.String("StoreType", storeType),
-				zap.String("ShareID", share.ID))
-			if err := store.Put(share); err != nil {
-				zap.L().Error("Failed to put share in store", zap.String("StoreType", storeType),
-					zap.Error(err))
-				continue
+		if commitments := pkg.PublishedCommitments(sharedFile); commitments != nil && commitmentsOut != "" {
+			if err := writeCommitments(commitments, commitmentsOut); err != nil {
+				zap.L().Error("Failed to publish commitments", zap.String("Path", commitmentsOut), zap.Error(err))
 			}
 		}
 
+		if objectName != "" {
+			addVersion(gasper, sharedFile, template)
+		}
+
+		catalogStored(sharedFile, placements, sourcePath(), sourceSize(), encryptionSettings)
+
 		zap.L().Info("Success! Keep the following info for later use", zap.String("FileID", sharedFile.ID),
 			zap.String("Checksum", sharedFile.Checksum))
 	},
 }
+
+// Plans which available, writable store each share goes to, according to placement flags, and checks the plan
+// against the stores config's placement rules - all before anything is written.
+// Returns nil under an access policy, as shares then go to their parties' stores (which are checked instead).
+func planPlacement(template *sharesPkg.Manifest, targets, available []*placement.Target) placement.Plan {
+	rules := extractRules()
+
+	if template.Policy != nil {
+		if len(pins) > 0 {
+			zap.L().Fatal("Shares split along a policy go to their parties' stores, and cannot be pinned")
+		}
+
+		partyTargets := make([]*placement.Target, 0)
+		for _, party := range template.Policy.Parties() {
+			for _, target := range targets {
+				if target.Store.Name() == party {
+					partyTargets = append(partyTargets, target)
+				}
+			}
+		}
+
+		violations := placement.CheckRules(partyTargets, rules, template.Policy.MinParties())
+		if !logViolations("Policy parties' stores violate placement rule", fileID, violations) {
+			zap.L().Fatal("Policy's placement violates placement rules")
+		}
+		return nil
+	}
+
+	strategy, err := placement.FromName(placementStrategy, spreadLabels)
+	if err != nil {
+		zap.L().Fatal("Invalid placement", zap.Error(err))
+	}
+
+	shareIDs := placement.ShareIDs(template.ShareCount)
+	shareSize := pkg.EstimateShareSize(template, sourceDataSize())
+	plan, err := placement.Place(strategy, fileID, shareIDs, shareSize, available, pins)
+	if err != nil {
+		zap.L().Fatal("Failed to plan share placement", zap.Int("AvailableStores", len(available)), zap.Error(err))
+	}
+
+	for _, shareID := range shareIDs {
+		zap.L().Debug("Share placement planned", zap.String("ShareID", shareID),
+			zap.String("StoreType", plan[shareID].Store.Type()), zap.String("StoreName", plan[shareID].Store.Name()))
+	}
+
+	violations := placement.CheckRules(plan.Targets(shareIDs), rules, int(template.MinSharesThreshold))
+	if !logViolations("Planned placement violates placement rule", fileID, violations) {
+		zap.L().Fatal("Planned placement violates placement rules, try another placement (e.g. 'spread')",
+			zap.String("Placement", placementStrategy))
+	}
+	return plan
+}
+
+// Logs what storing would do, without doing it: shares, settings, and which store each share would go to, along with
+// its estimated size.
+func logStorePlan(template *sharesPkg.Manifest, plan placement.Plan, targets []*placement.Target) {
+	size := sourceDataSize()
+
+	count, threshold := template.ShareCount, template.MinSharesThreshold
+	if template.Policy != nil {
+		count, threshold = byte(len(template.Policy.Parties())), byte(template.Policy.MinParties())
+	}
+
+	required := int(threshold)
+	if durability != 0 {
+		required = int(durability)
+	}
+
+	zap.L().Info("Storing plan", zap.String("FileID", fileID), zap.String("Name", objectName),
+		zap.String("Source", sourcePath()), zap.Int64("Size", size), zap.String("Scheme", template.SchemeOrDefault()),
+		zap.Uint8("ShareCount", count), zap.Uint8("Threshold", threshold), zap.Int("Durability", required),
+		zap.Bool("Encrypt", encryptionTurnedOn), zap.String("Compression", compressionCodec),
+		zap.Bool("Chunked", chunked), zap.String("Placement", placementStrategy))
+
+	// Note: chunked storing only ships new chunks, so the estimate is an upper bound then.
+	shareSize := pkg.EstimateShareSize(&sharesPkg.Manifest{Scheme: template.Scheme, ShareCount: count,
+		MinSharesThreshold: threshold}, size)
+
+	shareIDs := placement.ShareIDs(count)
+	total := int64(0)
+	for i, shareID := range shareIDs {
+		var store storesPkg.Store
+		if template.Policy != nil {
+			for _, target := range targets {
+				if target.Store.Name() == template.Policy.Parties()[i] {
+					store = target.Store
+				}
+			}
+		} else {
+			store = plan[shareID].Store
+		}
+
+		if store == nil {
+			zap.L().Warn("Planned share has no store", zap.String("ShareID", shareID),
+				zap.String("Party", template.Policy.Parties()[i]))
+			continue
+		}
+
+		total += shareSize
+		zap.L().Info("Planned share", zap.String("ShareID", shareID), zap.String("StoreType", store.Type()),
+			zap.String("StoreName", store.Name()), zap.Int64("EstimatedBytes", shareSize))
+	}
+
+	zap.L().Info("Dry run, nothing was written.", zap.Int64("EstimatedBytes", total))
+}
+
+// Returns the total size of a directory tree's regular files, or 0 if it can't be walked.
+func directorySize(path string) int64 {
+	size := int64(0)
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Cuts the file (or directory tree) into chunks, and stores those the given stores don't hold yet, as planned.
+// Returns the chunk index's shared file, to be stored in their place.
+func storeChunks(gasper *pkg.Gasper, template *sharesPkg.Manifest, metadataOptions *metadataPkg.Options,
+	stores []storesPkg.Store, plan placement.Plan) *sharesPkg.SharedFile {
+	var (
+		data         []byte
+		fileMetadata *metadataPkg.Metadata
+		err          error
+	)
+	if directoryPath != "" {
+		template.Content = sharesPkg.ContentDirectory
+		data, fileMetadata, err = pkg.LoadDirectory(directoryPath, metadataOptions)
+	} else {
+		data, fileMetadata, err = pkg.LoadFile(filePath, metadataOptions)
+	}
+	if err != nil {
+		zap.L().Fatal("Failed to load file", zap.Error(err))
+	}
+
+	zap.L().Info("Store new chunks")
+	sharedFile, report, storeErrors, err := gasper.SplitChunked(fileID, data, fileMetadata, template, stores, plan,
+		chunking.DefaultParams)
+	logStoreErrors("Failed to list or put chunks in store", storeErrors)
+	if err != nil {
+		zap.L().Fatal("Failed to store chunks", zap.Error(err))
+	}
+
+	zap.L().Info("Chunks stored", zap.Int("Chunks", report.Chunks), zap.Int("NewChunks", report.NewChunks),
+		zap.Int64("NewBytes", report.NewBytes))
+	return sharedFile
+}
+
+// Records the stored file as a new version of the named object.
+// Note: history is loaded from every store, unavailable ones included, so that it's never silently overwritten by a
+// fresh one.
+func addVersion(gasper *pkg.Gasper, sharedFile *sharesPkg.SharedFile, template *sharesPkg.Manifest) {
+	zap.L().Info("Record new version", zap.String("Name", objectName))
+	history, placements, err := gasper.LoadHistory(objectName, gasper.Stores())
+	if err != nil {
+		zap.L().Fatal("Failed to load version history, file is stored but not recorded", zap.String("Name",
+			objectName), zap.String("FileID", sharedFile.ID), zap.String("Checksum", sharedFile.Checksum),
+			zap.Error(err))
+	}
+
+	version := history.Add(sharedFile.ID, sharedFile.Checksum, time.Now())
+	_, storeErrors, err := gasper.SaveHistory(history, placements, template, availableStores(gasper.Stores()))
+	logStoreErrors("Failed to put history share in store", storeErrors)
+	if err != nil {
+		zap.L().Fatal("Failed to save version history, file is stored but not recorded", zap.String("Name",
+			objectName), zap.String("FileID", sharedFile.ID), zap.String("Checksum", sharedFile.Checksum),
+			zap.Error(err))
+	}
+
+	zap.L().Info("Version recorded", zap.String("Name", objectName), zap.Int("Version", version.Number))
+}
+
+// Returns the absolute path of the stored file or directory.
+func sourcePath() string {
+	path := filePath
+	if directoryPath != "" {
+		path = directoryPath
+	}
+
+	if absolutePath, err := filepath.Abs(path); err == nil {
+		return absolutePath
+	}
+	return path
+}
+
+// Returns the size of the data to store: the source file's, or the directory tree's.
+func sourceDataSize() int64 {
+	if directoryPath != "" {
+		return directorySize(directoryPath)
+	}
+	return sourceSize()
+}
+
+// Returns the targets shares can be put in, leaving out read-only stores.
+func writableTargets(targets []*placement.Target) []*placement.Target {
+	writable := make([]*placement.Target, 0, len(targets))
+	for _, target := range targets {
+		if !middleware.IsReadOnly(target.Store) {
+			writable = append(writable, target)
+		}
+	}
+	return writable
+}
+
+// Returns the stored file's size, or 0 for a directory tree.
+func sourceSize() int64 {
+	if filePath == "" {
+		return 0
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	storesCmd.AddCommand(storesTypesCmd)
+	rootCmd.AddCommand(storesCmd)
+}
+
+var storesCmd = &cobra.Command{
+	Use:   "stores",
+	Short: "Inspect supported stores",
+}
+
+var storesTypesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "List supported store types, and their config attributes",
+	Run: func(cmd *cobra.Command, args []string) {
+		registrations := storesPkg.Registrations()
+		for _, registration := range registrations {
+			attrs, err := storesPkg.Attrs(registration)
+			if err != nil {
+				zap.L().Fatal("Invalid store config schema", zap.String("Type", registration.Type), zap.Error(err))
+			}
+
+			zap.L().Info("Store type", zap.String("Type", registration.Type),
+				zap.String("Description", registration.Description), zap.Any("Attributes", attrs))
+		}
+		zap.L().Info("Store types listed.", zap.Int("Types", len(registrations)))
+	},
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
+	"github.com/gasper/pkg/placement"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	verifyCmd.PersistentFlags().StringVarP(&fileID, "file-id", "i", "",
+		"file id to verify (default: every file found in stores)")
+	verifyCmd.PersistentFlags().StringVarP(&checksum, "checksum", "m", "",
+		"checksum of the shared file, only used along with a file id (default: the catalog's)")
+	verifyCmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+		"whether files were encrypted before storing them (default: false)")
+	verifyCmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+		"decryption salt (required if decryption mode is turned on)")
+	verifyCmd.PersistentFlags().Int8VarP(&shareCount, "share-count", "a", 2,
+		"share count, for files stored without a manifest (default: 2)")
+	verifyCmd.PersistentFlags().Int8VarP(&minSharesThreshold, "shares-threshold", "t", 2,
+		"threshold of minimum shares, for files stored without a manifest (default: 2)")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify files are recoverable",
+	Long: "Check files are recoverable by combining and decrypting their shares in memory, " +
+		"without writing anything.\n" +
+		"Reports per-store health, missing or corrupt shares, and the margin above threshold.\n" +
+		"Exits with a non-zero status when any file drops to or below its threshold, or violates a placement rule.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if decryptionTurnedOn && decryptionSalt == "" {
+			zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+		}
+
+		targets, rules := extractTargets(), extractRules()
+		gasper, err := pkg.NewGasper(placement.Stores(targets), &encryption.Settings{
+			TurnedOn: decryptionTurnedOn,
+			Salt:     decryptionSalt,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+		}
+
+		healthy := true
+
+		zap.L().Info("Check stores health")
+		stores := make([]storesPkg.Store, 0, len(gasper.Stores()))
+		for _, store := range gasper.Stores() {
+			if skip := checkStoreAvailability(store); skip {
+				zap.L().Warn("Store is unhealthy", zap.String("StoreType", store.Type()),
+					zap.String("StoreName", store.Name()))
+				healthy = false
+				continue
+			}
+
+			if _, err := store.List(); err != nil {
+				zap.L().Warn("Store is unhealthy, failed to list files", zap.String("StoreType", store.Type()),
+					zap.String("StoreName", store.Name()), zap.Error(err))
+				healthy = false
+				continue
+			}
+
+			zap.L().Info("Store is healthy", zap.String("StoreType", store.Type()),
+				zap.String("StoreName", store.Name()))
+			stores = append(stores, store)
+		}
+
+		fileIDs := []string{fileID}
+		if fileID == "" {
+			var storeErrors []*pkg.StoreError
+			fileIDs, storeErrors = gasper.ListFileIDs(stores)
+			logStoreErrors("Failed to list files in store", storeErrors)
+		}
+
+		verifiedChunks := make(map[string]bool)
+		unverified := 0
+		for _, id := range fileIDs {
+			fileChecksum := ""
+			if id == fileID {
+				fileChecksum = checksum
+			}
+			if fileChecksum == "" {
+				fileChecksum = catalogChecksum(id)
+			}
+
+			placements, storeErrors := gasper.CollectShares(id, stores)
+			logStoreErrors("Failed to read share from store", storeErrors)
+
+			// Chunks can only be decrypted with the keys held by their indexes, so they're verified along with them.
+			manifest := pkg.PlacementsManifest(placements)
+			if fileID == "" && manifest != nil && manifest.Content == sharesPkg.ContentChunk {
+				continue
+			}
+
+			// Note: without a checksum, nor a decryption key the stores don't hold, shares which combine into any
+			// data at all pass, so the file can't be told authentic.
+			authenticated := fileChecksum != "" || decryptionTurnedOn
+			health := gasper.Verify(id, fileChecksum, placements, byte(shareCount), byte(minSharesThreshold))
+			if !logFileHealth(health, authenticated) {
+				healthy = false
+			} else if !authenticated {
+				unverified++
+			}
+			if !checkPlacementRules(health, targets, rules) {
+				healthy = false
+			}
+			withCatalog(func(cat *catalog.Catalog) error {
+				return catalogVerified(cat, health)
+			})
+
+			if !health.Recoverable() || manifest == nil || !manifest.IsChunkIndex() {
+				continue
+			}
+
+			chunkHealths, storeErrors, err := gasper.VerifyChunks(id, fileChecksum, placements, stores,
+				verifiedChunks)
+			logStoreErrors("Failed to read chunk share from store", storeErrors)
+			if err != nil {
+				zap.L().Error("Failed to verify chunks", zap.String("FileID", id), zap.Error(err))
+				healthy = false
+			}
+
+			for _, chunkHealth := range chunkHealths {
+				if !logFileHealth(chunkHealth, authenticated) {
+					healthy = false
+				}
+			}
+		}
+
+		if !healthy {
+			zap.L().Fatal("Verification failed", zap.Int("Files", len(fileIDs)), zap.Int("Unverified", unverified))
+		}
+
+		zap.L().Info("Verification succeeded.", zap.Int("Files", len(fileIDs)), zap.Int("Unverified", unverified))
+	},
+}
+
+// Checks where a file's healthy shares are against placement rules, logging violations. Returns whether there were
+// none.
+func checkPlacementRules(health *pkg.FileHealth, targets []*placement.Target, rules []*placement.Rule) bool {
+	stores := make([]storesPkg.Store, 0, len(health.Healthy))
+	for _, healthy := range health.Healthy {
+		stores = append(stores, healthy.Store)
+	}
+
+	violations := placement.CheckRules(storesTargets(stores, targets), rules, int(health.MinSharesThreshold))
+	return logViolations("Shares' placement violates placement rule", health.FileID, violations)
+}
+
+// Logs a file's health. Returns whether file is healthy - that is, recoverable and above its threshold.
+// Files whose recovered data couldn't be authenticated (no checksum, no decryption) are reported as unverified rather
+// than healthy.
+func logFileHealth(health *pkg.FileHealth, authenticated bool) bool {
+	for _, placement := range health.Corrupt {
+		zap.L().Warn("Corrupt share", zap.String("FileID", health.FileID),
+			zap.String("ShareID", placement.Share.ID), zap.String("StoreType", placement.Store.Type()),
+			zap.String("StoreName", placement.Store.Name()))
+	}
+
+	for _, placement := range health.Stale {
+		zap.L().Warn("Share of a stale generation", zap.String("FileID", health.FileID),
+			zap.String("ShareID", placement.Share.ID), zap.Uint64("Generation", placement.Share.Generation()),
+			zap.String("StoreType", placement.Store.Type()), zap.String("StoreName", placement.Store.Name()))
+	}
+
+	fields := []zap.Field{
+		zap.String("FileID", health.FileID),
+		zap.Uint64("Generation", health.Generation),
+		zap.Int("HealthyShares", len(health.Healthy)),
+		zap.Uint8("Threshold", health.MinSharesThreshold),
+		zap.Int("Margin", health.Margin()),
+		zap.Strings("MissingShareIDs", health.Missing),
+	}
+
+	if !health.Recoverable() {
+		zap.L().Error("File is unrecoverable", append(fields, zap.Error(health.Err))...)
+		return false
+	} else if health.Margin() <= 0 {
+		zap.L().Warn("File is recoverable, but at or below its threshold", fields...)
+		return false
+	} else if !authenticated {
+		zap.L().Warn("File is recoverable, but unverified (no checksum)", fields...)
+		return true
+	}
+
+	zap.L().Info("File is healthy", fields...)
+	return true
+}
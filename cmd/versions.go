@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg"
+	"github.com/gasper/pkg/catalog"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Date format accepted by '--at', besides RFC 3339.
+const versionDateFormat = "2006-01-02"
+
+var (
+	versionNumber int
+	versionTime   string
+	keepLast      int
+	keepDaily     int
+	keepWeekly    int
+	keepMonthly   int
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{versionsCmd, pruneCmd} {
+		cmd.PersistentFlags().StringVarP(&objectName, "name", "n", "",
+			"logical name the file was stored as (required)")
+		cmd.PersistentFlags().BoolVarP(&decryptionTurnedOn, "decrypt", "e", false,
+			"whether files were encrypted before storing them (default: false)")
+		cmd.PersistentFlags().StringVarP(&decryptionSalt, "salt", "s", "",
+			"decryption salt (required if decryption mode is turned on)")
+
+		if err := cmd.MarkPersistentFlagRequired("name"); err != nil {
+			panic("Failed to mark 'name' flag as required")
+		}
+	}
+
+	pruneCmd.PersistentFlags().IntVarP(&keepLast, "keep-last", "", 0,
+		"keep the last n versions")
+	pruneCmd.PersistentFlags().IntVarP(&keepDaily, "keep-daily", "", 0,
+		"keep the latest version of each of the last n days having versions")
+	pruneCmd.PersistentFlags().IntVarP(&keepWeekly, "keep-weekly", "", 0,
+		"keep the latest version of each of the last n weeks having versions")
+	pruneCmd.PersistentFlags().IntVarP(&keepMonthly, "keep-monthly", "", 0,
+		"keep the latest version of each of the last n months having versions")
+	pruneCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false,
+		"only list the versions which would be pruned, without deleting them (default: false)")
+
+	rootCmd.AddCommand(versionsCmd)
+	rootCmd.AddCommand(pruneCmd)
+}
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "List a named file's versions",
+	Long:  "List the versions a file was stored as under a logical name, oldest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		gasper := versionsGasper()
+
+		history, _, err := gasper.LoadHistory(objectName, availableStores(gasper.Stores()))
+		if err != nil {
+			zap.L().Fatal("Failed to load version history", zap.String("Name", objectName), zap.Error(err))
+		} else if len(history.Versions) == 0 {
+			zap.L().Warn("No versions found for requested name", zap.String("Name", objectName))
+			return
+		}
+
+		for _, version := range history.Versions {
+			zap.L().Info("Version", zap.Int("Version", version.Number), zap.Time("Time", version.Time),
+				zap.String("FileID", version.FileID), zap.String("Checksum", version.Checksum))
+		}
+	},
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Prune a named file's old versions",
+	Long: "Delete the versions of a named file which the retention rules don't keep.\n" +
+		"A version is kept if any rule keeps it. Days, weeks and months are taken in UTC.",
+	Run: func(cmd *cobra.Command, args []string) {
+		retention := &pkg.RetentionPolicy{
+			KeepLast:    keepLast,
+			KeepDaily:   keepDaily,
+			KeepWeekly:  keepWeekly,
+			KeepMonthly: keepMonthly,
+		}
+		if retention.KeepsAll() {
+			zap.L().Fatal("At least one retention rule is required")
+		}
+
+		gasper := versionsGasper()
+
+		zap.L().Info("Prune versions", zap.String("Name", objectName))
+		report, storeErrors, err := gasper.Prune(objectName, retention, availableStores(gasper.Stores()), dryRun)
+		logStoreErrors("Failed to update history or delete version in store", storeErrors)
+		if err != nil {
+			zap.L().Fatal("Failed to prune versions", zap.String("Name", objectName), zap.Error(err))
+		}
+
+		for _, version := range report.Pruned {
+			zap.L().Info("Pruned version", zap.Bool("DryRun", dryRun), zap.Int("Version", version.Number),
+				zap.Time("Time", version.Time), zap.String("FileID", version.FileID))
+		}
+
+		if !dryRun {
+			withCatalog(func(cat *catalog.Catalog) error {
+				for _, version := range report.Pruned {
+					if err := cat.Delete(version.FileID); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+
+		zap.L().Info("Versions pruned.", zap.String("Name", objectName), zap.Bool("DryRun", dryRun),
+			zap.Int("Kept", len(report.Kept)), zap.Int("Pruned", len(report.Pruned)))
+	},
+}
+
+func versionsGasper() *pkg.Gasper {
+	if decryptionTurnedOn && decryptionSalt == "" {
+		zap.L().Fatal("Decryption salt is required when decryption mode is turned on")
+	}
+
+	gasper, err := pkg.NewGasper(extractStores(), &encryption.Settings{
+		TurnedOn: decryptionTurnedOn,
+		Salt:     decryptionSalt,
+	})
+	if err != nil {
+		zap.L().Fatal("Failed to initialize Gasper", zap.Error(err))
+	}
+	return gasper
+}
+
+// Finds the version of the named object to retrieve, by number, date, or the latest one.
+func findVersion(gasper *pkg.Gasper) *pkg.Version {
+	var at time.Time
+	if versionTime != "" {
+		var err error
+		if at, err = parseVersionTime(versionTime); err != nil {
+			zap.L().Fatal("Invalid date", zap.String("At", versionTime), zap.Error(err))
+		}
+	}
+
+	history, _, err := gasper.LoadHistory(objectName, availableStores(gasper.Stores()))
+	if err != nil {
+		zap.L().Fatal("Failed to load version history", zap.String("Name", objectName), zap.Error(err))
+	}
+
+	version, err := history.Find(versionNumber, at)
+	if err != nil {
+		zap.L().Fatal("Failed to find version", zap.String("Name", objectName), zap.Error(err))
+	}
+
+	zap.L().Info("Found version", zap.String("Name", objectName), zap.Int("Version", version.Number),
+		zap.Time("Time", version.Time), zap.String("FileID", version.FileID))
+	return version
+}
+
+// Parses an RFC 3339 time, or a local date - standing for the end of that day.
+func parseVersionTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	day, err := time.ParseInLocation(versionDateFormat, value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
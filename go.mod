@@ -5,9 +5,11 @@ go 1.13
 require (
 	github.com/codahale/sss v0.0.0-20160501174526-0cb9f6d3f7f1
 	github.com/dustinkirkland/golang-petname v0.0.0-20191129215211-8e5a1ed0cff0
+	github.com/klauspost/compress v1.11.13
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.0.0
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
+	go.etcd.io/bbolt v1.3.5
 	go.uber.org/zap v1.16.0
 )
@@ -56,6 +56,10 @@ func (e *Encryptor) Decrypt(data []byte) ([]byte, error) {
 		return nil, errors.WithMessage(err, "new GCM")
 	}
 
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("data is shorter than nonce")
+	}
+
 	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
 
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
@@ -0,0 +1,122 @@
+// Package gf256 implements arithmetic over GF(2^8), using the same field as github.com/codahale/sss (0x11b prime
+// polynomial, 0x03 as generator), so that shares split by it can be manipulated directly.
+package gf256
+
+const fieldSize = 256
+
+var (
+	exp [fieldSize]byte
+	log [fieldSize]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < fieldSize-1; i++ {
+		exp[i] = x
+		log[x] = byte(i)
+		x = mulNoTable(x, 0x03)
+	}
+	exp[fieldSize-1] = exp[0]
+}
+
+func mulNoTable(a, b byte) byte {
+	var result byte
+	for b > 0 {
+		if b&1 == 1 {
+			result ^= a
+		}
+
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return result
+}
+
+// Adds (or subtracts, which is the same) two field elements.
+func Add(a, b byte) byte {
+	return a ^ b
+}
+
+// Multiplies two field elements.
+func Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return exp[(int(log[a])+int(log[b]))%(fieldSize-1)]
+}
+
+// Divides two field elements. Panics on division by zero.
+func Div(a, b byte) byte {
+	if b == 0 {
+		panic("gf256: division by zero")
+	}
+
+	if a == 0 {
+		return 0
+	}
+
+	p := (int(log[a]) - int(log[b])) % (fieldSize - 1)
+	if p < 0 {
+		p += fieldSize - 1
+	}
+	return exp[p]
+}
+
+// Raises a field element to the given power.
+func Pow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	} else if a == 0 {
+		return 0
+	}
+	return exp[(int(log[a])*n)%(fieldSize-1)]
+}
+
+// Computes the Lagrange basis weights of the given (distinct) x-coordinates, evaluated at x.
+// A polynomial passing through points (xs[i], ys[i]) evaluates at x to the sum of weights[i] * ys[i].
+func LagrangeWeights(xs []byte, x byte) []byte {
+	weights := make([]byte, len(xs))
+	for i, a := range xs {
+		weight := byte(1)
+		for j, b := range xs {
+			if i != j {
+				weight = Mul(weight, Div(x^b, a^b))
+			}
+		}
+		weights[i] = weight
+	}
+	return weights
+}
+
+// Evaluates at x the polynomials passing through the given points, where each point holds one y-value per
+// polynomial (as in shares split by github.com/codahale/sss, one polynomial per secret byte).
+// All y-value slices must be of the same length.
+func Interpolate(points map[byte][]byte, x byte) []byte {
+	xs := make([]byte, 0, len(points))
+	ys := make([][]byte, 0, len(points))
+	for px, py := range points {
+		xs = append(xs, px)
+		ys = append(ys, py)
+	}
+
+	if len(ys) == 0 {
+		return nil
+	}
+
+	weights := LagrangeWeights(xs, x)
+	result := make([]byte, len(ys[0]))
+	for i, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+
+		for j, y := range ys[i] {
+			result[j] ^= Mul(weight, y)
+		}
+	}
+	return result
+}
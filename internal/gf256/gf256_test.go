@@ -0,0 +1,102 @@
+package gf256
+
+import (
+	"bytes"
+	"github.com/codahale/sss"
+	"testing"
+)
+
+func TestMulMatchesTables(t *testing.T) {
+	for a := 0; a < fieldSize; a++ {
+		for b := 0; b < fieldSize; b++ {
+			if got, want := Mul(byte(a), byte(b)), mulNoTable(byte(a), byte(b)); got != want {
+				t.Fatalf("Mul(%#x, %#x) = %#x, want %#x", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestDivInvertsMul(t *testing.T) {
+	for a := 0; a < fieldSize; a++ {
+		for b := 1; b < fieldSize; b++ {
+			if got := Div(Mul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("Div(Mul(%#x, %#x), %#x) = %#x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestPow(t *testing.T) {
+	for a := 0; a < fieldSize; a++ {
+		want := byte(1)
+		for n := 0; n < 10; n++ {
+			if got := Pow(byte(a), n); got != want {
+				t.Fatalf("Pow(%#x, %d) = %#x, want %#x", a, n, got, want)
+			}
+			want = Mul(want, byte(a))
+		}
+	}
+}
+
+func TestInterpolatePolynomial(t *testing.T) {
+	// f(x) = 0x2a + 0x11x + 0x05x^2, evaluated through 3 of its points.
+	f := func(x byte) byte {
+		return Add(Add(0x2a, Mul(0x11, x)), Mul(0x05, Mul(x, x)))
+	}
+
+	points := map[byte][]byte{1: {f(1)}, 7: {f(7)}, 200: {f(200)}}
+	for x := 0; x < fieldSize; x++ {
+		if got := Interpolate(points, byte(x)); got[0] != f(byte(x)) {
+			t.Fatalf("Interpolate at %#x = %#x, want %#x", x, got[0], f(byte(x)))
+		}
+	}
+}
+
+func TestInterpolateNoPoints(t *testing.T) {
+	if got := Interpolate(map[byte][]byte{}, 1); got != nil {
+		t.Fatalf("Interpolate without points = %v, want nil", got)
+	}
+}
+
+// Shares split by sss are regenerated byte for byte out of any threshold of the others, and their secret is
+// recovered at x = 0.
+func TestRepairSSSShares(t *testing.T) {
+	secret := []byte("the quick brown fox jumps over the lazy dog")
+
+	tests := []struct {
+		name      string
+		n, k      byte
+		survivors []byte
+		missing   []byte
+	}{
+		{name: "2 of 2, none missing", n: 2, k: 2, survivors: []byte{1, 2}},
+		{name: "2 of 3, one missing", n: 3, k: 2, survivors: []byte{1, 3}, missing: []byte{2}},
+		{name: "3 of 5, two missing", n: 5, k: 3, survivors: []byte{2, 4, 5}, missing: []byte{1, 3}},
+		{name: "3 of 5, more survivors than needed", n: 5, k: 3, survivors: []byte{1, 2, 3, 5}, missing: []byte{4}},
+		{name: "2 of 4, half missing", n: 4, k: 2, survivors: []byte{1, 4}, missing: []byte{2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := sss.Split(test.n, test.k, secret)
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+
+			points := make(map[byte][]byte, len(test.survivors))
+			for _, x := range test.survivors {
+				points[x] = shares[x]
+			}
+
+			for _, x := range test.missing {
+				if got := Interpolate(points, x); !bytes.Equal(got, shares[x]) {
+					t.Errorf("share %d: regenerated %x, want %x", x, got, shares[x])
+				}
+			}
+
+			if got := Interpolate(points, 0); !bytes.Equal(got, secret) {
+				t.Errorf("secret: interpolated %q, want %q", got, secret)
+			}
+		})
+	}
+}
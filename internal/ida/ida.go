@@ -0,0 +1,91 @@
+// Package ida implements Rabin's information dispersal: data is erasure-coded into n fragments of about 1/k of its
+// size, any k of which are enough to reconstruct it.
+//
+// Fragments are Reed-Solomon codewords over GF(2^8): data is cut into k stripes, and the fragment at x holds the
+// evaluations at x of the polynomials (one per byte offset) passing through the stripes at 1..k. The code is thus
+// systematic - fragments 1..k are the stripes themselves. Note that dispersal alone provides no secrecy.
+package ida
+
+import (
+	"github.com/gasper/internal/gf256"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// Share IDs are non-zero field elements.
+const maxFragments = 255
+
+var (
+	ErrInvalidThreshold    = errors.New("threshold must be >= 1 and <= fragment count (at most 255)")
+	ErrNotEnoughFragments  = errors.New("not enough fragments to reconstruct data")
+	ErrInvalidFragmentSize = errors.New("fragments are of an unexpected size")
+)
+
+// Returns the size of each of the fragments data of the given size is dispersed into.
+func FragmentSize(size, k int) int {
+	return (size + k - 1) / k
+}
+
+// Disperses data into n fragments (with IDs 1..n), any k of which are enough to reconstruct it.
+func Disperse(data []byte, n, k int) (map[byte][]byte, error) {
+	if k < 1 || k > n || n > maxFragments {
+		return nil, ErrInvalidThreshold
+	}
+
+	fragmentSize := FragmentSize(len(data), k)
+	stripes := make(map[byte][]byte, k)
+	for x := 1; x <= k; x++ {
+		stripe := make([]byte, fragmentSize)
+		if start := (x - 1) * fragmentSize; start < len(data) {
+			copy(stripe, data[start:])
+		}
+		stripes[byte(x)] = stripe
+	}
+
+	fragments := make(map[byte][]byte, n)
+	for x := 1; x <= n; x++ {
+		if stripe, ok := stripes[byte(x)]; ok {
+			fragments[byte(x)] = stripe
+			continue
+		}
+		fragments[byte(x)] = gf256.Interpolate(stripes, byte(x))
+	}
+	return fragments, nil
+}
+
+// Reconstructs data of the given size out of (at least k) fragments, by fragment ID.
+func Reconstruct(fragments map[byte][]byte, k, size int) ([]byte, error) {
+	if k < 1 || k > maxFragments {
+		return nil, ErrInvalidThreshold
+	}
+
+	xs := make([]byte, 0, len(fragments))
+	for x := range fragments {
+		if x != 0 {
+			xs = append(xs, x)
+		}
+	}
+	if len(xs) < k {
+		return nil, ErrNotEnoughFragments
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	fragmentSize := FragmentSize(size, k)
+	points := make(map[byte][]byte, k)
+	for _, x := range xs[:k] {
+		if len(fragments[x]) != fragmentSize {
+			return nil, ErrInvalidFragmentSize
+		}
+		points[x] = fragments[x]
+	}
+
+	data := make([]byte, 0, fragmentSize*k)
+	for x := 1; x <= k; x++ {
+		if stripe, ok := points[byte(x)]; ok {
+			data = append(data, stripe...)
+			continue
+		}
+		data = append(data, gf256.Interpolate(points, byte(x))...)
+	}
+	return data[:size], nil
+}
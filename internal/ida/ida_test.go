@@ -0,0 +1,99 @@
+package ida
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDisperseReconstruct(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name    string
+		size    int
+		n, k    int
+		subsets [][]byte
+	}{
+		{name: "empty", size: 0, n: 3, k: 2, subsets: [][]byte{{1, 2}, {3, 1}}},
+		{name: "1 of 1", size: 100, n: 1, k: 1, subsets: [][]byte{{1}}},
+		{name: "1 of 3, replicated", size: 100, n: 3, k: 1, subsets: [][]byte{{1}, {2}, {3}}},
+		{name: "2 of 3", size: 1000, n: 3, k: 2, subsets: [][]byte{{1, 2}, {1, 3}, {3, 2}}},
+		{name: "3 of 5, uneven stripes", size: 1001, n: 5, k: 3,
+			subsets: [][]byte{{1, 2, 3}, {3, 4, 5}, {5, 1, 4}, {1, 2, 3, 4, 5}}},
+		{name: "smaller than threshold", size: 2, n: 6, k: 4, subsets: [][]byte{{3, 4, 5, 6}, {1, 6, 2, 5}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := make([]byte, test.size)
+			random.Read(data)
+
+			fragments, err := Disperse(data, test.n, test.k)
+			if err != nil {
+				t.Fatalf("disperse: %v", err)
+			} else if len(fragments) != test.n {
+				t.Fatalf("disperse: %d fragments, want %d", len(fragments), test.n)
+			}
+
+			for x, fragment := range fragments {
+				if len(fragment) != FragmentSize(test.size, test.k) {
+					t.Errorf("fragment %d: %d bytes, want %d", x, len(fragment), FragmentSize(test.size, test.k))
+				}
+			}
+
+			for _, subset := range test.subsets {
+				subsetFragments := make(map[byte][]byte, len(subset))
+				for _, x := range subset {
+					subsetFragments[x] = fragments[x]
+				}
+
+				reconstructed, err := Reconstruct(subsetFragments, test.k, test.size)
+				if err != nil {
+					t.Fatalf("reconstruct out of %v: %v", subset, err)
+				} else if !bytes.Equal(reconstructed, data) {
+					t.Errorf("reconstruct out of %v: data differs", subset)
+				}
+			}
+		})
+	}
+}
+
+// Fragments 1..k are the data stripes themselves.
+func TestDisperseSystematic(t *testing.T) {
+	data := []byte("abcdefghij")
+	fragments, err := Disperse(data, 4, 2)
+	if err != nil {
+		t.Fatalf("disperse: %v", err)
+	}
+
+	if !bytes.Equal(fragments[1], []byte("abcde")) || !bytes.Equal(fragments[2], []byte("fghij")) {
+		t.Errorf("stripes: %q and %q, want %q and %q", fragments[1], fragments[2], "abcde", "fghij")
+	}
+}
+
+func TestDisperseInvalidThreshold(t *testing.T) {
+	for _, nk := range [][2]int{{3, 0}, {2, 3}, {256, 2}} {
+		if _, err := Disperse([]byte("data"), nk[0], nk[1]); err != ErrInvalidThreshold {
+			t.Errorf("%d of %d: %v, want %v", nk[1], nk[0], err, ErrInvalidThreshold)
+		}
+	}
+}
+
+func TestReconstructErrors(t *testing.T) {
+	data := []byte("some data to disperse")
+	fragments, err := Disperse(data, 4, 3)
+	if err != nil {
+		t.Fatalf("disperse: %v", err)
+	}
+
+	few := map[byte][]byte{1: fragments[1], 4: fragments[4]}
+	if _, err := Reconstruct(few, 3, len(data)); err != ErrNotEnoughFragments {
+		t.Errorf("2 of 3 fragments: %v, want %v", err, ErrNotEnoughFragments)
+	}
+
+	truncated := map[byte][]byte{1: fragments[1], 2: fragments[2][1:], 3: fragments[3]}
+	if _, err := Reconstruct(truncated, 3, len(data)); err != ErrInvalidFragmentSize {
+		t.Errorf("truncated fragment: %v, want %v", err, ErrInvalidFragmentSize)
+	}
+}
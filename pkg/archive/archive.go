@@ -0,0 +1,233 @@
+// Package archive bundles a directory tree into a single tar archive, so that it can be shared as a single file, and
+// restores it. Relative paths, permissions, modification times and symlinks are preserved.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidEntryPath = errors.New("archive entry path escapes destination")
+	ErrSubPathNotFound  = errors.New("sub-path not found in archive")
+)
+
+// Packs the directory tree rooted at root into a tar archive.
+// Entries are named after their path relative to root, using forward slashes. Symlinks are archived as links, never
+// followed - except for root itself, if it is one. Entry types other than regular files, directories and symlinks are
+// skipped.
+func Pack(root string) ([]byte, error) {
+	// Note: Walk doesn't descend into a symlinked root.
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, errors.WithMessage(err, "resolve root")
+	}
+
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+
+	err = filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return errors.WithMessagef(err, "relative path of '%s'", filePath)
+		} else if relativePath == "." {
+			return nil
+		}
+
+		mode := info.Mode()
+		if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		link := ""
+		if mode&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				return errors.WithMessagef(err, "read link '%s'", filePath)
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return errors.WithMessagef(err, "archive header of '%s'", filePath)
+		}
+
+		header.Name = filepath.ToSlash(relativePath)
+		if mode.IsDir() {
+			header.Name += "/"
+		}
+		header.Uname, header.Gname = "", ""
+		header.Format = tar.FormatPAX // Keeps sub-second modification times.
+
+		if err := writer.WriteHeader(header); err != nil {
+			return errors.WithMessagef(err, "write archive header of '%s'", filePath)
+		}
+
+		if mode.IsRegular() {
+			if err := copyFile(writer, filePath); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "walk '%s'", root)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, errors.WithMessage(err, "close archive")
+	}
+	return buffer.Bytes(), nil
+}
+
+// Unpacks a tar archive packed by Pack into destination directory, creating it if needed.
+// If subPath is set, only the entries at or under it are restored (still at their relative path under destination).
+// Entries escaping destination, whether by their path or through an archived symlink, are rejected.
+func Unpack(data []byte, destination, subPath string) error {
+	subPath = strings.Trim(path.Clean("/"+filepath.ToSlash(subPath)), "/")
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return errors.WithMessagef(err, "create destination '%s'", destination)
+	}
+
+	var directories []*tar.Header
+	found := false
+
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.WithMessage(err, "read archive")
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+		if !withinSubPath(name, subPath) {
+			continue
+		}
+		found = true
+
+		target, err := entryTarget(destination, name)
+		if err != nil {
+			return errors.WithMessagef(err, "entry '%s'", header.Name)
+		}
+
+		if err := unpackEntry(reader, header, target); err != nil {
+			return errors.WithMessagef(err, "unpack entry '%s'", header.Name)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			directories = append(directories, header)
+		}
+	}
+
+	if !found && subPath != "" {
+		return errors.WithMessagef(ErrSubPathNotFound, "sub-path '%s'", subPath)
+	}
+
+	// Directories' modes and modification times are restored last, as unpacking their entries needs them writable, and
+	// updates their modification times. Children go before their parents.
+	for i := len(directories) - 1; i >= 0; i-- {
+		target := filepath.Join(destination, filepath.FromSlash(strings.TrimSuffix(directories[i].Name, "/")))
+		if err := os.Chmod(target, os.FileMode(directories[i].Mode).Perm()); err != nil {
+			return errors.WithMessagef(err, "restore mode of '%s'", target)
+		} else if err := os.Chtimes(target, directories[i].ModTime, directories[i].ModTime); err != nil {
+			return errors.WithMessagef(err, "restore modification time of '%s'", target)
+		}
+	}
+	return nil
+}
+
+func unpackEntry(reader io.Reader, header *tar.Header, target string) error {
+	mode := os.FileMode(header.Mode).Perm()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, 0700); err != nil {
+			return err
+		}
+		return os.Chmod(target, mode|0700) // Actual mode is restored once entries are unpacked (see Unpack).
+	case tar.TypeSymlink:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		} else if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		return os.Symlink(header.Linkname, target)
+	case tar.TypeReg, tar.TypeRegA:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		} else if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(file, reader); err != nil {
+			_ = file.Close()
+			return err
+		} else if err := file.Close(); err != nil {
+			return err
+		} else if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		return os.Chtimes(target, header.ModTime, header.ModTime)
+	}
+	return nil
+}
+
+// Resolves an entry's target path under destination, rejecting entries which escape it - either by their path, or by
+// going through a symlink.
+func entryTarget(destination, name string) (string, error) {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name || name == ".." ||
+		strings.HasPrefix(name, "../") {
+		return "", ErrInvalidEntryPath
+	}
+
+	parent := destination
+	components := strings.Split(name, "/")
+	for _, component := range components[:len(components)-1] {
+		parent = filepath.Join(parent, component)
+		info, err := os.Lstat(parent)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return "", err
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			return "", ErrInvalidEntryPath
+		}
+	}
+	return filepath.Join(destination, filepath.FromSlash(name)), nil
+}
+
+// Whether an entry is the sub-path, or lies under it. Every entry lies under an empty sub-path.
+func withinSubPath(name, subPath string) bool {
+	return subPath == "" || name == subPath || strings.HasPrefix(name, subPath+"/")
+}
+
+func copyFile(writer io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return errors.WithMessagef(err, "open file '%s'", filePath)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(writer, file); err != nil {
+		return errors.WithMessagef(err, "archive file '%s'", filePath)
+	}
+	return nil
+}
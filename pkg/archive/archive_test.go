@@ -0,0 +1,224 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var testModTime = time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+func tempDir(t *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "gasper-archive-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	return directory, func() { _ = os.RemoveAll(directory) }
+}
+
+// Creates a tree of files, directories and a symlink under root.
+func createTestTree(t *testing.T, root string) {
+	files := map[string]string{
+		"top.txt":         "top",
+		"docs/readme.txt": "readme",
+		"docs/sub/a.txt":  "a",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("create directory: %v", err)
+		} else if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	if err := os.Chmod(filepath.Join(root, "top.txt"), 0600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	} else if err := os.Mkdir(filepath.Join(root, "empty"), 0750); err != nil {
+		t.Fatalf("create directory: %v", err)
+	} else if err := os.Symlink("docs/readme.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	} else if err := os.Chtimes(filepath.Join(root, "docs/sub/a.txt"), testModTime, testModTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+// Lists the entries under root (files and symlinks with their content or target, directories with a slash).
+func listTree(t *testing.T, root string) map[string]string {
+	entries := make(map[string]string)
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || filePath == root {
+			return err
+		}
+
+		name, _ := filepath.Rel(root, filePath)
+		name = filepath.ToSlash(name)
+		switch {
+		case info.IsDir():
+			entries[name+"/"] = info.Mode().Perm().String()
+		case info.Mode()&os.ModeSymlink != 0:
+			entries[name], err = os.Readlink(filePath)
+		default:
+			var content []byte
+			content, err = ioutil.ReadFile(filePath)
+			entries[name] = info.Mode().Perm().String() + " " + string(content)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	return entries
+}
+
+func TestPackUnpack(t *testing.T) {
+	source, cleanup := tempDir(t)
+	defer cleanup()
+	createTestTree(t, source)
+
+	data, err := Pack(source)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	destination, cleanup := tempDir(t)
+	defer cleanup()
+
+	if err := Unpack(data, filepath.Join(destination, "restored"), ""); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+
+	restored := filepath.Join(destination, "restored")
+	if got, want := listTree(t, restored), listTree(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored tree %v, want %v", got, want)
+	}
+
+	info, err := os.Stat(filepath.Join(restored, "docs/sub/a.txt"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	} else if !info.ModTime().Equal(testModTime) {
+		t.Errorf("modification time %s, want %s", info.ModTime(), testModTime)
+	}
+}
+
+func TestUnpackSubPath(t *testing.T) {
+	source, cleanup := tempDir(t)
+	defer cleanup()
+	createTestTree(t, source)
+
+	data, err := Pack(source)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	tests := []struct {
+		subPath string
+		want    []string
+		err     error
+	}{
+		{subPath: "docs", want: []string{"docs/", "docs/readme.txt", "docs/sub/", "docs/sub/a.txt"}},
+		{subPath: "/docs/sub/", want: []string{"docs/", "docs/sub/", "docs/sub/a.txt"}},
+		{subPath: "docs/readme.txt", want: []string{"docs/", "docs/readme.txt"}},
+		{subPath: "link", want: []string{"link"}},
+		{subPath: "doc", err: ErrSubPathNotFound},
+		{subPath: "missing", err: ErrSubPathNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.subPath, func(t *testing.T) {
+			destination, cleanup := tempDir(t)
+			defer cleanup()
+
+			if err := Unpack(data, destination, test.subPath); errors.Cause(err) != test.err {
+				t.Fatalf("unpack: %v, want %v", err, test.err)
+			} else if err != nil {
+				return
+			}
+
+			names := make([]string, 0)
+			for name := range listTree(t, destination) {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("restored %v, want %v", names, test.want)
+			}
+		})
+	}
+}
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	link     string
+}
+
+// Builds an archive out of entries, as a malicious one could be.
+func buildArchive(t *testing.T, entries ...testEntry) []byte {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Linkname: entry.link, Mode: 0644}
+		content := []byte("escaped")
+		if entry.typeflag == tar.TypeReg {
+			header.Size = int64(len(content))
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatalf("write header: %v", err)
+		} else if entry.typeflag == tar.TypeReg {
+			if _, err := writer.Write(content); err != nil {
+				t.Fatalf("write content: %v", err)
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestUnpackRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+	}{
+		{name: "parent", entries: []testEntry{{name: "../escaped", typeflag: tar.TypeReg}}},
+		{name: "nested parent", entries: []testEntry{{name: "a/../../escaped", typeflag: tar.TypeReg}}},
+		{name: "absolute", entries: []testEntry{{name: "/tmp/escaped", typeflag: tar.TypeReg}}},
+		{name: "through a symlink", entries: []testEntry{
+			{name: "link", typeflag: tar.TypeSymlink, link: ".."},
+			{name: "link/escaped", typeflag: tar.TypeReg},
+		}},
+		{name: "through an absolute symlink", entries: []testEntry{
+			{name: "dir/", typeflag: tar.TypeDir},
+			{name: "dir/link", typeflag: tar.TypeSymlink, link: "/tmp"},
+			{name: "dir/link/escaped", typeflag: tar.TypeReg},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, cleanup := tempDir(t)
+			defer cleanup()
+
+			destination := filepath.Join(root, "destination")
+			err := Unpack(buildArchive(t, test.entries...), destination, "")
+			if errors.Cause(err) != ErrInvalidEntryPath {
+				t.Errorf("unpack: %v, want %v", err, ErrInvalidEntryPath)
+			}
+
+			if _, err := os.Lstat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+				t.Error("entry written outside destination")
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package catalog keeps a local record of stored files (embedded in a single bolt database file), so that their
+// checksum, shape and placement don't have to be kept track of by hand.
+package catalog
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// Catalog file, under the user's config directory.
+	defaultDirectory = "gasper"
+	defaultFilename  = "catalog.db"
+
+	// How long to wait for another process holding the catalog open.
+	openTimeout = 5 * time.Second
+)
+
+var entriesBucket = []byte("entries")
+
+var ErrEntryNotFound = errors.New("file isn't in catalog")
+
+// Entry records a stored file.
+type Entry struct {
+	FileID string `json:"file-id"`
+
+	// Path file (or directory tree) was stored from, and the logical name it was stored as, if any.
+	Path string `json:"path,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// Size of the stored file, in bytes (unknown for directory trees).
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `json:"checksum"`
+
+	Scheme             string `json:"scheme,omitempty"`
+	Content            string `json:"content,omitempty"`
+	ShareCount         byte   `json:"share-count"`
+	MinSharesThreshold byte   `json:"shares-threshold"`
+
+	// Cipher file was encrypted with before splitting (e.g. 'aes-256-gcm'), or 'none'.
+	Cipher string `json:"cipher"`
+
+	// Where file's shares were placed, as of storage or last verification. Empty if unknown.
+	Placements []*Placement `json:"placements,omitempty"`
+
+	CreatedAt time.Time `json:"created-at"`
+
+	// Last verification, if any.
+	VerifiedAt *time.Time `json:"verified-at,omitempty"`
+	Healthy    *bool      `json:"healthy,omitempty"`
+}
+
+// Placement records which store holds one of a file's shares.
+type Placement struct {
+	ShareID   string `json:"share-id"`
+	StoreType string `json:"store-type"`
+	StoreName string `json:"store-name"`
+}
+
+// Catalog is a local record of stored files, by file ID.
+type Catalog struct {
+	db *bolt.DB
+}
+
+// Returns the default catalog path, under the user's config directory.
+func DefaultPath() (string, error) {
+	configDirectory, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.WithMessage(err, "find user config directory")
+	}
+	return filepath.Join(configDirectory, defaultDirectory, defaultFilename), nil
+}
+
+// Opens the catalog at the given path, creating it (and its directory) if needed.
+func Open(path string) (*Catalog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.WithMessagef(err, "create catalog directory of '%s'", path)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "open catalog '%s'", path)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(entriesBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.WithMessagef(err, "initialize catalog '%s'", path)
+	}
+	return &Catalog{db: db}, nil
+}
+
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// Records an entry, replacing any entry of the same file ID.
+func (c *Catalog) Put(entry *Entry) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return putEntry(tx, entry)
+	})
+}
+
+// Retrieves a file's entry. Returns ErrEntryNotFound if file isn't in catalog.
+func (c *Catalog) Get(fileID string) (*Entry, error) {
+	var entry *Entry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		var err error
+		entry, err = getEntry(tx, fileID)
+		return err
+	})
+	return entry, err
+}
+
+// Deletes a file's entry. Files which aren't in catalog are ignored.
+func (c *Catalog) Delete(fileID string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).Delete([]byte(fileID))
+	})
+}
+
+// Updates a file's entry in place. Returns ErrEntryNotFound if file isn't in catalog.
+// If update changes entry's file ID, the entry is moved under the new one.
+func (c *Catalog) Update(fileID string, update func(entry *Entry)) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		entry, err := getEntry(tx, fileID)
+		if err != nil {
+			return err
+		}
+
+		update(entry)
+		if entry.FileID != fileID {
+			if err := tx.Bucket(entriesBucket).Delete([]byte(fileID)); err != nil {
+				return err
+			}
+		}
+		return putEntry(tx, entry)
+	})
+}
+
+// Lists entries, oldest first.
+func (c *Catalog) List() ([]*Entry, error) {
+	var entries []*Entry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(key, value []byte) error {
+			entry := &Entry{}
+			if err := json.Unmarshal(value, entry); err != nil {
+				return errors.WithMessagef(err, "unmarshal entry '%s'", key)
+			}
+
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// Exports every entry, as a JSON array.
+func (c *Catalog) Export(writer io.Writer) error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return errors.WithMessage(err, "encode entries")
+	}
+	return nil
+}
+
+// Imports entries exported by Export, replacing entries of the same file IDs. Returns how many were imported.
+func (c *Catalog) Import(reader io.Reader) (int, error) {
+	var entries []*Entry
+	if err := json.NewDecoder(reader).Decode(&entries); err != nil {
+		return 0, errors.WithMessage(err, "decode entries")
+	}
+
+	for _, entry := range entries {
+		if entry == nil || entry.FileID == "" {
+			return 0, errors.New("entry without a file ID")
+		}
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, entry := range entries {
+			if err := putEntry(tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func getEntry(tx *bolt.Tx, fileID string) (*Entry, error) {
+	value := tx.Bucket(entriesBucket).Get([]byte(fileID))
+	if value == nil {
+		return nil, errors.WithMessagef(ErrEntryNotFound, "file ID '%s'", fileID)
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, errors.WithMessagef(err, "unmarshal entry '%s'", fileID)
+	}
+	return entry, nil
+}
+
+func putEntry(tx *bolt.Tx, entry *Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithMessagef(err, "marshal entry '%s'", entry.FileID)
+	}
+	return tx.Bucket(entriesBucket).Put([]byte(entry.FileID), value)
+}
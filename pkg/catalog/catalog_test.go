@@ -0,0 +1,203 @@
+package catalog
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCreatedAt = time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+
+// Opens a catalog in a temporary directory, which the returned function closes and removes.
+func openTestCatalog(t *testing.T) (*Catalog, func()) {
+	directory, err := ioutil.TempDir("", "gasper-catalog-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+
+	catalog, err := Open(filepath.Join(directory, "nested", "catalog.db"))
+	if err != nil {
+		_ = os.RemoveAll(directory)
+		t.Fatalf("open catalog: %v", err)
+	}
+	return catalog, func() {
+		_ = catalog.Close()
+		_ = os.RemoveAll(directory)
+	}
+}
+
+func testEntry(fileID string, age time.Duration) *Entry {
+	healthy := true
+	verifiedAt := testCreatedAt.Add(time.Hour)
+	return &Entry{
+		FileID:             fileID,
+		Path:               "/home/user/" + fileID,
+		Size:               1024,
+		Checksum:           "checksum-" + fileID,
+		ShareCount:         3,
+		MinSharesThreshold: 2,
+		Cipher:             "aes-256-gcm",
+		Placements:         []*Placement{{ShareID: "1", StoreType: "local", StoreName: "/mnt/a"}},
+		CreatedAt:          testCreatedAt.Add(-age),
+		VerifiedAt:         &verifiedAt,
+		Healthy:            &healthy,
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	catalog, cleanup := openTestCatalog(t)
+	defer cleanup()
+
+	entry := testEntry("file", 0)
+	if err := catalog.Put(entry); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	got, err := catalog.Get("file")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	} else if !reflect.DeepEqual(got, entry) {
+		t.Errorf("got %+v, want %+v", got, entry)
+	}
+
+	// Putting again replaces the entry.
+	entry.Name = "report"
+	if err := catalog.Put(entry); err != nil {
+		t.Fatalf("put again: %v", err)
+	} else if got, err := catalog.Get("file"); err != nil || got.Name != "report" {
+		t.Errorf("got %+v (%v), want it named 'report'", got, err)
+	}
+
+	if err := catalog.Delete("file"); err != nil {
+		t.Fatalf("delete: %v", err)
+	} else if _, err := catalog.Get("file"); errors.Cause(err) != ErrEntryNotFound {
+		t.Errorf("get deleted entry: %v, want %v", err, ErrEntryNotFound)
+	} else if err := catalog.Delete("file"); err != nil {
+		t.Errorf("delete missing entry: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	catalog, cleanup := openTestCatalog(t)
+	defer cleanup()
+
+	if err := catalog.Update("missing", func(*Entry) {}); errors.Cause(err) != ErrEntryNotFound {
+		t.Errorf("update missing entry: %v, want %v", err, ErrEntryNotFound)
+	}
+
+	if err := catalog.Put(testEntry("file", 0)); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// Changing the file ID moves the entry.
+	err := catalog.Update("file", func(entry *Entry) {
+		entry.FileID, entry.ShareCount = "rekeyed", 5
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	} else if _, err := catalog.Get("file"); errors.Cause(err) != ErrEntryNotFound {
+		t.Errorf("get moved entry: %v, want %v", err, ErrEntryNotFound)
+	} else if entry, err := catalog.Get("rekeyed"); err != nil || entry.ShareCount != 5 {
+		t.Errorf("got %+v (%v), want 5 shares", entry, err)
+	}
+}
+
+func TestListExportImport(t *testing.T) {
+	catalog, cleanup := openTestCatalog(t)
+	defer cleanup()
+
+	// Listed oldest first, whatever the file IDs.
+	entries := []*Entry{testEntry("c", 3*time.Hour), testEntry("a", 2*time.Hour), testEntry("b", time.Hour)}
+	for _, entry := range entries {
+		if err := catalog.Put(entry); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+
+	listed, err := catalog.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	} else if !reflect.DeepEqual(listed, entries) {
+		t.Errorf("listed %v, want %v", listed, entries)
+	}
+
+	exported := &bytes.Buffer{}
+	if err := catalog.Export(exported); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	other, otherCleanup := openTestCatalog(t)
+	defer otherCleanup()
+
+	if err := other.Put(&Entry{FileID: "a", Checksum: "stale"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if imported, err := other.Import(exported); err != nil {
+		t.Fatalf("import: %v", err)
+	} else if imported != len(entries) {
+		t.Errorf("imported %d entries, want %d", imported, len(entries))
+	}
+
+	if listed, err := other.List(); err != nil {
+		t.Fatalf("list imported: %v", err)
+	} else if !reflect.DeepEqual(listed, entries) {
+		t.Errorf("listed imported %v, want %v", listed, entries)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	catalog, cleanup := openTestCatalog(t)
+	defer cleanup()
+
+	for _, exported := range []string{"not json", `{"file-id": "a"}`, `[{"file-id": "a"}, {"checksum": "b"}]`,
+		`[null]`} {
+		if _, err := catalog.Import(strings.NewReader(exported)); err == nil {
+			t.Errorf("imported '%s'", exported)
+		}
+	}
+
+	// Nothing is imported out of a partly invalid export.
+	if entries, err := catalog.List(); err != nil {
+		t.Fatalf("list: %v", err)
+	} else if len(entries) != 0 {
+		t.Errorf("%d entries imported", len(entries))
+	}
+}
+
+// Entries persist across reopening the catalog.
+func TestReopen(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gasper-catalog-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "catalog.db")
+	catalog, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	} else if err := catalog.Put(testEntry("file", 0)); err != nil {
+		t.Fatalf("put: %v", err)
+	} else if err := catalog.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+
+	if entry, err := reopened.Get("file"); err != nil {
+		t.Fatalf("get: %v", err)
+	} else if !reflect.DeepEqual(entry, testEntry("file", 0)) {
+		t.Errorf("got %+v after reopening", entry)
+	}
+}
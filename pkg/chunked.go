@@ -0,0 +1,425 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg/chunking"
+	metadataPkg "github.com/gasper/pkg/metadata"
+	"github.com/gasper/pkg/placement"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+const (
+	// Chunk keys are derived with this public secret when encryption is turned off (convergent encryption): identical
+	// chunks are deduplicated across every user of the stores, but anyone holding a chunk's plaintext can tell whether
+	// it is stored.
+	convergentChunkSecret = "gasper convergent chunk secret"
+
+	// Purpose label of the subkey chunk keys are derived with.
+	chunkKeyPurpose = "gasper-chunk-id"
+
+	chunkIDPrefix = "chunk-"
+
+	// Length of chunk IDs' hex-encoded digest part.
+	chunkIDDigestSize = 40
+)
+
+// ChunkIndex lists the content-defined chunks a file (or directory tree) was cut into, in order.
+// It is stored as a regular shared file, while its chunks are stored once each, however many indexes reference them.
+type ChunkIndex struct {
+	// Content type of the chunked data (see Manifest's Content).
+	Content string `json:"content,omitempty"`
+
+	Size int64 `json:"size"`
+
+	// Hex-encoded md5 checksum of the whole data.
+	Checksum string `json:"checksum"`
+
+	Chunks []*ChunkRef `json:"chunks"`
+}
+
+// ChunkRef references a stored chunk.
+type ChunkRef struct {
+	// File ID chunk is shared under.
+	ID string `json:"id"`
+
+	// Hex-encoded key chunk is encrypted with.
+	Key string `json:"key"`
+
+	Size int `json:"size"`
+}
+
+// ChunkReport describes a chunked split.
+type ChunkReport struct {
+	// How many chunks data was cut into, and how many of them (and how many bytes) had to be stored. The others were
+	// already held by the stores.
+	Chunks    int
+	NewChunks int
+	NewBytes  int64
+}
+
+// GarbageReport describes a garbage collection.
+type GarbageReport struct {
+	// How many chunk indexes were read, and how many chunks they reference.
+	Indexes          int
+	ReferencedChunks int
+
+	// IDs of chunks no index references.
+	Unreferenced []string
+
+	// How many shares of unreferenced chunks were deleted.
+	DeletedShares int
+}
+
+// Cuts data into content-defined chunks (see chunking), and stores the chunks the given stores don't hold yet, so that
+// successive backups of similar data only ship new chunks.
+// Each chunk is compressed using metadata's compression codec, encrypted with a key derived from its content and the
+// encryption salt (or a public secret when encryption is turned off), then split according to the manifest template
+// under an ID derived from its key - identical chunks thus end up under the same ID. Chunk shares go to the stores
+// placement plan tells (or to their parties' stores, under an access policy, or to the given stores in order if there's
+// no plan). A chunk the stores already list is only referenced as is if its current shares were split along the
+// template, and reach its threshold (see reusableChunk) - otherwise it's re-split.
+// Returns the chunk index's shared file, split under the given file ID according to the manifest template along with
+// file metadata, for the caller to put in stores like any other file. Its checksum is the index's own, whereas the
+// whole data's is kept in the index.
+func (g *Gasper) SplitChunked(fileID string, data []byte, fileMetadata *metadataPkg.Metadata,
+	template *sharesPkg.Manifest, stores []storesPkg.Store, plan placement.Plan,
+	params *chunking.Params) (*sharesPkg.SharedFile, *ChunkReport, []*StoreError, error) {
+	if template.MinSharesThreshold > template.ShareCount {
+		return nil, nil, nil, ErrInvalidSharesThreshold
+	}
+
+	chunks, err := chunking.Split(data, params)
+	if err != nil {
+		return nil, nil, nil, errors.WithMessage(err, "cut data into chunks")
+	}
+
+	held, storeErrors := listedFileIDs(stores)
+
+	threshold := int(template.MinSharesThreshold)
+	if template.Policy != nil {
+		threshold = template.Policy.MinParties()
+	}
+
+	codec := ""
+	if fileMetadata != nil {
+		codec = fileMetadata.Compression
+	}
+
+	checksum := md5.Sum(data)
+	index := &ChunkIndex{
+		Content:  template.Content,
+		Size:     int64(len(data)),
+		Checksum: hex.EncodeToString(checksum[:]),
+		Chunks:   make([]*ChunkRef, 0, len(chunks)),
+	}
+	report := &ChunkReport{Chunks: len(chunks)}
+
+	stored := make(map[string]bool) // Chunks stored (or found reusable) by this split.
+	for _, chunk := range chunks {
+		key := g.chunkKey(chunk)
+		ref := &ChunkRef{ID: chunkID(key), Key: hex.EncodeToString(key), Size: len(chunk)}
+		index.Chunks = append(index.Chunks, ref)
+
+		if stored[ref.ID] {
+			continue
+		}
+
+		var placements []*Placement
+		if held[ref.ID] > 0 {
+			var collectErrors []*StoreError
+			placements, collectErrors = g.CollectShares(ref.ID, stores)
+			storeErrors = append(storeErrors, collectErrors...)
+
+			if current, _ := SelectGeneration(placements); reusableChunk(current, template, threshold) {
+				stored[ref.ID] = true
+				continue
+			}
+		}
+
+		chunkStoreErrors, err := g.storeChunk(ref.ID, key, chunk, codec, template, stores, plan, placements,
+			held[ref.ID] > 0)
+		storeErrors = append(storeErrors, chunkStoreErrors...)
+		if err != nil {
+			return nil, report, storeErrors, errors.WithMessagef(err, "store chunk '%s'", ref.ID)
+		}
+
+		stored[ref.ID] = true
+		report.NewChunks++
+		report.NewBytes += int64(len(chunk))
+	}
+
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return nil, report, storeErrors, errors.WithMessage(err, "marshal chunk index")
+	}
+
+	indexTemplate := *template
+	indexTemplate.Content = sharesPkg.ContentChunkIndex
+	sharedFile, err := g.Split(fileID, indexData, fileMetadata, &indexTemplate)
+	if err != nil {
+		return nil, report, storeErrors, errors.WithMessage(err, "split chunk index")
+	}
+	return sharedFile, report, storeErrors, nil
+}
+
+// Whether a chunk already held can be referenced as is: its current generation must have been split along the
+// template's scheme, shape and access policy, and enough of its shares must be held to recover it. A chunk stored
+// under a weaker (or just different) template is re-split instead, so that it doesn't weaken the files referencing it.
+func reusableChunk(current []*Placement, template *sharesPkg.Manifest, threshold int) bool {
+	manifest := PlacementsManifest(current)
+	if manifest == nil || manifest.Content != sharesPkg.ContentChunk || len(current) < threshold {
+		return false
+	}
+
+	sameShape := manifest.SchemeOrDefault() == template.SchemeOrDefault() &&
+		manifest.ShareCount == template.ShareCount && manifest.MinSharesThreshold == template.MinSharesThreshold &&
+		reflect.DeepEqual(manifest.Policy, template.Policy)
+	return sameShape && (manifest.Policy == nil || PolicyMissing(current) == "")
+}
+
+// Encrypts, splits, and puts a single chunk in stores.
+// A chunk some stores already list (though not enough of them, or not along the template) may be referenced by older
+// indexes, and held by stores which couldn't be listed: it is then split under the generation following its collected
+// placements, so that its new shares are never mixed with its previous ones, and a failed put never rolls back the
+// stores which listed it - their previous shares were replaced already, and the new ones are left for a later split
+// (or gc) to sort out.
+func (g *Gasper) storeChunk(id string, key, chunk []byte, codec string, template *sharesPkg.Manifest,
+	stores []storesPkg.Store, plan placement.Plan, placements []*Placement, listed bool) ([]*StoreError, error) {
+	chunkGasper, err := newChunkGasper(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkTemplate := *template
+	chunkTemplate.Content = sharesPkg.ContentChunk
+	chunkTemplate.Generation = 0
+
+	kept := make(map[storesPkg.Store]bool)
+	if listed {
+		for _, store := range PlacementStores(placements) {
+			kept[store] = true
+		}
+		chunkTemplate.Generation = nextGeneration(placements)
+	}
+
+	sharedFile, err := chunkGasper.Split(id, chunk, &metadataPkg.Metadata{Compression: codec}, &chunkTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := stores
+	if sharedFile.Manifest.Policy != nil {
+		if targets, err = PolicyStores(sharedFile, stores); err != nil {
+			return nil, err
+		}
+	} else if plan != nil {
+		if targets, err = plan.Stores(sharedFile.Shares); err != nil {
+			return nil, err
+		}
+	}
+
+	_, putErrors, err := g.distributeShares(sharedFile, targets, 0, kept)
+	return putErrors, err
+}
+
+// Reassembles chunked data out of its recovered chunk index, collecting its chunks from the given stores.
+// The reassembled data is checked against the checksum kept in the index.
+func (g *Gasper) Reassemble(indexData []byte, stores []storesPkg.Store) ([]byte, *ChunkIndex, error) {
+	index := &ChunkIndex{}
+	if err := json.Unmarshal(indexData, index); err != nil {
+		return nil, nil, errors.WithMessage(err, "unmarshal chunk index")
+	}
+
+	data := make([]byte, 0, index.Size)
+	for _, ref := range index.Chunks {
+		chunk, err := g.recoverChunk(ref, stores)
+		if err != nil {
+			return nil, index, errors.WithMessagef(err, "recover chunk '%s'", ref.ID)
+		}
+		data = append(data, chunk...)
+	}
+
+	if err := g.validateChecksum(data, index.Checksum); err != nil {
+		return nil, index, err
+	}
+	return data, index, nil
+}
+
+func (g *Gasper) recoverChunk(ref *ChunkRef, stores []storesPkg.Store) ([]byte, error) {
+	key, err := hex.DecodeString(ref.Key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode chunk key")
+	}
+
+	chunkGasper, err := newChunkGasper(key)
+	if err != nil {
+		return nil, err
+	}
+
+	placements, _ := g.CollectShares(ref.ID, stores)
+	current, _ := SelectGeneration(placements)
+	recovery, err := chunkGasper.Recover(SharedFileFromPlacements(ref.ID, "", current))
+	if err != nil {
+		return nil, err
+	} else if len(recovery.Data) != ref.Size {
+		return nil, errors.Errorf("chunk size mismatch (expected: %d, got: %d)", ref.Size, len(recovery.Data))
+	}
+	return recovery.Data, nil
+}
+
+// Verifies the chunks a chunk index references are recoverable (see Verify), collecting them from the given stores.
+// Chunks already in verified are skipped, and the others are added to it, so that chunks shared by several indexes
+// are only verified once. Returns chunks' health, alongside store failures.
+func (g *Gasper) VerifyChunks(fileID, checksum string, placements []*Placement, stores []storesPkg.Store,
+	verified map[string]bool) ([]*FileHealth, []*StoreError, error) {
+	current, _ := SelectGeneration(placements)
+	recovery, err := g.Recover(SharedFileFromPlacements(fileID, checksum, current))
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "recover chunk index")
+	}
+
+	index := &ChunkIndex{}
+	if err := json.Unmarshal(recovery.Data, index); err != nil {
+		return nil, nil, errors.WithMessage(err, "unmarshal chunk index")
+	}
+
+	healths := make([]*FileHealth, 0, len(index.Chunks))
+	storeErrors := make([]*StoreError, 0)
+	for _, ref := range index.Chunks {
+		if verified[ref.ID] {
+			continue
+		}
+		verified[ref.ID] = true
+
+		key, err := hex.DecodeString(ref.Key)
+		if err != nil {
+			return healths, storeErrors, errors.WithMessagef(err, "decode key of chunk '%s'", ref.ID)
+		}
+
+		chunkGasper, err := newChunkGasper(key)
+		if err != nil {
+			return healths, storeErrors, err
+		}
+
+		chunkPlacements, collectErrors := g.CollectShares(ref.ID, stores)
+		storeErrors = append(storeErrors, collectErrors...)
+		healths = append(healths, chunkGasper.Verify(ref.ID, "", chunkPlacements, 0, 0))
+	}
+	return healths, storeErrors, nil
+}
+
+// Deletes the chunks no chunk index references from the given stores, or only lists them on a dry run.
+// Every chunk index in the stores must be readable with Gasper's encryption settings, otherwise nothing is deleted -
+// a chunk referenced only by an unreadable index would be lost. Listing failures abort as well, for the same reason.
+// Note: chunks stored by a concurrent chunked split, whose index isn't stored yet, look unreferenced.
+func (g *Gasper) CollectGarbage(stores []storesPkg.Store, dryRun bool) (*GarbageReport, []*StoreError, error) {
+	fileIDs, storeErrors := g.ListFileIDs(stores)
+	if len(storeErrors) > 0 {
+		return nil, storeErrors, errors.WithMessage(ErrIncompleteListing, "list files")
+	}
+
+	report := &GarbageReport{}
+	referenced := make(map[string]bool)
+	var chunkIDs []string
+
+	for _, fileID := range fileIDs {
+		placements, collectErrors := g.CollectShares(fileID, stores)
+		storeErrors = append(storeErrors, collectErrors...)
+
+		current, _ := SelectGeneration(placements)
+		manifest := PlacementsManifest(current)
+		if manifest == nil {
+			continue
+		} else if manifest.Content == sharesPkg.ContentChunk {
+			chunkIDs = append(chunkIDs, fileID)
+			continue
+		} else if !manifest.IsChunkIndex() {
+			continue
+		}
+
+		recovery, err := g.Recover(SharedFileFromPlacements(fileID, "", current))
+		if err != nil {
+			return nil, storeErrors, errors.WithMessagef(ErrUnreadableIndex, "index '%s': %s", fileID, err)
+		}
+
+		index := &ChunkIndex{}
+		if err := json.Unmarshal(recovery.Data, index); err != nil {
+			return nil, storeErrors, errors.WithMessagef(ErrUnreadableIndex, "index '%s': %s", fileID, err)
+		}
+
+		report.Indexes++
+		for _, ref := range index.Chunks {
+			referenced[ref.ID] = true
+		}
+	}
+	report.ReferencedChunks = len(referenced)
+
+	for _, id := range chunkIDs {
+		if referenced[id] {
+			continue
+		}
+
+		report.Unreferenced = append(report.Unreferenced, id)
+		if !dryRun {
+			deletedShares, deleteErrors := g.DeleteShares(id, stores)
+			report.DeletedShares += deletedShares
+			storeErrors = append(storeErrors, deleteErrors...)
+		}
+	}
+	return report, storeErrors, nil
+}
+
+// Derives a chunk's key from its content: keyed by a subkey of the encryption salt, identical chunks of the same user
+// get the same key (hence the same ID), while other users can't tell which chunks are stored.
+func (g *Gasper) chunkKey(chunk []byte) []byte {
+	mac := hmac.New(sha256.New, g.chunkSecret)
+	_, _ = mac.Write(chunk)
+	return mac.Sum(nil)
+}
+
+// Derives a chunk's ID from its key, so that it doesn't reveal the key.
+func chunkID(key []byte) string {
+	digest := sha256.Sum256(key)
+	return chunkIDPrefix + hex.EncodeToString(digest[:])[:chunkIDDigestSize]
+}
+
+// Returns a Gasper encrypting with a chunk's key.
+func newChunkGasper(key []byte) (*Gasper, error) {
+	return NewGasper(nil, &encryption.Settings{
+		TurnedOn: true,
+		Salt:     string(key),
+	})
+}
+
+// Counts how many of the given stores list each file ID.
+func listedFileIDs(stores []storesPkg.Store) (map[string]int, []*StoreError) {
+	listed := make(map[string]int)
+	storeErrors := make([]*StoreError, 0)
+
+	seen := make(map[storesPkg.Store]bool, len(stores))
+	for _, store := range stores {
+		if seen[store] {
+			continue
+		}
+		seen[store] = true
+
+		fileIDs, err := store.List()
+		if err != nil {
+			storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			continue
+		}
+
+		for _, fileID := range fileIDs {
+			listed[fileID]++
+		}
+	}
+	return listed, storeErrors
+}
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"github.com/gasper/pkg/chunking"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"testing"
+)
+
+var testChunkParams = &chunking.Params{MinSize: 1024, AvgSize: 4096, MaxSize: 16384}
+
+// Chunk keys are keyed by a subkey of the salt, never by the salt itself, which keys encryption.
+func TestChunkKey(t *testing.T) {
+	chunk := []byte("some chunk")
+	g := newTestGasper(t, nil, true)
+
+	key := g.chunkKey(chunk)
+	if !bytes.Equal(key, newTestGasper(t, nil, true).chunkKey(chunk)) {
+		t.Error("same chunk and salt, different keys")
+	} else if bytes.Equal(key, newTestGasper(t, nil, false).chunkKey(chunk)) {
+		t.Error("same key with and without encryption")
+	}
+
+	mac := hmac.New(sha256.New, []byte(testSalt))
+	_, _ = mac.Write(chunk)
+	if bytes.Equal(key, mac.Sum(nil)) {
+		t.Error("chunk key is keyed by the salt itself")
+	} else if bytes.Equal(g.chunkSecret, []byte(testSalt)) {
+		t.Error("chunk secret is the salt itself")
+	}
+}
+
+func TestSplitChunkedReassemble(t *testing.T) {
+	stores, cleanup := newTestStores(t, 3)
+	defer cleanup()
+
+	g := newTestGasper(t, stores, true)
+	template := &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}
+	data := testData(1, 100000)
+
+	sharedFile, report, storeErrors, err := g.SplitChunked("file", data, nil, template, stores, nil, testChunkParams)
+	if err != nil {
+		t.Fatalf("split chunked: %v", err)
+	} else if len(storeErrors) > 0 {
+		t.Fatalf("split chunked: %v", storeErrors[0])
+	} else if report.NewChunks != report.Chunks {
+		t.Errorf("%d new chunks out of %d, want all of them", report.NewChunks, report.Chunks)
+	}
+
+	if _, _, err := g.DistributeShares(sharedFile, stores, 0); err != nil {
+		t.Fatalf("distribute index: %v", err)
+	}
+
+	indexData := recoverTestData(t, g, "file", sharedFile.Checksum, stores)
+	reassembled, _, err := g.Reassemble(indexData, stores)
+	if err != nil {
+		t.Fatalf("reassemble: %v", err)
+	} else if !bytes.Equal(reassembled, data) {
+		t.Fatal("reassembled data differs")
+	}
+
+	// Storing the same data again only ships the index.
+	_, report, _, err = g.SplitChunked("file-2", data, nil, template, stores, nil, testChunkParams)
+	if err != nil {
+		t.Fatalf("split chunked again: %v", err)
+	} else if report.NewChunks != 0 {
+		t.Errorf("%d new chunks out of %d, want none", report.NewChunks, report.Chunks)
+	}
+}
+
+// Chunks held under another shape or scheme are re-split along the new template, rather than referenced as is.
+func TestSplitChunkedResplitsOtherTemplates(t *testing.T) {
+	data := testData(2, 50000)
+
+	tests := []struct {
+		name     string
+		previous *sharesPkg.Manifest
+		template *sharesPkg.Manifest
+		resplit  bool
+	}{
+		{name: "same template", previous: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2},
+			template: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}},
+		{name: "higher threshold", previous: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2},
+			template: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 3}, resplit: true},
+		{name: "fewer shares", previous: &sharesPkg.Manifest{ShareCount: 2, MinSharesThreshold: 2},
+			template: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}, resplit: true},
+		{name: "other scheme", previous: &sharesPkg.Manifest{Scheme: sharesPkg.SchemeSSMS, ShareCount: 3,
+			MinSharesThreshold: 2}, template: &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}, resplit: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stores, cleanup := newTestStores(t, 3)
+			defer cleanup()
+			g := newTestGasper(t, stores, true)
+
+			_, _, _, err := g.SplitChunked("old", data, nil, test.previous, stores, nil, testChunkParams)
+			if err != nil {
+				t.Fatalf("split chunked: %v", err)
+			}
+
+			sharedFile, report, _, err := g.SplitChunked("new", data, nil, test.template, stores, nil,
+				testChunkParams)
+			if err != nil {
+				t.Fatalf("split chunked again: %v", err)
+			} else if resplit := report.NewChunks == report.Chunks; resplit != test.resplit ||
+				(!resplit && report.NewChunks != 0) {
+				t.Fatalf("%d new chunks out of %d, want resplit: %t", report.NewChunks, report.Chunks, test.resplit)
+			}
+
+			if _, _, err := g.DistributeShares(sharedFile, stores, 0); err != nil {
+				t.Fatalf("distribute index: %v", err)
+			}
+
+			indexData := recoverTestData(t, g, "new", sharedFile.Checksum, stores)
+			reassembled, index, err := g.Reassemble(indexData, stores)
+			if err != nil {
+				t.Fatalf("reassemble: %v", err)
+			} else if !bytes.Equal(reassembled, data) {
+				t.Fatal("reassembled data differs")
+			}
+
+			placements, _ := g.CollectShares(index.Chunks[0].ID, stores)
+			current, _ := SelectGeneration(placements)
+			manifest := PlacementsManifest(current)
+			if manifest.SchemeOrDefault() != test.template.SchemeOrDefault() ||
+				manifest.MinSharesThreshold != test.template.MinSharesThreshold ||
+				manifest.ShareCount != test.template.ShareCount {
+				t.Errorf("chunk's current manifest: %s, %d of %d, want %s, %d of %d", manifest.SchemeOrDefault(),
+					manifest.MinSharesThreshold, manifest.ShareCount, test.template.SchemeOrDefault(),
+					test.template.MinSharesThreshold, test.template.ShareCount)
+			}
+		})
+	}
+}
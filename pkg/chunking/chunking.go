@@ -0,0 +1,101 @@
+// Package chunking cuts data into content-defined chunks (FastCDC), so that editing data only changes the chunks
+// around the edit, and unchanged chunks can be deduplicated across backups.
+package chunking
+
+import (
+	"github.com/pkg/errors"
+	"math/bits"
+)
+
+// Gear table seed. Changing it changes every chunk boundary, hence defeats deduplication with existing chunks.
+const gearSeed = 0x6761737065720001
+
+var gear [256]uint64
+
+func init() {
+	// SplitMix64.
+	state := uint64(gearSeed)
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// Params bounds chunk sizes. Average size must be a power of two.
+type Params struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// Default chunk sizes: 256KiB to 4MiB, 1MiB on average.
+var DefaultParams = &Params{
+	MinSize: 256 * 1024,
+	AvgSize: 1024 * 1024,
+	MaxSize: 4 * 1024 * 1024,
+}
+
+// Checks chunk sizes are sensible.
+func (p *Params) Validate() error {
+	if p.MinSize < 64 || p.MinSize > p.AvgSize || p.AvgSize > p.MaxSize {
+		return errors.New("chunk sizes must satisfy 64 <= min <= avg <= max")
+	} else if bits.OnesCount(uint(p.AvgSize)) != 1 {
+		return errors.New("average chunk size must be a power of two")
+	}
+	return nil
+}
+
+// Cuts data into content-defined chunks. Chunks are sub-slices of data.
+func Split(data []byte, params *Params) ([][]byte, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Normalized chunking: a stricter mask before the average size, and a looser one past it, so that chunk sizes
+	// concentrate around the average. Masks select top bits, which depend on the last 64 bytes.
+	avgBits := bits.TrailingZeros(uint(params.AvgSize))
+	strictMask := ^uint64(0) << uint(64-avgBits-1)
+	looseMask := ^uint64(0) << uint(64-avgBits+1)
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		size := boundary(data, params, strictMask, looseMask)
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return chunks, nil
+}
+
+// Returns the size of data's first chunk.
+func boundary(data []byte, params *Params, strictMask, looseMask uint64) int {
+	n := len(data)
+	if n <= params.MinSize {
+		return n
+	} else if n > params.MaxSize {
+		n = params.MaxSize
+	}
+
+	normal := params.AvgSize
+	if normal > n {
+		normal = n
+	}
+
+	fingerprint := uint64(0)
+	i := params.MinSize
+	for ; i < normal; i++ {
+		fingerprint = (fingerprint << 1) + gear[data[i]]
+		if fingerprint&strictMask == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fingerprint = (fingerprint << 1) + gear[data[i]]
+		if fingerprint&looseMask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
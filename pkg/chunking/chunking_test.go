@@ -0,0 +1,155 @@
+package chunking
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+var testParams = &Params{MinSize: 1024, AvgSize: 4096, MaxSize: 16384}
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestSplitCoversData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "smaller than min size", data: randomData(1, testParams.MinSize-1)},
+		{name: "random", data: randomData(2, 1<<20)},
+		{name: "zeros", data: make([]byte, 1<<18)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks, err := Split(test.data, testParams)
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+
+			if joined := bytes.Join(chunks, nil); !bytes.Equal(joined, test.data) {
+				t.Fatalf("chunks don't add up to data (%d bytes, want %d)", len(joined), len(test.data))
+			}
+
+			for i, chunk := range chunks {
+				if len(chunk) > testParams.MaxSize {
+					t.Errorf("chunk %d: %d bytes, above max size", i, len(chunk))
+				} else if len(chunk) < testParams.MinSize && i != len(chunks)-1 {
+					t.Errorf("chunk %d: %d bytes, below min size", i, len(chunk))
+				}
+			}
+		})
+	}
+}
+
+func TestSplitAverageSize(t *testing.T) {
+	data := randomData(3, 4<<20)
+	chunks, err := Split(data, testParams)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	// Normalized chunking keeps the average around the target, though not exactly on it.
+	average := len(data) / len(chunks)
+	if average < testParams.AvgSize/2 || average > testParams.AvgSize*2 {
+		t.Errorf("average chunk size: %d bytes, want about %d", average, testParams.AvgSize)
+	}
+}
+
+// Boundaries only depend on content: editing data only changes the chunks around the edit.
+func TestSplitBoundaryStability(t *testing.T) {
+	data := randomData(4, 1<<20)
+	edit := len(data) / 2
+
+	tests := []struct {
+		name   string
+		edited []byte
+	}{
+		{name: "insertion", edited: append(append(append([]byte{}, data[:edit]...), []byte("inserted")...),
+			data[edit:]...)},
+		{name: "deletion", edited: append(append([]byte{}, data[:edit]...), data[edit+100:]...)},
+		{name: "overwrite", edited: append(append(append([]byte{}, data[:edit]...), make([]byte, 10)...),
+			data[edit+10:]...)},
+		{name: "prepend", edited: append([]byte("header"), data...)},
+	}
+
+	chunks, err := Split(data, testParams)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	known := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		known[string(chunk)] = true
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			editedChunks, err := Split(test.edited, testParams)
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+
+			changed := 0
+			for _, chunk := range editedChunks {
+				if !known[string(chunk)] {
+					changed++
+				}
+			}
+
+			// A single edit changes the chunk holding it, and maybe a few more until boundaries line up again.
+			if changed > 3 {
+				t.Errorf("%d of %d chunks changed, want at most 3", changed, len(editedChunks))
+			}
+		})
+	}
+}
+
+func TestSplitDeterministic(t *testing.T) {
+	data := randomData(5, 1<<19)
+	first, err := Split(data, testParams)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	second, err := Split(append([]byte{}, data...), testParams)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	} else if len(first) != len(second) {
+		t.Fatalf("%d chunks, then %d", len(first), len(second))
+	}
+
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("chunk %d differs", i)
+		}
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *Params
+		valid  bool
+	}{
+		{name: "default", params: DefaultParams, valid: true},
+		{name: "equal sizes", params: &Params{MinSize: 4096, AvgSize: 4096, MaxSize: 4096}, valid: true},
+		{name: "min too small", params: &Params{MinSize: 32, AvgSize: 4096, MaxSize: 16384}},
+		{name: "min above avg", params: &Params{MinSize: 8192, AvgSize: 4096, MaxSize: 16384}},
+		{name: "avg above max", params: &Params{MinSize: 1024, AvgSize: 32768, MaxSize: 16384}},
+		{name: "avg not a power of two", params: &Params{MinSize: 1024, AvgSize: 5000, MaxSize: 16384}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.params.Validate(); (err == nil) != test.valid {
+				t.Errorf("validate: %v, want valid: %t", err, test.valid)
+			}
+		})
+	}
+}
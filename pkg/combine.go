@@ -0,0 +1,273 @@
+package pkg
+
+import (
+	"github.com/gasper/internal/gf256"
+	metadataPkg "github.com/gasper/pkg/metadata"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+)
+
+// Upper bound on share subsets tried while looking for a consistent combination.
+const maxCombineAttempts = 10000
+
+// Recovery describes a shared file's data recovered in memory.
+type Recovery struct {
+	Data []byte
+
+	// File's metadata, if it was shared along with its data.
+	Metadata *metadataPkg.Metadata
+
+	// Shares the data was recovered from (or, if recovery failed, the ones which passed integrity checks).
+	Used []*sharesPkg.Share
+
+	// Shares excluded for returning bad data.
+	Bad []*sharesPkg.Share
+}
+
+// Recovers a shared file's original data in memory, tolerating bad shares.
+// Shares failing their integrity tag (committed in the manifest agreed upon by most shares) are excluded first, along
+// with shares of an unexpected size or ID. Under verifiable secret sharing, shares inconsistent with the commitments
+// are excluded as well. Under an access policy, good shares must satisfy the policy. Otherwise, when more shares than
+// the minimum threshold remain but are inconsistent with each other, subsets of them are tried until a combination
+// which decrypts, matches the checksum (if set), and agrees with most other shares is found. Shares disagreeing with it
+// are excluded as well.
+// On failure, the returned recovery (if any) still tells which shares were found bad.
+func (g *Gasper) Recover(sharedFile *sharesPkg.SharedFile) (*Recovery, error) {
+	if sharedFile == nil {
+		return nil, ErrNilSharedFile
+	} else if len(sharedFile.Shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	for _, share := range sharedFile.Shares {
+		if share.Generation() != sharedFile.Shares[0].Generation() {
+			return nil, ErrMixedGenerations
+		}
+	}
+
+	recovery := &Recovery{}
+	manifest := sharesPkg.ConsensusManifest(sharedFile.Shares)
+	if manifest != nil && manifest.SchemeOrDefault() == sharesPkg.SchemePolicy {
+		payload, err := recoverPolicy(sharedFile.Shares, manifest, recovery)
+		if err != nil {
+			return recovery, err
+		}
+
+		recovery.Data, recovery.Metadata, err = g.decode(payload, sharedFile.Checksum)
+		return recovery, err
+	}
+
+	points := checkShares(sharedFile.Shares, manifest, recovery)
+
+	threshold := len(points)
+	if manifest != nil {
+		threshold = int(manifest.MinSharesThreshold)
+	}
+
+	if len(points) < threshold || len(points) == 0 {
+		return recovery, ErrNotEnoughShares
+	}
+
+	if manifest != nil && manifest.SchemeOrDefault() == sharesPkg.SchemeVSS {
+		payload, err := recoverVerifiable(manifest, recovery)
+		if err != nil {
+			return recovery, err
+		}
+
+		recovery.Data, recovery.Metadata, err = g.decode(payload, sharedFile.Checksum)
+		return recovery, err
+	}
+
+	xs := make([]byte, 0, len(points))
+	for x := range points {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	// Fast path: all shares lie on the same polynomial.
+	agreeing := agreeingShares(points, xs[:threshold])
+	if len(agreeing) == len(points) {
+		data, fileMetadata, err := g.combinePoints(manifest, points, xs[:threshold], sharedFile.Checksum)
+		if err != nil {
+			return recovery, err
+		}
+
+		recovery.Data, recovery.Metadata = data, fileMetadata
+		return recovery, nil
+	}
+
+	var (
+		bestData     []byte
+		bestMetadata *metadataPkg.Metadata
+		bestAgreeing []byte
+		lastErr      error
+	)
+
+	attempts := 0
+	forEachSubset(xs, threshold, func(subset []byte) bool {
+		attempts++
+
+		agreeing := agreeingShares(points, subset)
+		if len(agreeing) <= len(bestAgreeing) {
+			return attempts < maxCombineAttempts
+		}
+
+		data, fileMetadata, err := g.combinePoints(manifest, points, subset, sharedFile.Checksum)
+		if err != nil {
+			lastErr = err
+			return attempts < maxCombineAttempts
+		}
+
+		bestData, bestMetadata, bestAgreeing = data, fileMetadata, agreeing
+		return len(bestAgreeing) < len(points) && attempts < maxCombineAttempts
+	})
+
+	if bestData == nil {
+		if lastErr == nil {
+			lastErr = ErrNotEnoughShares
+		}
+		return recovery, errors.WithMessage(lastErr, "no consistent combination of shares")
+	}
+
+	agreeingSet := make(map[byte]bool, len(bestAgreeing))
+	for _, x := range bestAgreeing {
+		agreeingSet[x] = true
+	}
+
+	used := make([]*sharesPkg.Share, 0, len(bestAgreeing))
+	for _, share := range recovery.Used {
+		x, _ := strconv.Atoi(share.ID)
+		if agreeingSet[byte(x)] {
+			used = append(used, share)
+		} else {
+			recovery.Bad = append(recovery.Bad, share)
+		}
+	}
+
+	recovery.Data, recovery.Metadata, recovery.Used = bestData, bestMetadata, used
+	return recovery, nil
+}
+
+// Checks each share on its own: its ID must be a valid (and unique) share ID, its size must be the most common one,
+// and its data must match its integrity tag. Good shares are added to recovery's used shares, and bad ones to its bad
+// shares. Returns good shares' points, by x-coordinate.
+func checkShares(shares []*sharesPkg.Share, manifest *sharesPkg.Manifest,
+	recovery *Recovery) map[byte]*sharesPkg.Share {
+	sizes := make(map[int]int, len(shares))
+	commonSize, commonSizeCount := 0, 0
+	for _, share := range shares {
+		size := len(share.Data)
+		sizes[size]++
+		if sizes[size] > commonSizeCount {
+			commonSize, commonSizeCount = size, sizes[size]
+		}
+	}
+
+	points := make(map[byte]*sharesPkg.Share, len(shares))
+	for _, share := range shares {
+		x, err := strconv.Atoi(share.ID)
+
+		good := err == nil && x >= 1 && x <= 255 && points[byte(x)] == nil && len(share.Data) == commonSize
+		if good && manifest != nil {
+			good = share.Manifest != nil && manifest.CheckShare(share)
+		}
+
+		if !good {
+			recovery.Bad = append(recovery.Bad, share)
+			continue
+		}
+
+		points[byte(x)] = share
+		recovery.Used = append(recovery.Used, share)
+	}
+	return points
+}
+
+// Returns the x-coordinates of the points which lie on the polynomial passing through the subset's points.
+func agreeingShares(points map[byte]*sharesPkg.Share, subset []byte) []byte {
+	inSubset := make(map[byte]bool, len(subset))
+	for _, x := range subset {
+		inSubset[x] = true
+	}
+
+	agreeing := append([]byte{}, subset...)
+	for x, share := range points {
+		if inSubset[x] {
+			continue
+		}
+
+		weights := gf256.LagrangeWeights(subset, x)
+		agrees := true
+		for i := 0; i < len(share.Data) && agrees; i++ {
+			var y byte
+			for j, subsetX := range subset {
+				y ^= gf256.Mul(weights[j], points[subsetX].Data[i])
+			}
+			agrees = y == share.Data[i]
+		}
+
+		if agrees {
+			agreeing = append(agreeing, x)
+		}
+	}
+	return agreeing
+}
+
+// Combines the subset's points back into the original data, according to manifest's scheme (if any), and decodes it.
+func (g *Gasper) combinePoints(manifest *sharesPkg.Manifest, points map[byte]*sharesPkg.Share, subset []byte,
+	checksum string) ([]byte, *metadataPkg.Metadata, error) {
+	rawShares := make(map[byte][]byte, len(subset))
+	for _, x := range subset {
+		rawShares[x] = points[x].Data
+	}
+
+	if manifest == nil || manifest.SchemeOrDefault() == sharesPkg.SchemeShamir {
+		payload, err := combineSalted(rawShares, manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return g.decode(payload, checksum)
+	} else if manifest.SchemeOrDefault() != sharesPkg.SchemeSSMS {
+		return nil, nil, errors.Errorf("unsupported scheme '%s'", manifest.Scheme)
+	}
+
+	payload, err := combineDispersed(rawShares, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g.decode(payload, checksum)
+}
+
+// Calls fn with each k-sized subset of xs, in lexicographic order, for as long as it returns true.
+func forEachSubset(xs []byte, k int, fn func(subset []byte) bool) {
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	subset := make([]byte, k)
+	for {
+		for i, index := range indexes {
+			subset[i] = xs[index]
+		}
+
+		if !fn(subset) {
+			return
+		}
+
+		i := k - 1
+		for i >= 0 && indexes[i] == len(xs)-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+
+		indexes[i]++
+		for j := i + 1; j < k; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"bytes"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Returns a shared file's shares by ID.
+func sharesByID(sharedFile *sharesPkg.SharedFile) map[string]*sharesPkg.Share {
+	byID := make(map[string]*sharesPkg.Share, len(sharedFile.Shares))
+	for _, share := range sharedFile.Shares {
+		byID[share.ID] = share
+	}
+	return byID
+}
+
+// Picks shares by ID.
+func pickShares(byID map[string]*sharesPkg.Share, ids ...string) []*sharesPkg.Share {
+	picked := make([]*sharesPkg.Share, 0, len(ids))
+	for _, id := range ids {
+		picked = append(picked, byID[id])
+	}
+	return picked
+}
+
+func sortedShareIDs(shares []*sharesPkg.Share) []string {
+	ids := make([]string, 0, len(shares))
+	for _, share := range shares {
+		ids = append(ids, share.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Flips a byte of a share's data, leaving its integrity tag as is.
+func corruptShare(share *sharesPkg.Share) {
+	share.Data = append([]byte{}, share.Data...)
+	share.Data[len(share.Data)/2] ^= 0xff
+}
+
+// Flips a byte of a share's data, along with its tag in its own manifest copy.
+func forgeShare(share *sharesPkg.Share) {
+	corruptShare(share)
+
+	manifest := *share.Manifest
+	manifest.ShareDigests = make(map[string]string, len(share.Manifest.ShareDigests))
+	for id, digest := range share.Manifest.ShareDigests {
+		manifest.ShareDigests[id] = digest
+	}
+	manifest.ShareDigests[share.ID] = sharesPkg.ShareDigest(share.Data)
+	share.Manifest = &manifest
+}
+
+// Drops the integrity tags of every share, as for shares stored before tags were introduced.
+func untagShares(byID map[string]*sharesPkg.Share) {
+	var manifest sharesPkg.Manifest
+	for _, share := range byID {
+		manifest = *share.Manifest
+		break
+	}
+
+	manifest.ShareDigests = nil
+	for _, share := range byID {
+		share.Manifest = &manifest
+	}
+}
+
+func TestRecoverSubsets(t *testing.T) {
+	data := testData(2, 5000)
+
+	tests := []struct {
+		name string
+		ids  []string
+		err  error
+	}{
+		{name: "every share", ids: []string{"1", "2", "3", "4", "5"}},
+		{name: "threshold", ids: []string{"1", "2", "3"}},
+		{name: "other threshold", ids: []string{"5", "3", "4"}},
+		{name: "above threshold", ids: []string{"2", "4", "1", "5"}},
+		{name: "below threshold", ids: []string{"1", "4"}, err: ErrNotEnoughShares},
+	}
+
+	for _, scheme := range []string{sharesPkg.SchemeShamir, sharesPkg.SchemeVSS, sharesPkg.SchemeSSMS} {
+		for _, test := range tests {
+			t.Run(scheme+" "+test.name, func(t *testing.T) {
+				g := newTestGasper(t, nil, true)
+				sharedFile := splitTestData(t, g, "file", data, scheme, 5, 3)
+				sharedFile.Shares = pickShares(sharesByID(sharedFile), test.ids...)
+
+				recovery, err := g.Recover(sharedFile)
+				if errors.Cause(err) != test.err {
+					t.Fatalf("recover: %v, want %v", err, test.err)
+				} else if err != nil {
+					return
+				}
+
+				if !bytes.Equal(recovery.Data, data) {
+					t.Error("recovered data differs")
+				} else if len(recovery.Used) != len(test.ids) || len(recovery.Bad) != 0 {
+					t.Errorf("%d shares used and %d bad, want %d used", len(recovery.Used), len(recovery.Bad),
+						len(test.ids))
+				}
+			})
+		}
+	}
+}
+
+func TestRecoverBadShares(t *testing.T) {
+	data := testData(3, 5000)
+
+	tests := []struct {
+		name   string
+		tamper func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share
+		bad    []string
+		err    error
+	}{
+		{
+			name: "failing its tag",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				corruptShare(byID["2"])
+				return pickShares(byID, "1", "2", "3", "4", "5")
+			},
+			bad: []string{"2"},
+		},
+		{
+			name: "with a forged manifest copy",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				forgeShare(byID["4"])
+				return pickShares(byID, "1", "2", "3", "4", "5")
+			},
+			bad: []string{"4"},
+		},
+		{
+			name: "untagged, told apart by subset combining",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				untagShares(byID)
+				corruptShare(byID["3"])
+				return pickShares(byID, "1", "2", "3", "4", "5")
+			},
+			bad: []string{"3"},
+		},
+		{
+			name: "truncated",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				byID["5"].Data = byID["5"].Data[1:]
+				return pickShares(byID, "1", "2", "3", "4", "5")
+			},
+			bad: []string{"5"},
+		},
+		{
+			name: "duplicate ID",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				duplicate := *byID["1"]
+				return append(pickShares(byID, "1", "2", "3"), &duplicate)
+			},
+			bad: []string{"1"},
+		},
+		{
+			name: "invalid ID",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				byID["2"].ID = "two"
+				return pickShares(byID, "1", "2", "3", "4")
+			},
+			bad: []string{"two"},
+		},
+		{
+			name: "too many bad",
+			tamper: func(byID map[string]*sharesPkg.Share) []*sharesPkg.Share {
+				corruptShare(byID["1"])
+				forgeShare(byID["2"])
+				corruptShare(byID["3"])
+				return pickShares(byID, "1", "2", "3", "4", "5")
+			},
+			bad: []string{"1", "2", "3"},
+			err: ErrNotEnoughShares,
+		},
+	}
+
+	for _, scheme := range []string{sharesPkg.SchemeShamir, sharesPkg.SchemeSSMS} {
+		for _, test := range tests {
+			t.Run(scheme+" "+test.name, func(t *testing.T) {
+				g := newTestGasper(t, nil, true)
+				sharedFile := splitTestData(t, g, "file", data, scheme, 5, 3)
+				sharedFile.Shares = test.tamper(sharesByID(sharedFile))
+
+				recovery, err := g.Recover(sharedFile)
+				if errors.Cause(err) != test.err {
+					t.Fatalf("recover: %v, want %v", err, test.err)
+				} else if err == nil && !bytes.Equal(recovery.Data, data) {
+					t.Error("recovered data differs")
+				}
+
+				if bad := sortedShareIDs(recovery.Bad); !reflect.DeepEqual(bad, test.bad) {
+					t.Errorf("bad shares: %v, want %v", bad, test.bad)
+				}
+			})
+		}
+	}
+}
+
+// Under verifiable secret sharing, shares are checked against the commitments rather than combined in subsets.
+func TestRecoverVerifiableBadShares(t *testing.T) {
+	data := testData(4, 5000)
+	g := newTestGasper(t, nil, true)
+	sharedFile := splitTestData(t, g, "file", data, sharesPkg.SchemeVSS, 5, 3)
+
+	byID := sharesByID(sharedFile)
+	forgeShare(byID["1"])
+	corruptShare(byID["5"])
+
+	recovery, err := g.Recover(sharedFile)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	} else if !bytes.Equal(recovery.Data, data) {
+		t.Error("recovered data differs")
+	}
+
+	if bad := sortedShareIDs(recovery.Bad); !reflect.DeepEqual(bad, []string{"1", "5"}) {
+		t.Errorf("bad shares: %v, want [1 5]", bad)
+	}
+}
+
+// Tags are salted by the shares themselves: splitting the same data twice yields different tags.
+func TestShareDigestsSalted(t *testing.T) {
+	data := []byte("low entropy")
+	for _, scheme := range []string{sharesPkg.SchemeShamir, sharesPkg.SchemeVSS, sharesPkg.SchemeSSMS} {
+		g := newTestGasper(t, nil, false)
+		first := splitTestData(t, g, "file", data, scheme, 3, 2)
+		second := splitTestData(t, g, "file", data, scheme, 3, 2)
+
+		for id, digest := range first.Manifest.ShareDigests {
+			if second.Manifest.ShareDigests[id] == digest {
+				t.Errorf("%s: share %s has the same tag twice", scheme, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package compression compresses data before it is encrypted and split, so that compressible files (e.g. logs, SQL
+// dumps) take less space on every store.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"io/ioutil"
+)
+
+// Codecs.
+const (
+	CodecNone = "none"
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+
+	// Compresses using zstd, unless data looks already compressed (or otherwise incompressible).
+	CodecAuto = "auto"
+)
+
+const (
+	// Size of the sample auto mode tries compressing.
+	autoSampleSize = 64 * 1024
+
+	// Auto mode skips compression unless the sample shrinks to at most this ratio of its size.
+	autoMaxRatio = 0.9
+)
+
+var ErrUnsupportedCodec = errors.New("unsupported compression codec")
+
+// Checks codec is supported. Empty means CodecNone.
+func Validate(codec string) error {
+	switch codec {
+	case "", CodecNone, CodecGzip, CodecZstd, CodecAuto:
+		return nil
+	}
+	return errors.WithMessagef(ErrUnsupportedCodec, "codec '%s'", codec)
+}
+
+// Compresses data using the given codec. Auto mode is resolved into an actual codec first.
+// Returns the codec data was actually compressed with (CodecNone if it wasn't), alongside compressed data.
+func Compress(codec string, data []byte) (string, []byte, error) {
+	if codec == CodecAuto {
+		codec = autoCodec(data)
+	}
+
+	switch codec {
+	case "", CodecNone:
+		return CodecNone, data, nil
+	case CodecGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(data); err != nil {
+			return "", nil, errors.WithMessage(err, "gzip data")
+		} else if err := writer.Close(); err != nil {
+			return "", nil, errors.WithMessage(err, "gzip data")
+		}
+		return CodecGzip, buffer.Bytes(), nil
+	case CodecZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return "", nil, errors.WithMessage(err, "initialize zstd encoder")
+		}
+		defer encoder.Close()
+		return CodecZstd, encoder.EncodeAll(data, nil), nil
+	}
+	return "", nil, errors.WithMessagef(ErrUnsupportedCodec, "codec '%s'", codec)
+}
+
+// Decompresses data compressed using the given codec.
+func Decompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case "", CodecNone:
+		return data, nil
+	case CodecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.WithMessage(err, "initialize gzip reader")
+		}
+		defer reader.Close()
+
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, errors.WithMessage(err, "gunzip data")
+		}
+		return decompressed, nil
+	case CodecZstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, errors.WithMessage(err, "initialize zstd decoder")
+		}
+		defer decoder.Close()
+
+		decompressed, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, errors.WithMessage(err, "zstd decompress data")
+		}
+		return decompressed, nil
+	}
+	return nil, errors.WithMessagef(ErrUnsupportedCodec, "codec '%s'", codec)
+}
+
+// Picks zstd if a sample of data compresses well enough, so already compressed data (archives, media, encrypted
+// files) isn't compressed again for nothing.
+func autoCodec(data []byte) string {
+	sample := data
+	if len(sample) > autoSampleSize {
+		sample = sample[:autoSampleSize]
+	}
+
+	if len(sample) == 0 {
+		return CodecNone
+	}
+
+	_, compressed, err := Compress(CodecZstd, sample)
+	if err != nil || float64(len(compressed)) > autoMaxRatio*float64(len(sample)) {
+		return CodecNone
+	}
+	return CodecZstd
+}
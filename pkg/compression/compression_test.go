@@ -0,0 +1,79 @@
+package compression
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+var compressibleData = bytes.Repeat([]byte("2026-01-02T03:04:05Z INFO request served in 12ms\n"), 5000)
+
+func TestCompressDecompress(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  string
+		data   []byte
+		actual string
+		shrink bool
+	}{
+		{name: "none", codec: CodecNone, data: compressibleData, actual: CodecNone},
+		{name: "empty codec", codec: "", data: compressibleData, actual: CodecNone},
+		{name: "gzip", codec: CodecGzip, data: compressibleData, actual: CodecGzip, shrink: true},
+		{name: "zstd", codec: CodecZstd, data: compressibleData, actual: CodecZstd, shrink: true},
+		{name: "zstd, empty data", codec: CodecZstd, data: []byte{}, actual: CodecZstd},
+		{name: "auto, compressible", codec: CodecAuto, data: compressibleData, actual: CodecZstd, shrink: true},
+		{name: "auto, random", codec: CodecAuto, data: randomData(1, 100000), actual: CodecNone},
+		{name: "auto, empty data", codec: CodecAuto, data: []byte{}, actual: CodecNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codec, compressed, err := Compress(test.codec, test.data)
+			if err != nil {
+				t.Fatalf("compress: %v", err)
+			} else if codec != test.actual {
+				t.Errorf("compressed with '%s', want '%s'", codec, test.actual)
+			} else if shrunk := len(compressed) < len(test.data)/2; shrunk != test.shrink {
+				t.Errorf("compressed %d bytes into %d", len(test.data), len(compressed))
+			}
+
+			decompressed, err := Decompress(codec, compressed)
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			} else if !bytes.Equal(decompressed, test.data) {
+				t.Error("decompressed data differs")
+			}
+		})
+	}
+}
+
+func TestUnsupportedCodec(t *testing.T) {
+	if err := Validate("lz4"); errors.Cause(err) != ErrUnsupportedCodec {
+		t.Errorf("validate: %v, want %v", err, ErrUnsupportedCodec)
+	} else if _, _, err := Compress("lz4", compressibleData); errors.Cause(err) != ErrUnsupportedCodec {
+		t.Errorf("compress: %v, want %v", err, ErrUnsupportedCodec)
+	} else if _, err := Decompress("lz4", compressibleData); errors.Cause(err) != ErrUnsupportedCodec {
+		t.Errorf("decompress: %v, want %v", err, ErrUnsupportedCodec)
+	}
+
+	for _, codec := range []string{"", CodecNone, CodecGzip, CodecZstd, CodecAuto} {
+		if err := Validate(codec); err != nil {
+			t.Errorf("validate '%s': %v", codec, err)
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	for _, codec := range []string{CodecGzip, CodecZstd} {
+		if _, err := Decompress(codec, []byte("not compressed")); err == nil {
+			t.Errorf("%s: decompressed corrupt data", codec)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	metadataPkg "github.com/gasper/pkg/metadata"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+// DiscoveredFile describes a file found by scanning stores.
+type DiscoveredFile struct {
+	FileID string
+
+	// Manifest agreed upon by the current generation's shares, or nil if they hold none.
+	Manifest *sharesPkg.Manifest
+
+	// Placements of the current generation's shares, and of stale ones.
+	Current []*Placement
+	Stale   []*Placement
+
+	// Distinct current shares passing their integrity tag, and how many are required to recover the file (0 if
+	// unknown, for shares stored without a manifest).
+	Reachable int
+	Required  int
+
+	// Set when recovery was requested: file's checksum, size (0 for directory trees), metadata, and history if it
+	// is a named object's one - or why it couldn't be recovered.
+	Checksum   string
+	Size       int64
+	Metadata   *metadataPkg.Metadata
+	History    *History
+	RecoverErr error
+}
+
+// Whether enough shares are reachable to recover the file. Under an access policy, reachable shares' parties must
+// satisfy it.
+func (df *DiscoveredFile) Recoverable() bool {
+	if df.Manifest != nil && df.Manifest.Policy != nil {
+		present := make(map[string]bool, len(df.Current))
+		for _, placement := range df.Current {
+			if df.Manifest.CheckShare(placement.Share) {
+				present[df.Manifest.Party(placement.Share.ID)] = true
+			}
+		}
+		return df.Manifest.Policy.Satisfied(present)
+	}
+	return df.Required > 0 && df.Reachable >= df.Required
+}
+
+// StrayShare is an entry of a store which looks like a share, but can't be read back as one (e.g. a truncated or
+// foreign file, or a leftover temporary file).
+type StrayShare struct {
+	Store storesPkg.Store
+
+	// File ID the store lists it under, or its entry name if store doesn't list it at all.
+	Name string
+	Err  error
+}
+
+// DiscoveryReport describes the files found by scanning stores.
+type DiscoveryReport struct {
+	Files  []*DiscoveredFile
+	Strays []*StrayShare
+}
+
+// Scans the given stores for every file they hold a share of, grouping shares by file ID and generation, and counting
+// how many are reachable versus required. Stores implementing StrayLister are also scanned for stray entries.
+// If recover is set, each recoverable file (except chunks) is also recovered in memory to compute its checksum, which
+// shares don't hold - this requires Gasper's decryption settings to match.
+// Returns a discovery report, alongside listing failures.
+func (g *Gasper) Discover(stores []storesPkg.Store, recover bool) (*DiscoveryReport, []*StoreError) {
+	fileIDs, storeErrors := g.ListFileIDs(stores)
+
+	report := &DiscoveryReport{}
+	for _, store := range stores {
+		strayLister, ok := store.(storesPkg.StrayLister)
+		if !ok {
+			continue
+		}
+
+		names, err := strayLister.ListStrays()
+		if err != nil {
+			storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			continue
+		}
+
+		for _, name := range names {
+			report.Strays = append(report.Strays, &StrayShare{Store: store, Name: name, Err: ErrNotAShare})
+		}
+	}
+
+	for _, fileID := range fileIDs {
+		placements := make([]*Placement, 0, len(stores))
+		for _, store := range stores {
+			share, err := store.Get(fileID)
+			if err == storesPkg.ErrShareNotExists {
+				continue
+			} else if err != nil {
+				report.Strays = append(report.Strays, &StrayShare{Store: store, Name: fileID, Err: err})
+				continue
+			}
+
+			placements = append(placements, &Placement{Store: store, Share: share})
+		}
+
+		if len(placements) == 0 {
+			continue
+		}
+
+		file := &DiscoveredFile{FileID: fileID}
+		file.Current, file.Stale = SelectGeneration(placements)
+		file.Manifest = PlacementsManifest(file.Current)
+
+		reachable := make(map[string]bool, len(file.Current))
+		for _, placement := range file.Current {
+			if file.Manifest == nil || file.Manifest.CheckShare(placement.Share) {
+				reachable[placement.Share.ID] = true
+			}
+		}
+		file.Reachable = len(reachable)
+		if file.Manifest != nil {
+			file.Required = int(file.Manifest.MinSharesThreshold)
+		}
+
+		// Chunks are encrypted under their own key, and checked through their index instead.
+		if recover && file.Recoverable() && file.Manifest.Content != sharesPkg.ContentChunk {
+			file.RecoverErr = g.recoverDiscovered(file)
+		}
+
+		report.Files = append(report.Files, file)
+	}
+	return report, storeErrors
+}
+
+// Recovers a discovered file in memory, filling its checksum, size, metadata and history.
+func (g *Gasper) recoverDiscovered(file *DiscoveredFile) error {
+	recovery, err := g.Recover(SharedFileFromPlacements(file.FileID, "", file.Current))
+	if err != nil {
+		return err
+	}
+
+	checksum := md5.Sum(recovery.Data)
+	file.Checksum = hex.EncodeToString(checksum[:])
+	file.Metadata = recovery.Metadata
+
+	switch file.Manifest.Content {
+	case sharesPkg.ContentDirectory:
+	case sharesPkg.ContentChunkIndex:
+		index := &ChunkIndex{}
+		if err := json.Unmarshal(recovery.Data, index); err != nil {
+			return errors.WithMessage(err, "unmarshal chunk index")
+		} else if index.Content != sharesPkg.ContentDirectory {
+			file.Size = index.Size
+		}
+	case sharesPkg.ContentHistory:
+		file.History = &History{}
+		if err := json.Unmarshal(recovery.Data, file.History); err != nil {
+			return errors.WithMessage(err, "unmarshal history")
+		}
+	default:
+		file.Size = int64(len(recovery.Data))
+	}
+	return nil
+}
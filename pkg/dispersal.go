@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"github.com/codahale/sss"
+	"github.com/gasper/internal/ida"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+)
+
+// Splits payload using secret sharing made short: payload is encrypted with a random key, the ciphertext is dispersed
+// into fragments of about 1/threshold of its size, and the key is split using Shamir's secret sharing. Each share
+// holds a key share followed by a fragment. Ciphertext size is set in manifest.
+// Note: both key shares and fragments are evaluations of polynomials over GF(2^8) of the same degree, so such shares
+// can be checked against each other, and regenerated, just like plain Shamir shares.
+func splitDispersed(payload []byte, manifest *sharesPkg.Manifest) (map[byte][]byte, error) {
+	key, ciphertext, err := encryptWithRandomKey(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	keyShares, err := sss.Split(manifest.ShareCount, manifest.MinSharesThreshold, key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "split key")
+	}
+
+	fragments, err := ida.Disperse(ciphertext, int(manifest.ShareCount), int(manifest.MinSharesThreshold))
+	if err != nil {
+		return nil, errors.WithMessage(err, "disperse ciphertext")
+	}
+
+	manifest.CiphertextSize = len(ciphertext)
+
+	sharesBytes := make(map[byte][]byte, len(keyShares))
+	for x, keyShare := range keyShares {
+		sharesBytes[x] = append(append(make([]byte, 0, len(keyShare)+len(fragments[x])), keyShare...),
+			fragments[x]...)
+	}
+	return sharesBytes, nil
+}
+
+// Combines shares split using secret sharing made short back into the payload.
+func combineDispersed(rawShares map[byte][]byte, manifest *sharesPkg.Manifest) ([]byte, error) {
+	keyShares := make(map[byte][]byte, len(rawShares))
+	fragments := make(map[byte][]byte, len(rawShares))
+	for x, data := range rawShares {
+		if len(data) < randomKeySize {
+			return nil, errors.New("share is too short")
+		}
+		keyShares[x], fragments[x] = data[:randomKeySize], data[randomKeySize:]
+	}
+
+	ciphertext, err := ida.Reconstruct(fragments, int(manifest.MinSharesThreshold), manifest.CiphertextSize)
+	if err != nil {
+		return nil, errors.WithMessage(err, "reconstruct ciphertext")
+	}
+	return decryptWithKey(sss.Combine(keyShares), ciphertext)
+}
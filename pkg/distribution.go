@@ -0,0 +1,300 @@
+package pkg
+
+import (
+	"fmt"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"sort"
+)
+
+// Placement couples a share with the store holding it.
+type Placement struct {
+	Store storesPkg.Store
+	Share *sharesPkg.Share
+}
+
+// StoreError couples a store with the error it has failed on.
+type StoreError struct {
+	Store storesPkg.Store
+	Err   error
+}
+
+func (se *StoreError) Error() string {
+	return fmt.Sprintf("store '%s' (%s): %s", se.Store.Name(), se.Store.Type(), se.Err)
+}
+
+// DistributionError tells how distributing a file's shares fell short of durability, and how it was rolled back.
+type DistributionError struct {
+	FileID string
+
+	// How many shares were put, out of how many, while how many were required.
+	Put      int
+	Shares   int
+	Required int
+
+	// Why shares couldn't be put.
+	PutErrors []*StoreError
+
+	// How many put shares were deleted again, and why others couldn't be (those are left behind).
+	RolledBack     int
+	RollbackErrors []*StoreError
+
+	// How many put shares were kept on purpose, as their stores held a share of the file ID beforehand.
+	Kept int
+
+	// What the put shares' parties still missed to satisfy the file's access policy, if any.
+	PolicyMissing string
+}
+
+func (de *DistributionError) Error() string {
+	msg := fmt.Sprintf("put %d of %d shares of file '%s', while %d are required", de.Put, de.Shares, de.FileID,
+		de.Required)
+	if de.PolicyMissing != "" {
+		msg += fmt.Sprintf(" and access policy misses %s", de.PolicyMissing)
+	}
+
+	msg += fmt.Sprintf(": %d put shares rolled back", de.RolledBack)
+	if de.Kept > 0 {
+		msg += fmt.Sprintf(", %d kept", de.Kept)
+	}
+	if len(de.RollbackErrors) > 0 {
+		msg += fmt.Sprintf(", %d left behind", len(de.RollbackErrors))
+	}
+	return msg + ": " + ErrDurabilityNotReached.Error()
+}
+
+// Lets errors.Cause tell distribution errors apart.
+func (de *DistributionError) Cause() error {
+	return ErrDurabilityNotReached
+}
+
+// Collects a file's shares from the given stores.
+// Stores which don't hold a share of the file are skipped, other failures are returned alongside the found shares.
+func (g *Gasper) CollectShares(fileID string, stores []storesPkg.Store) ([]*Placement, []*StoreError) {
+	placements := make([]*Placement, 0, len(stores))
+	storeErrors := make([]*StoreError, 0)
+
+	for _, store := range stores {
+		share, err := store.Get(fileID)
+		if err != nil {
+			if err != storesPkg.ErrShareNotExists {
+				storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			}
+			continue
+		}
+
+		placements = append(placements, &Placement{Store: store, Share: share})
+	}
+
+	return placements, storeErrors
+}
+
+// Puts each share in its own store, in order.
+// Returns the placements of shares which were put successfully, alongside failures.
+func (g *Gasper) PutShares(shares []*sharesPkg.Share, stores []storesPkg.Store) ([]*Placement, []*StoreError) {
+	placements := make([]*Placement, 0, len(shares))
+	storeErrors := make([]*StoreError, 0)
+
+	for i, share := range shares {
+		if i > len(stores)-1 {
+			break
+		}
+
+		store := stores[i]
+		if err := store.Put(share); err != nil {
+			storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			continue
+		}
+
+		placements = append(placements, &Placement{Store: store, Share: share})
+	}
+
+	return placements, storeErrors
+}
+
+// Puts each share of a new file in its own store, in order, as a transaction: unless at least durability shares are
+// put (and, under an access policy, their parties satisfy it), the put shares are deleted again and a
+// *DistributionError is returned. Durability defaults to the minimum shares threshold.
+// Note: a store's previous share of the same file ID (if any) is replaced, so it's lost on rollback.
+// Returns the placements of shares which were put successfully, alongside non-fatal failures.
+func (g *Gasper) DistributeShares(sharedFile *sharesPkg.SharedFile, stores []storesPkg.Store,
+	durability int) ([]*Placement, []*StoreError, error) {
+	return g.distributeShares(sharedFile, stores, durability, nil)
+}
+
+// Distributes shares like DistributeShares, except that shares put in the kept stores are never rolled back.
+func (g *Gasper) distributeShares(sharedFile *sharesPkg.SharedFile, stores []storesPkg.Store, durability int,
+	kept map[storesPkg.Store]bool) ([]*Placement, []*StoreError, error) {
+	if durability <= 0 {
+		durability = int(sharedFile.Manifest.MinSharesThreshold)
+	}
+
+	placements, putErrors := g.PutShares(sharedFile.Shares, stores)
+	policyMissing := PolicyMissing(placements)
+	if len(placements) >= durability && policyMissing == "" {
+		return placements, putErrors, nil
+	}
+
+	distributionErr := &DistributionError{
+		FileID:         sharedFile.ID,
+		Put:            len(placements),
+		Shares:         len(sharedFile.Shares),
+		Required:       durability,
+		PutErrors:      putErrors,
+		RollbackErrors: make([]*StoreError, 0),
+		PolicyMissing:  policyMissing,
+	}
+	for _, placement := range placements {
+		if kept[placement.Store] {
+			distributionErr.Kept++
+			continue
+		}
+
+		if err := placement.Store.Delete(sharedFile.ID); err != nil && err != storesPkg.ErrShareNotExists {
+			distributionErr.RollbackErrors = append(distributionErr.RollbackErrors,
+				&StoreError{Store: placement.Store, Err: err})
+			continue
+		}
+		distributionErr.RolledBack++
+	}
+	return nil, nil, distributionErr
+}
+
+// Deletes a file's share from each of the given stores.
+// Returns how many shares were deleted, alongside failures. Stores which don't hold a share of the file are skipped.
+func (g *Gasper) DeleteShares(fileID string, stores []storesPkg.Store) (int, []*StoreError) {
+	deletedShares := 0
+	storeErrors := make([]*StoreError, 0)
+
+	for _, store := range stores {
+		if err := store.Delete(fileID); err != nil {
+			if err != storesPkg.ErrShareNotExists {
+				storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			}
+			continue
+		}
+
+		deletedShares++
+	}
+
+	return deletedShares, storeErrors
+}
+
+// Lists IDs of files which have a share in any of the given stores, sorted.
+// Returns failures alongside.
+func (g *Gasper) ListFileIDs(stores []storesPkg.Store) ([]string, []*StoreError) {
+	seen := make(map[string]bool)
+	storeErrors := make([]*StoreError, 0)
+
+	for _, store := range stores {
+		fileIDs, err := store.List()
+		if err != nil {
+			storeErrors = append(storeErrors, &StoreError{Store: store, Err: err})
+			continue
+		}
+
+		for _, fileID := range fileIDs {
+			seen[fileID] = true
+		}
+	}
+
+	fileIDs := make([]string, 0, len(seen))
+	for fileID := range seen {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+
+	return fileIDs, storeErrors
+}
+
+// Builds a shared file out of collected placements.
+func SharedFileFromPlacements(fileID, checksum string, placements []*Placement) *sharesPkg.SharedFile {
+	shares := make([]*sharesPkg.Share, 0, len(placements))
+	for _, placement := range placements {
+		shares = append(shares, placement.Share)
+	}
+
+	return &sharesPkg.SharedFile{
+		ID:       fileID,
+		Checksum: checksum,
+		Shares:   shares,
+	}
+}
+
+// Returns the placements holding the given shares.
+func placementsOf(placements []*Placement, shares []*sharesPkg.Share) []*Placement {
+	wanted := make(map[*sharesPkg.Share]bool, len(shares))
+	for _, share := range shares {
+		wanted[share] = true
+	}
+
+	matching := make([]*Placement, 0, len(shares))
+	for _, placement := range placements {
+		if wanted[placement.Share] {
+			matching = append(matching, placement)
+		}
+	}
+	return matching
+}
+
+// Returns the stores of the given placements.
+func PlacementStores(placements []*Placement) []storesPkg.Store {
+	stores := make([]storesPkg.Store, 0, len(placements))
+	for _, placement := range placements {
+		stores = append(stores, placement.Store)
+	}
+	return stores
+}
+
+// Splits collected placements by share generation.
+// Current placements are those of the latest generation which reaches its minimum shares threshold (or simply of the
+// latest generation, if none does). All others are stale, and must not be combined with current ones.
+func SelectGeneration(placements []*Placement) (current []*Placement, stale []*Placement) {
+	byGeneration := make(map[uint64][]*Placement)
+	for _, placement := range placements {
+		generation := placement.Share.Generation()
+		byGeneration[generation] = append(byGeneration[generation], placement)
+	}
+
+	var (
+		selected      uint64
+		selectedFound bool
+		latest        uint64
+	)
+	for generation, generationPlacements := range byGeneration {
+		if generation > latest {
+			latest = generation
+		}
+
+		manifest := PlacementsManifest(generationPlacements)
+		if manifest != nil && (!manifest.Valid() || len(generationPlacements) < int(manifest.MinSharesThreshold)) {
+			continue
+		}
+
+		if !selectedFound || generation > selected {
+			selected, selectedFound = generation, true
+		}
+	}
+
+	if !selectedFound {
+		selected = latest
+	}
+
+	for _, placement := range placements {
+		if placement.Share.Generation() == selected {
+			current = append(current, placement)
+		} else {
+			stale = append(stale, placement)
+		}
+	}
+	return current, stale
+}
+
+// Returns the manifest agreed upon by most of the given placements' shares, or nil if they hold none.
+func PlacementsManifest(placements []*Placement) *sharesPkg.Manifest {
+	shares := make([]*sharesPkg.Share, 0, len(placements))
+	for _, placement := range placements {
+		shares = append(shares, placement.Share)
+	}
+	return sharesPkg.ConsensusManifest(shares)
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestDistributeShares(t *testing.T) {
+	failAll := func(fileID string) bool { return true }
+
+	tests := []struct {
+		name       string
+		durability int
+		failPut    []int // Indexes of the stores failing puts.
+		failDelete []int // Indexes of the stores failing deletes, thus rollbacks.
+		kept       []int // Indexes of the stores whose shares are never rolled back.
+		err        *DistributionError
+		fileIDs    []string
+	}{
+		{name: "every share put", fileIDs: []string{"file"}},
+		{name: "threshold reached", failPut: []int{2}, fileIDs: []string{"file"}},
+		{
+			name:    "below threshold",
+			failPut: []int{0, 2},
+			err:     &DistributionError{FileID: "file", Put: 1, Shares: 3, Required: 2, RolledBack: 1},
+			fileIDs: []string{},
+		},
+		{
+			name:       "below durability",
+			durability: 3,
+			failPut:    []int{1},
+			err:        &DistributionError{FileID: "file", Put: 2, Shares: 3, Required: 3, RolledBack: 2},
+			fileIDs:    []string{},
+		},
+		{
+			name:       "rollback fails",
+			failPut:    []int{0, 1},
+			failDelete: []int{2},
+			err:        &DistributionError{FileID: "file", Put: 1, Shares: 3, Required: 2},
+			fileIDs:    []string{"file"},
+		},
+		{
+			name:    "kept shares",
+			failPut: []int{0, 1},
+			kept:    []int{2},
+			err:     &DistributionError{FileID: "file", Put: 1, Shares: 3, Required: 2, Kept: 1},
+			fileIDs: []string{"file"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			faulty, stores, cleanup := newFaultyTestStores(t, 3)
+			defer cleanup()
+
+			g := newTestGasper(t, stores, true)
+			sharedFile := splitTestData(t, g, "file", testData(13, 1000), "", 3, 2)
+
+			for _, i := range test.failPut {
+				faulty[i].failPut = func(*sharesPkg.Share) bool { return true }
+			}
+			for _, i := range test.failDelete {
+				faulty[i].failDelete = failAll
+			}
+			kept := make(map[storesPkg.Store]bool, len(test.kept))
+			for _, i := range test.kept {
+				kept[stores[i]] = true
+			}
+
+			placements, storeErrors, err := g.distributeShares(sharedFile, stores, test.durability, kept)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("distribute shares: %v", err)
+				} else if len(placements) != 3-len(test.failPut) || len(storeErrors) != len(test.failPut) {
+					t.Errorf("%d shares put and %d failures, want %d failures", len(placements), len(storeErrors),
+						len(test.failPut))
+				}
+			} else {
+				distributionErr, ok := err.(*DistributionError)
+				if !ok {
+					t.Fatalf("distribute shares: %v, want a distribution error", err)
+				} else if errors.Cause(err) != ErrDurabilityNotReached {
+					t.Errorf("cause: %v, want %v", errors.Cause(err), ErrDurabilityNotReached)
+				} else if placements != nil || storeErrors != nil {
+					t.Errorf("%d placements and %d failures returned alongside the error", len(placements),
+						len(storeErrors))
+				}
+
+				if len(distributionErr.PutErrors) != len(test.failPut) ||
+					len(distributionErr.RollbackErrors) != len(test.failDelete) {
+					t.Errorf("%d put and %d rollback failures, want %d and %d", len(distributionErr.PutErrors),
+						len(distributionErr.RollbackErrors), len(test.failPut), len(test.failDelete))
+				}
+				for _, storeErr := range append(distributionErr.PutErrors, distributionErr.RollbackErrors...) {
+					if storeErr.Err != errFaultyStore {
+						t.Errorf("failure: %v, want %v", storeErr.Err, errFaultyStore)
+					}
+				}
+
+				distributionErr.PutErrors, distributionErr.RollbackErrors = nil, nil
+				if !reflect.DeepEqual(distributionErr, test.err) {
+					t.Errorf("distribution error: %+v, want %+v", distributionErr, test.err)
+				}
+			}
+
+			for _, store := range faulty {
+				store.failDelete = nil
+			}
+			if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, test.fileIDs) {
+				t.Errorf("stored file IDs: %v, want %v", fileIDs, test.fileIDs)
+			}
+		})
+	}
+}
+
+func TestDistributionErrorMessage(t *testing.T) {
+	err := &DistributionError{FileID: "file", Put: 2, Shares: 5, Required: 3, RolledBack: 1, Kept: 1,
+		RollbackErrors: []*StoreError{{}}, PolicyMissing: "1 more of (alice, bob)"}
+
+	want := "put 2 of 5 shares of file 'file', while 3 are required and access policy misses 1 more of (alice, bob): " +
+		"1 put shares rolled back, 1 kept, 1 left behind: " + ErrDurabilityNotReached.Error()
+	if err.Error() != want {
+		t.Errorf("message: '%s', want '%s'", err.Error(), want)
+	}
+}
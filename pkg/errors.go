@@ -5,4 +5,37 @@ import "github.com/pkg/errors"
 var (
 	ErrInvalidSharesThreshold = errors.New("minimum shares threshold cannot be larger than share count")
 	ErrNilSharedFile          = errors.New("nil shared file")
+	ErrNotEnoughShares        = errors.New("not enough shares to reach minimum shares threshold")
+	ErrNotEnoughStores        = errors.New("not enough available stores for all shares")
+	ErrNotAllSharesPut        = errors.New("not all shares could be put in stores")
+	ErrSwitchOverFailed       = errors.New("failed to switch over to new shares")
+	ErrDurabilityNotReached   = errors.New("not enough shares could be put in stores to reach durability")
+	ErrMixedGenerations       = errors.New("cannot combine shares of different generations")
+	ErrUnknownFileName        = errors.New("file's original name is unknown, destination must be a file path")
+	ErrInvalidFileID          = errors.New("invalid file ID")
+	ErrFileIDTaken            = errors.New("file ID is already taken")
+
+	// Verifiable secret sharing errors.
+	ErrNotVerifiable       = errors.New("share wasn't split using verifiable secret sharing")
+	ErrCommitmentsMismatch = errors.New("share's commitments don't match the published ones")
+	ErrBadKeyShare         = errors.New("share's key share is inconsistent with commitments")
+	ErrBadCiphertext       = errors.New("share's ciphertext doesn't match its digest")
+
+	// Access policy errors.
+	ErrNoPolicy           = errors.New("no access policy to split along")
+	ErrPolicyNotSatisfied = errors.New("access policy isn't satisfied")
+	ErrPartyStoreNotFound = errors.New("no store is named after policy party")
+	ErrPolicyFixedShape   = errors.New("share count and threshold of files split along a policy follow the policy")
+	ErrPolicyRepair       = errors.New("shares split along a policy cannot be regenerated, refresh the file instead")
+
+	// Chunking errors.
+	ErrIncompleteListing = errors.New("not every store could be listed, cannot tell which chunks are unreferenced")
+	ErrUnreadableIndex   = errors.New("chunk index cannot be read, cannot tell which chunks it references")
+
+	// Versioning errors.
+	ErrVersionNotFound   = errors.New("no such version")
+	ErrHistoriesUnlisted = errors.New("not every store could be listed, cannot tell which histories reference the file")
+
+	// Discovery errors.
+	ErrNotAShare = errors.New("entry isn't a share")
 )
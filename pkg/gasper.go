@@ -1,19 +1,26 @@
 package pkg
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
 	"encoding/hex"
 	"github.com/codahale/sss"
 	petname "github.com/dustinkirkland/golang-petname"
 	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg/archive"
+	"github.com/gasper/pkg/compression"
+	metadataPkg "github.com/gasper/pkg/metadata"
 	sharesPkg "github.com/gasper/pkg/shares"
 	storesPkg "github.com/gasper/pkg/storage/stores"
 	"github.com/pkg/errors"
 	"io/ioutil"
-	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 )
 
 // todo: more elegant and efficient way to read & write big files.
@@ -21,6 +28,17 @@ import (
 const (
 	fileIDWordCount     = 2
 	fileIDWordSeparator = "-"
+
+	// Random bytes of generated file IDs (128 bits), so that they don't collide however many files are stored.
+	fileIDRandomSize = 16
+
+	maxFileIDLength = 128
+
+	// Size of the random keys payloads are encrypted with, under schemes which split the key rather than the payload.
+	randomKeySize = 32
+
+	// Size of the random nonce appended to the payload under Shamir's secret sharing (see splitSalted).
+	shareNonceSize = 32
 )
 
 // Gasper lets you store, load, and delete files in a multi-part, distributed manner, using on Shamir's Secret Sharing.
@@ -28,6 +46,11 @@ const (
 type Gasper struct {
 	stores    []storesPkg.Store
 	encryptor *encryption.Encryptor
+
+	// Subkeys of the encryption salt (or of a public secret, without encryption), which chunk keys and history IDs are
+	// derived with (see subkey, chunkKey and HistoryID).
+	chunkSecret   []byte
+	historySecret []byte
 }
 
 func NewGasper(stores []storesPkg.Store, encryptionSettings *encryption.Settings) (*Gasper, error) {
@@ -35,12 +58,27 @@ func NewGasper(stores []storesPkg.Store, encryptionSettings *encryption.Settings
 		return nil, errors.WithMessage(err, "validate encryption settings")
 	}
 
+	secret := []byte(convergentChunkSecret)
+	if encryptionSettings.TurnedOn {
+		secret = []byte(encryptionSettings.Salt)
+	}
+
 	return &Gasper{
-		stores:    stores,
-		encryptor: encryption.NewEncryptor(encryptionSettings),
+		stores:        stores,
+		encryptor:     encryption.NewEncryptor(encryptionSettings),
+		chunkSecret:   subkey(secret, chunkKeyPurpose),
+		historySecret: subkey(secret, historyIDPurpose),
 	}, nil
 }
 
+// Derives a subkey of secret for the given purpose (HMAC-SHA256 of the purpose label, keyed by secret), so that
+// subkeys of different purposes are independent of each other and of secret, which never keys two primitives.
+func subkey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 // Retrieves stores.
 func (g *Gasper) Stores() []storesPkg.Store {
 	return g.stores
@@ -48,36 +86,160 @@ func (g *Gasper) Stores() []storesPkg.Store {
 
 // Splits file into its shares.
 func (g *Gasper) SharesFromFile(filePath string, shareCount, minSharesThreshold byte) (*sharesPkg.SharedFile, error) {
-	if minSharesThreshold > shareCount {
+	fileID, err := g.UniqueFileID()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.SplitFile(fileID, filePath, &sharesPkg.Manifest{
+		ShareCount:         shareCount,
+		MinSharesThreshold: minSharesThreshold,
+	}, &metadataPkg.Options{})
+}
+
+// Splits raw data into its shares, under the given file ID.
+func (g *Gasper) SharesFromData(fileID string, data []byte, shareCount,
+	minSharesThreshold byte) (*sharesPkg.SharedFile, error) {
+	return g.Split(fileID, data, nil, &sharesPkg.Manifest{
+		ShareCount:         shareCount,
+		MinSharesThreshold: minSharesThreshold,
+	})
+}
+
+// Splits file into its shares under the given file ID, according to a manifest template (see Split).
+// File's metadata is captured according to metadata options, and shared along with its data.
+func (g *Gasper) SplitFile(fileID, filePath string, template *sharesPkg.Manifest,
+	metadataOptions *metadataPkg.Options) (*sharesPkg.SharedFile, error) {
+	if template.MinSharesThreshold > template.ShareCount {
 		return nil, ErrInvalidSharesThreshold
 	}
 
-	fileID := g.uniqueFileId()
+	data, fileMetadata, err := LoadFile(filePath, metadataOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Split(fileID, data, fileMetadata, template)
+}
+
+// Splits a directory tree into its shares under the given file ID, according to a manifest template (see Split). The
+// tree is bundled into a single archive first, so it is shared under a single file ID. Directory's own metadata is
+// captured according to metadata options.
+func (g *Gasper) SplitDirectory(fileID, directoryPath string, template *sharesPkg.Manifest,
+	metadataOptions *metadataPkg.Options) (*sharesPkg.SharedFile, error) {
+	if template.MinSharesThreshold > template.ShareCount {
+		return nil, ErrInvalidSharesThreshold
+	}
+
+	data, directoryMetadata, err := LoadDirectory(directoryPath, metadataOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	directoryTemplate := *template
+	directoryTemplate.Content = sharesPkg.ContentDirectory
+	return g.Split(fileID, data, directoryMetadata, &directoryTemplate)
+}
+
+// Reads a file, capturing its metadata according to metadata options.
+func LoadFile(filePath string, metadataOptions *metadataPkg.Options) ([]byte, *metadataPkg.Metadata, error) {
+	fileMetadata, err := metadataPkg.Capture(filePath, metadataOptions)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "capture metadata of file '%s'", filePath)
+	}
 
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return nil, errors.WithMessagef(err, "read file '%s'", filePath)
+		return nil, nil, errors.WithMessagef(err, "read file '%s'", filePath)
+	}
+	return data, fileMetadata, nil
+}
+
+// Bundles a directory tree into a single archive, capturing directory's own metadata according to metadata options.
+func LoadDirectory(directoryPath string, metadataOptions *metadataPkg.Options) ([]byte, *metadataPkg.Metadata,
+	error) {
+	directoryMetadata, err := metadataPkg.Capture(directoryPath, metadataOptions)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "capture metadata of directory '%s'", directoryPath)
 	}
 
-	encryptedData, err := g.encryptor.Encrypt(data)
+	data, err := archive.Pack(directoryPath)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "pack directory '%s'", directoryPath)
+	}
+	return data, directoryMetadata, nil
+}
+
+// Splits raw data into its shares under the given file ID, according to a manifest template: share count, minimum
+// shares threshold, scheme, access policy, content type and generation are taken from it. The rest of the manifest is
+// filled while splitting. File metadata (if any) is prepended to data, so it's encrypted and shared along with it.
+// Data is first compressed using metadata's compression codec, which is then set to the codec actually used.
+func (g *Gasper) Split(fileID string, data []byte, fileMetadata *metadataPkg.Metadata,
+	template *sharesPkg.Manifest) (*sharesPkg.SharedFile, error) {
+	if template.MinSharesThreshold > template.ShareCount {
+		return nil, ErrInvalidSharesThreshold
+	}
+
+	payloadData := data
+	if fileMetadata != nil && fileMetadata.Compression != "" {
+		codec, compressedData, err := compression.Compress(fileMetadata.Compression, data)
+		if err != nil {
+			return nil, errors.WithMessage(err, "compress data")
+		}
+
+		compressedMetadata := *fileMetadata
+		compressedMetadata.Compression = codec
+		fileMetadata, payloadData = &compressedMetadata, compressedData
+	}
+
+	payload, err := metadataPkg.EncodePayload(fileMetadata, payloadData)
+	if err != nil {
+		return nil, errors.WithMessage(err, "encode payload")
+	}
+
+	encryptedData, err := g.encryptor.Encrypt(payload)
 	if err != nil {
 		return nil, errors.WithMessage(err, "encrypt data")
 	}
 
-	sharesBytes, err := sss.Split(shareCount, minSharesThreshold, encryptedData)
+	manifest := &sharesPkg.Manifest{
+		Scheme:             template.Scheme,
+		Content:            template.Content,
+		Generation:         template.Generation,
+		ShareCount:         template.ShareCount,
+		MinSharesThreshold: template.MinSharesThreshold,
+		Policy:             template.Policy,
+	}
+
+	var sharesBytes map[byte][]byte
+	switch manifest.SchemeOrDefault() {
+	case sharesPkg.SchemeShamir:
+		sharesBytes, err = splitSalted(encryptedData, manifest)
+	case sharesPkg.SchemeVSS:
+		sharesBytes, err = splitVerifiable(encryptedData, manifest)
+	case sharesPkg.SchemeSSMS:
+		sharesBytes, err = splitDispersed(encryptedData, manifest)
+	case sharesPkg.SchemePolicy:
+		sharesBytes, err = splitPolicy(encryptedData, manifest)
+	default:
+		return nil, errors.Errorf("unsupported scheme '%s'", manifest.Scheme)
+	}
 	if err != nil {
 		return nil, errors.WithMessage(err, "split data to shares")
 	}
 
+	manifest.ShareDigests = make(map[string]string, len(sharesBytes))
 	shares := make([]*sharesPkg.Share, 0, len(sharesBytes))
 	for shareID, shareBytes := range sharesBytes {
 		share := &sharesPkg.Share{
-			ID:     strconv.Itoa(int(shareID)),
-			FileID: fileID,
-			Data:   shareBytes,
+			ID:       strconv.Itoa(int(shareID)),
+			FileID:   fileID,
+			Data:     shareBytes,
+			Manifest: manifest,
 		}
 
 		shares = append(shares, share)
+		manifest.ShareDigests[share.ID] = sharesPkg.ShareDigest(shareBytes)
 	}
 
 	checksum := md5.Sum(data)
@@ -86,49 +248,218 @@ func (g *Gasper) SharesFromFile(filePath string, shareCount, minSharesThreshold
 		ID:       fileID,
 		Checksum: hex.EncodeToString(checksum[:]),
 		Shares:   shares,
+		Manifest: manifest,
 	}, nil
 }
 
-func (g *Gasper) uniqueFileId() string {
-	rand.Seed(time.Now().UnixNano())
-	return petname.Generate(fileIDWordCount, fileIDWordSeparator)
+// Estimates the size of each share of data of the given size, split according to a manifest template (e.g. for
+// planning). Only the data's share is accounted for: compression, metadata, encryption and manifest overhead aren't.
+func EstimateShareSize(template *sharesPkg.Manifest, size int64) int64 {
+	if template.SchemeOrDefault() == sharesPkg.SchemeSSMS && template.MinSharesThreshold > 0 {
+		threshold := int64(template.MinSharesThreshold)
+		return (size + threshold - 1) / threshold
+	}
+	return size
+}
+
+// Generates a new file ID, prefixed with a human-friendly alias (see NewFileID).
+func (g *Gasper) UniqueFileID() (string, error) {
+	return NewFileID(true)
+}
+
+// Generates a new cryptographically random file ID, optionally prefixed with a human-friendly (but not unique) alias,
+// e.g. 'brave-otter-<random>'.
+func NewFileID(alias bool) (string, error) {
+	random := make([]byte, fileIDRandomSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.WithMessage(err, "generate random file ID")
+	}
+
+	fileID := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random))
+	if alias {
+		fileID = petname.Generate(fileIDWordCount, fileIDWordSeparator) + fileIDWordSeparator + fileID
+	}
+	return fileID, nil
+}
+
+// Checks a user-chosen file ID is usable: letters, digits, '-' and '_' only (stores use it in file names), and not
+// starting with a prefix reserved for chunks or histories.
+func ValidateFileID(fileID string) error {
+	if fileID == "" || len(fileID) > maxFileIDLength {
+		return errors.WithMessagef(ErrInvalidFileID, "file ID must be 1 to %d characters long", maxFileIDLength)
+	}
+
+	for _, prefix := range []string{chunkIDPrefix, historyIDPrefix, stagingIDPrefix} {
+		if strings.HasPrefix(fileID, prefix) {
+			return errors.WithMessagef(ErrInvalidFileID, "prefix '%s' is reserved", prefix)
+		}
+	}
+
+	for _, char := range fileID {
+		if !('a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' ||
+			char == '-' || char == '_') {
+			return errors.WithMessagef(ErrInvalidFileID, "invalid character '%c'", char)
+		}
+	}
+	return nil
+}
+
+// Checks no share of the given file ID is held by any of the given stores, so that a new file doesn't get mixed with
+// an existing one. Returns ErrFileIDTaken if one is, or the first failure if any store couldn't be checked.
+func (g *Gasper) CheckFileIDAvailable(fileID string, stores []storesPkg.Store) error {
+	placements, storeErrors := g.CollectShares(fileID, stores)
+	if len(placements) > 0 {
+		return errors.WithMessagef(ErrFileIDTaken, "file ID '%s' (store '%s')", fileID,
+			placements[0].Store.Name())
+	} else if len(storeErrors) > 0 {
+		return errors.WithMessagef(storeErrors[0], "check file ID '%s' is available", fileID)
+	}
+	return nil
 }
 
 // Dumps shared file to a local filesystem destination.
 // If md5 checksum is set, will use it to check file authenticity, otherwise will skip checksum check.
 func (g *Gasper) DumpSharedFile(sharedFile *sharesPkg.SharedFile, destination string) error {
-	if sharedFile == nil {
-		return ErrNilSharedFile
+	recovery, err := g.Recover(sharedFile)
+	if err != nil {
+		return err
 	}
 
-	rawShares := make(map[byte][]byte, len(sharedFile.Shares))
-	for _, share := range sharedFile.Shares {
-		shareIDInt, err := strconv.Atoi(share.ID)
-		if err != nil {
-			return errors.WithMessage(err, "convert share ID from string to int")
+	_, err = g.DumpData(recovery.Data, recovery.Metadata, destination, &metadataPkg.Options{})
+	return err
+}
+
+// Dumps recovered data to a local filesystem destination, restoring the file's metadata (if any) according to
+// metadata options. If destination is a directory, file is dumped into it under its original name.
+// Returns the path file was dumped to.
+func (g *Gasper) DumpData(data []byte, fileMetadata *metadataPkg.Metadata, destination string,
+	metadataOptions *metadataPkg.Options) (string, error) {
+	if info, err := os.Stat(destination); err == nil && info.IsDir() {
+		if fileMetadata == nil || fileMetadata.Name == "" {
+			return "", ErrUnknownFileName
 		}
-		rawShares[byte(shareIDInt)] = share.Data
+		destination = filepath.Join(destination, filepath.Base(fileMetadata.Name))
 	}
 
-	combinedBytes := sss.Combine(rawShares)
+	mode := metadataPkg.DefaultMode
+	if fileMetadata != nil {
+		mode = fileMetadata.Mode.Perm()
+	}
 
-	decryptedData, err := g.encryptor.Decrypt(combinedBytes)
-	if err != nil {
-		return errors.WithMessage(err, "decrypt data")
+	if err := ioutil.WriteFile(destination, data, mode); err != nil {
+		return "", errors.WithMessagef(err, "write file '%s'", destination)
 	}
 
-	if sharedFile.Checksum != "" {
-		if err := g.validateChecksum(decryptedData, sharedFile.Checksum); err != nil {
-			return err
+	if fileMetadata != nil {
+		if err := fileMetadata.Restore(destination, metadataOptions); err != nil {
+			return destination, errors.WithMessagef(err, "restore metadata of file '%s'", destination)
 		}
 	}
+	return destination, nil
+}
 
-	if err := ioutil.WriteFile(destination, decryptedData, os.ModePerm); err != nil {
-		return errors.WithMessagef(err, "write file '%s'", destination)
+// Dumps recovered directory tree data to a local filesystem destination directory.
+// If subPath is set, only the part of the tree at or under it is restored.
+func (g *Gasper) DumpDirectory(data []byte, destination, subPath string) error {
+	if err := archive.Unpack(data, destination, subPath); err != nil {
+		return errors.WithMessagef(err, "unpack directory to '%s'", destination)
 	}
 	return nil
 }
 
+// Combines shared file's shares back into the original data, in memory.
+// If md5 checksum is set, will use it to check file authenticity, otherwise will skip checksum check.
+// Bad shares are tolerated as long as enough good ones remain (see Recover).
+func (g *Gasper) Combine(sharedFile *sharesPkg.SharedFile) ([]byte, error) {
+	recovery, err := g.Recover(sharedFile)
+	if err != nil {
+		return nil, err
+	}
+	return recovery.Data, nil
+}
+
+// Decrypts combined data, splits file metadata (if any) out of it, decompresses it, and checks it against the checksum
+// (if set).
+func (g *Gasper) decode(combinedBytes []byte, checksum string) ([]byte, *metadataPkg.Metadata, error) {
+	decryptedPayload, err := g.encryptor.Decrypt(combinedBytes)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "decrypt data")
+	}
+
+	fileMetadata, decryptedData, err := metadataPkg.DecodePayload(decryptedPayload)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "decode payload")
+	}
+
+	if fileMetadata != nil {
+		if decryptedData, err = compression.Decompress(fileMetadata.Compression, decryptedData); err != nil {
+			return nil, nil, errors.WithMessage(err, "decompress data")
+		}
+	}
+
+	if checksum != "" {
+		if err := g.validateChecksum(decryptedData, checksum); err != nil {
+			return nil, nil, err
+		}
+	}
+	return decryptedData, fileMetadata, nil
+}
+
+// Splits payload using Shamir's secret sharing, with a random nonce appended to it. Each share then ends with a share
+// of the nonce, which can't be predicted from fewer shares than the threshold, and salts the share's integrity tag:
+// otherwise, holders of fewer shares could brute force a low-entropy payload against the tags of the others.
+// Other schemes need none, as each of their shares holds a share of a random key.
+func splitSalted(payload []byte, manifest *sharesPkg.Manifest) (map[byte][]byte, error) {
+	salted := make([]byte, len(payload)+shareNonceSize)
+	copy(salted, payload)
+	if _, err := rand.Read(salted[len(payload):]); err != nil {
+		return nil, errors.WithMessage(err, "generate random nonce")
+	}
+
+	manifest.NonceSize = shareNonceSize
+	return sss.Split(manifest.ShareCount, manifest.MinSharesThreshold, salted)
+}
+
+// Combines shares split by splitSalted back into the payload. Shares split without a nonce are combined as-is.
+func combineSalted(shares map[byte][]byte, manifest *sharesPkg.Manifest) ([]byte, error) {
+	salted := sss.Combine(shares)
+	if manifest == nil || manifest.NonceSize == 0 {
+		return salted, nil
+	} else if len(salted) < manifest.NonceSize {
+		return nil, errors.New("combined data is shorter than nonce")
+	}
+	return salted[:len(salted)-manifest.NonceSize], nil
+}
+
+// Encrypts payload with a fresh random key, for schemes which split the key rather than the payload itself.
+func encryptWithRandomKey(payload []byte) ([]byte, []byte, error) {
+	key := make([]byte, randomKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, errors.WithMessage(err, "generate random key")
+	}
+
+	ciphertext, err := encryption.NewEncryptor(&encryption.Settings{
+		TurnedOn: true,
+		Salt:     string(key),
+	}).Encrypt(payload)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "encrypt payload")
+	}
+	return key, ciphertext, nil
+}
+
+// Decrypts a payload encrypted by encryptWithRandomKey.
+func decryptWithKey(key, ciphertext []byte) ([]byte, error) {
+	payload, err := encryption.NewEncryptor(&encryption.Settings{
+		TurnedOn: true,
+		Salt:     string(key),
+	}).Decrypt(ciphertext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decrypt payload")
+	}
+	return payload, nil
+}
+
 func (g *Gasper) validateChecksum(decryptedData []byte, originalChecksum string) error {
 	currentChecksumBytes := md5.Sum(decryptedData)
 	currentChecksum := hex.EncodeToString(currentChecksumBytes[:])
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/gasper/internal/encryption"
+	"github.com/gasper/pkg/compression"
+	metadataPkg "github.com/gasper/pkg/metadata"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const testSalt = "0123456789abcdef0123456789abcdef"
+
+func newTestGasper(t *testing.T, stores []storesPkg.Store, encrypted bool) *Gasper {
+	settings := &encryption.Settings{TurnedOn: false}
+	if encrypted {
+		settings = &encryption.Settings{TurnedOn: true, Salt: testSalt}
+	}
+
+	gasper, err := NewGasper(stores, settings)
+	if err != nil {
+		t.Fatalf("new gasper: %v", err)
+	}
+	return gasper
+}
+
+// Creates local stores in a temporary directory, which the returned function removes.
+func newTestStores(t *testing.T, count int) ([]storesPkg.Store, func()) {
+	root, err := ioutil.TempDir("", "gasper-test")
+	if err != nil {
+		t.Fatalf("create stores directory: %v", err)
+	}
+
+	stores := make([]storesPkg.Store, 0, count)
+	for i := 0; i < count; i++ {
+		directory := filepath.Join(root, "store-"+strconv.Itoa(i+1))
+		if err := os.Mkdir(directory, 0755); err != nil {
+			t.Fatalf("create store directory: %v", err)
+		}
+
+		store, err := storesPkg.NewLocalStore(directory)
+		if err != nil {
+			t.Fatalf("new local store: %v", err)
+		}
+		stores = append(stores, store)
+	}
+	return stores, func() { _ = os.RemoveAll(root) }
+}
+
+var errFaultyStore = errors.New("faulty store")
+
+// Store which fails the operations its fault functions pick, and passes others on to the store it wraps.
+type faultyStore struct {
+	storesPkg.Store
+	failPut    func(share *sharesPkg.Share) bool
+	failGet    func(fileID string) bool
+	failDelete func(fileID string) bool
+}
+
+func (fs *faultyStore) Put(share *sharesPkg.Share) error {
+	if fs.failPut != nil && fs.failPut(share) {
+		return errFaultyStore
+	}
+	return fs.Store.Put(share)
+}
+
+func (fs *faultyStore) Get(fileID string) (*sharesPkg.Share, error) {
+	if fs.failGet != nil && fs.failGet(fileID) {
+		return nil, errFaultyStore
+	}
+	return fs.Store.Get(fileID)
+}
+
+func (fs *faultyStore) Delete(fileID string) error {
+	if fs.failDelete != nil && fs.failDelete(fileID) {
+		return errFaultyStore
+	}
+	return fs.Store.Delete(fileID)
+}
+
+// Creates local stores like newTestStores, wrapped in faulty stores which don't fail anything yet.
+func newFaultyTestStores(t *testing.T, count int) ([]*faultyStore, []storesPkg.Store, func()) {
+	stores, cleanup := newTestStores(t, count)
+
+	faulty := make([]*faultyStore, 0, count)
+	wrapped := make([]storesPkg.Store, 0, count)
+	for _, store := range stores {
+		faulty = append(faulty, &faultyStore{Store: store})
+		wrapped = append(wrapped, faulty[len(faulty)-1])
+	}
+	return faulty, wrapped, cleanup
+}
+
+func testData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// Splits data under the given file ID, along a share count and threshold.
+func splitTestData(t *testing.T, g *Gasper, fileID string, data []byte, scheme string, shareCount,
+	threshold byte) *sharesPkg.SharedFile {
+	sharedFile, err := g.Split(fileID, data, nil, &sharesPkg.Manifest{
+		Scheme:             scheme,
+		ShareCount:         shareCount,
+		MinSharesThreshold: threshold,
+	})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	return sharedFile
+}
+
+// Splits data like splitTestData under Shamir's secret sharing, and puts its shares in stores.
+func storeTestData(t *testing.T, g *Gasper, fileID string, data []byte, stores []storesPkg.Store, shareCount,
+	threshold byte) (*sharesPkg.SharedFile, []*Placement) {
+	sharedFile := splitTestData(t, g, fileID, data, sharesPkg.SchemeShamir, shareCount, threshold)
+	placements, _, err := g.DistributeShares(sharedFile, stores, 0)
+	if err != nil {
+		t.Fatalf("distribute shares: %v", err)
+	}
+	return sharedFile, placements
+}
+
+// Lists the file IDs held by stores.
+func listTestFileIDs(t *testing.T, g *Gasper, stores []storesPkg.Store) []string {
+	fileIDs, storeErrors := g.ListFileIDs(stores)
+	if len(storeErrors) > 0 {
+		t.Fatalf("list file IDs: %v", storeErrors[0])
+	}
+	return fileIDs
+}
+
+// Collects a file's shares from stores.
+func collectTestPlacements(t *testing.T, g *Gasper, fileID string, stores []storesPkg.Store) []*Placement {
+	placements, storeErrors := g.CollectShares(fileID, stores)
+	if len(storeErrors) > 0 {
+		t.Fatalf("collect shares: %v", storeErrors[0])
+	}
+	return placements
+}
+
+// Collects a file's shares from stores, and recovers its data out of their current generation.
+func recoverTestData(t *testing.T, g *Gasper, fileID, checksum string, stores []storesPkg.Store) []byte {
+	current, _ := SelectGeneration(collectTestPlacements(t, g, fileID, stores))
+	data, err := g.Combine(SharedFileFromPlacements(fileID, checksum, current))
+	if err != nil {
+		t.Fatalf("combine: %v", err)
+	}
+	return data
+}
+
+func TestSplitCombine(t *testing.T) {
+	data := testData(1, 10000)
+
+	for _, scheme := range []string{sharesPkg.SchemeShamir, sharesPkg.SchemeVSS, sharesPkg.SchemeSSMS} {
+		for _, encrypted := range []bool{false, true} {
+			t.Run(scheme+" encrypted "+strconv.FormatBool(encrypted), func(t *testing.T) {
+				g := newTestGasper(t, nil, encrypted)
+				sharedFile := splitTestData(t, g, "file", data, scheme, 5, 3)
+
+				combined, err := g.Combine(sharedFile)
+				if err != nil {
+					t.Fatalf("combine: %v", err)
+				} else if !bytes.Equal(combined, data) {
+					t.Fatalf("combined data differs")
+				}
+			})
+		}
+	}
+}
+
+// The codec data was actually compressed with is recorded in the shared metadata, and undone on recovery.
+func TestSplitCompressed(t *testing.T) {
+	compressible := bytes.Repeat([]byte("2026-01-02T03:04:05Z INFO request served in 12ms\n"), 2000)
+
+	tests := []struct {
+		name   string
+		codec  string
+		data   []byte
+		actual string
+	}{
+		{name: "uncompressed", codec: compression.CodecNone, data: compressible, actual: compression.CodecNone},
+		{name: "gzip", codec: compression.CodecGzip, data: compressible, actual: compression.CodecGzip},
+		{name: "zstd", codec: compression.CodecZstd, data: compressible, actual: compression.CodecZstd},
+		{name: "auto, compressible", codec: compression.CodecAuto, data: compressible, actual: compression.CodecZstd},
+		{name: "auto, random", codec: compression.CodecAuto, data: testData(14, 50000), actual: compression.CodecNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newTestGasper(t, nil, true)
+			sharedFile, err := g.Split("file", test.data, &metadataPkg.Metadata{Name: "log", Compression: test.codec},
+				&sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2})
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+
+			compressed := len(sharedFile.Shares[0].Data) < len(test.data)/2
+			if compressed != (test.actual != compression.CodecNone) {
+				t.Errorf("%d bytes split into shares of %d", len(test.data), len(sharedFile.Shares[0].Data))
+			}
+
+			recovery, err := g.Recover(sharedFile)
+			if err != nil {
+				t.Fatalf("recover: %v", err)
+			} else if !bytes.Equal(recovery.Data, test.data) {
+				t.Error("recovered data differs")
+			} else if recovery.Metadata == nil || recovery.Metadata.Compression != test.actual {
+				t.Errorf("recorded metadata %+v, want codec '%s'", recovery.Metadata, test.actual)
+			}
+		})
+	}
+}
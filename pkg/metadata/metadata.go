@@ -0,0 +1,167 @@
+// Package metadata captures a file's metadata at store time and restores it on retrieval.
+// Metadata (along with how data was compressed) is kept in a header prepended to the file's data, so it is encrypted
+// and shared along with it.
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	payloadMagic   = "GASPERMETA"
+	payloadVersion = byte(1)
+
+	headerLengthSize = 4
+
+	// Mode files are restored with when their metadata is unknown.
+	DefaultMode = os.FileMode(0644)
+)
+
+var ErrInvalidPayload = errors.New("invalid payload encoding")
+
+// Metadata describes a file, as captured at store time.
+type Metadata struct {
+	// Original base name.
+	Name string `json:"name"`
+
+	// Permission bits.
+	Mode os.FileMode `json:"mode"`
+
+	// Modification time.
+	ModTime time.Time `json:"mtime"`
+
+	// Ownership, if captured.
+	UID *int `json:"uid,omitempty"`
+	GID *int `json:"gid,omitempty"`
+
+	// Extended attributes, if captured.
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+
+	// Codec data was compressed with before encryption (see compression package). Empty means none.
+	Compression string `json:"compression,omitempty"`
+}
+
+// Options controls which optional metadata is captured, or restored.
+type Options struct {
+	Ownership bool
+	Xattrs    bool
+
+	// Codec to compress data with, recorded as is when capturing (see compression package).
+	Compression string
+}
+
+// Captures a file's metadata.
+func Capture(filePath string, options *Options) (*Metadata, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "stat file '%s'", filePath)
+	}
+
+	metadata := &Metadata{
+		Name:        filepath.Base(filePath),
+		Mode:        info.Mode().Perm(),
+		ModTime:     info.ModTime(),
+		Compression: options.Compression,
+	}
+
+	if options.Ownership {
+		if uid, gid, ok := fileOwner(info); ok {
+			metadata.UID, metadata.GID = &uid, &gid
+		}
+	}
+
+	if options.Xattrs {
+		if metadata.Xattrs, err = listXattrs(filePath); err != nil {
+			return nil, errors.WithMessagef(err, "list extended attributes of '%s'", filePath)
+		}
+	}
+	return metadata, nil
+}
+
+// Restores a file's metadata: permission bits and modification time always, ownership and extended attributes only
+// if set in options (and captured).
+// Ownership goes first, as changing it may clear mode bits, and extended attributes before the mode, which may make
+// the file read-only. Modification time goes last, as the others may update it.
+func (m *Metadata) Restore(filePath string, options *Options) error {
+	if options.Ownership && m.UID != nil && m.GID != nil {
+		if err := os.Lchown(filePath, *m.UID, *m.GID); err != nil {
+			return errors.WithMessagef(err, "restore ownership of '%s'", filePath)
+		}
+	}
+
+	if options.Xattrs {
+		for name, value := range m.Xattrs {
+			if err := setXattr(filePath, name, value); err != nil {
+				return errors.WithMessagef(err, "restore extended attribute '%s' of '%s'", name, filePath)
+			}
+		}
+	}
+
+	if err := os.Chmod(filePath, m.Mode.Perm()); err != nil {
+		return errors.WithMessagef(err, "restore mode of '%s'", filePath)
+	}
+
+	if err := os.Chtimes(filePath, m.ModTime, m.ModTime); err != nil {
+		return errors.WithMessagef(err, "restore modification time of '%s'", filePath)
+	}
+	return nil
+}
+
+// Prepends a metadata header to data, to be encrypted and shared along with it.
+// Format: "GASPERMETA" | version (1 byte) | header length (4 bytes, big endian) | JSON metadata | data.
+func EncodePayload(metadata *Metadata, data []byte) ([]byte, error) {
+	if metadata == nil {
+		return data, nil
+	}
+
+	header, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errors.WithMessage(err, "marshal metadata")
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(payloadMagic) + 1 + headerLengthSize + len(header) + len(data))
+	buffer.WriteString(payloadMagic)
+	buffer.WriteByte(payloadVersion)
+
+	headerLength := make([]byte, headerLengthSize)
+	binary.BigEndian.PutUint32(headerLength, uint32(len(header)))
+	buffer.Write(headerLength)
+	buffer.Write(header)
+	buffer.Write(data)
+
+	return buffer.Bytes(), nil
+}
+
+// Splits a payload encoded by EncodePayload back into metadata and data.
+// Payloads shared before metadata was introduced are returned as-is, with nil metadata.
+func DecodePayload(payload []byte) (*Metadata, []byte, error) {
+	if !bytes.HasPrefix(payload, []byte(payloadMagic)) {
+		return nil, payload, nil
+	}
+
+	payload = payload[len(payloadMagic):]
+	if len(payload) < 1+headerLengthSize {
+		return nil, nil, ErrInvalidPayload
+	} else if payload[0] != payloadVersion {
+		return nil, nil, errors.Errorf("unsupported payload version %d", payload[0])
+	}
+
+	headerLength := binary.BigEndian.Uint32(payload[1 : 1+headerLengthSize])
+	payload = payload[1+headerLengthSize:]
+	if uint64(len(payload)) < uint64(headerLength) {
+		return nil, nil, ErrInvalidPayload
+	}
+
+	metadata := &Metadata{}
+	if err := json.Unmarshal(payload[:headerLength], metadata); err != nil {
+		return nil, nil, errors.WithMessage(err, "unmarshal metadata")
+	}
+	return metadata, payload[headerLength:], nil
+}
@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	uid, gid := 1000, 1000
+	metadata := &Metadata{
+		Name:        "report.txt",
+		Mode:        0640,
+		ModTime:     time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		UID:         &uid,
+		GID:         &gid,
+		Xattrs:      map[string][]byte{"user.origin": []byte("scanner")},
+		Compression: "zstd",
+	}
+	data := []byte("some data")
+
+	payload, err := EncodePayload(metadata, data)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+
+	decodedMetadata, decodedData, err := DecodePayload(payload)
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	} else if !bytes.Equal(decodedData, data) {
+		t.Errorf("decoded data %q, want %q", decodedData, data)
+	} else if !reflect.DeepEqual(decodedMetadata, metadata) {
+		t.Errorf("decoded metadata %+v, want %+v", decodedMetadata, metadata)
+	}
+}
+
+func TestPayloadWithoutMetadata(t *testing.T) {
+	data := []byte("raw data, as stored before metadata was captured")
+
+	payload, err := EncodePayload(nil, data)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+
+	metadata, decodedData, err := DecodePayload(payload)
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	} else if metadata != nil || !bytes.Equal(decodedData, data) {
+		t.Errorf("decoded %+v and %q, want no metadata and %q", metadata, decodedData, data)
+	}
+}
+
+// A read-only mode is restored after extended attributes, which it would otherwise prevent from being set.
+func TestRestoreReadOnly(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gasper-metadata-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	filePath := filepath.Join(directory, "file")
+	if err := ioutil.WriteFile(filePath, []byte("data"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	captured, err := Capture(filePath, &Options{Ownership: true})
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	metadata := &Metadata{
+		Mode:    0444,
+		ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		UID:     captured.UID,
+		GID:     captured.GID,
+	}
+	if err := setXattr(filePath, "user.gasper-test", []byte("probe")); err == nil {
+		metadata.Xattrs = map[string][]byte{"user.gasper-test": []byte("value")}
+	}
+
+	if err := metadata.Restore(filePath, &Options{Ownership: true, Xattrs: true}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	restored, err := Capture(filePath, &Options{Ownership: true, Xattrs: true})
+	if err != nil {
+		t.Fatalf("capture restored: %v", err)
+	} else if restored.Mode != metadata.Mode {
+		t.Errorf("mode %v, want %v", restored.Mode, metadata.Mode)
+	} else if !restored.ModTime.Equal(metadata.ModTime) {
+		t.Errorf("modification time %v, want %v", restored.ModTime, metadata.ModTime)
+	} else if metadata.Xattrs != nil && !bytes.Equal(restored.Xattrs["user.gasper-test"], []byte("value")) {
+		t.Errorf("extended attribute %q, want %q", restored.Xattrs["user.gasper-test"], "value")
+	}
+}
@@ -0,0 +1,8 @@
+package metadata
+
+import "os"
+
+// Note: ownership isn't captured on windows.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"bytes"
+	"syscall"
+)
+
+func listXattrs(filePath string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(filePath, nil)
+	if err != nil || size == 0 {
+		return nil, ignoreUnsupported(err)
+	}
+
+	namesBuffer := make([]byte, size)
+	if size, err = syscall.Listxattr(filePath, namesBuffer); err != nil {
+		return nil, ignoreUnsupported(err)
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range bytes.Split(namesBuffer[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		valueSize, err := syscall.Getxattr(filePath, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, valueSize)
+		if valueSize, err = syscall.Getxattr(filePath, string(name), value); err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = value[:valueSize]
+	}
+	return xattrs, nil
+}
+
+func setXattr(filePath, name string, value []byte) error {
+	return syscall.Setxattr(filePath, name, value, 0)
+}
+
+// Filesystems without extended attributes support simply have none.
+func ignoreUnsupported(err error) error {
+	if err == syscall.ENOTSUP {
+		return nil
+	}
+	return err
+}
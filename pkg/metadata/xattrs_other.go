@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package metadata
+
+// Note: extended attributes are only captured on linux.
+func listXattrs(filePath string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func setXattr(filePath, name string, value []byte) error {
+	return nil
+}
@@ -0,0 +1,349 @@
+// Package placement decides which store each of a file's shares goes to, before anything is written.
+package placement
+
+import (
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Supported placement strategies.
+const (
+	// Shares go to stores in config order.
+	StrategyOrdered = "ordered"
+
+	// Like ordered, but starting from a different store for each file, so that shares spread evenly across stores
+	// over many files.
+	StrategyRoundRobin = "round-robin"
+
+	// Shares go to stores of as many different failure domains as possible, as told by store labels.
+	StrategySpread = "spread"
+
+	// Shares go to the stores having the most free space.
+	StrategyCapacity = "capacity"
+)
+
+// Labels the spread strategy tells failure domains apart by, most significant first, unless told otherwise.
+var DefaultSpreadLabels = []string{"region", "provider", "owner"}
+
+var (
+	ErrUnknownStrategy     = errors.New("unknown placement strategy")
+	ErrNotEnoughTargets    = errors.New("not enough available stores to place every share in its own store")
+	ErrNotEnoughCapacity   = errors.New("not enough stores with room for a share")
+	ErrPinnedStoreNotFound = errors.New("no available store is named after pin")
+	ErrUnknownShare        = errors.New("no such share")
+	ErrUnplannedShare      = errors.New("share has no store planned")
+	ErrStorePlannedTwice   = errors.New("store is planned for more than one share")
+)
+
+// Target is a store shares may be placed in, along with its labels (e.g. 'region', 'provider', 'owner').
+type Target struct {
+	Store  storesPkg.Store
+	Labels map[string]string
+}
+
+// Plan tells which target each share goes to, by share ID.
+type Plan map[string]*Target
+
+// Strategy picks a distinct target for each share.
+type Strategy interface {
+	// Plans shares' placement among the given targets, alongside the already planned shares (e.g. pinned ones).
+	// Size is the expected size of a share in bytes, or 0 if unknown.
+	Place(fileID string, shareIDs []string, size int64, targets []*Target, planned Plan) (Plan, error)
+}
+
+// Returns the strategy of the given name. Spread labels are only used by the spread strategy (defaulting to
+// DefaultSpreadLabels).
+func FromName(name string, spreadLabels []string) (Strategy, error) {
+	switch name {
+	case StrategyOrdered, "":
+		return &Ordered{}, nil
+	case StrategyRoundRobin:
+		return &RoundRobin{}, nil
+	case StrategySpread:
+		if len(spreadLabels) == 0 {
+			spreadLabels = DefaultSpreadLabels
+		}
+		return &Spread{Labels: spreadLabels}, nil
+	case StrategyCapacity:
+		return &Capacity{}, nil
+	}
+	return nil, errors.WithMessagef(ErrUnknownStrategy, "'%s'", name)
+}
+
+// Returns the IDs shares are split under, given their count.
+func ShareIDs(count byte) []string {
+	shareIDs := make([]string, 0, count)
+	for x := 1; x <= int(count); x++ {
+		shareIDs = append(shareIDs, strconv.Itoa(x))
+	}
+	return shareIDs
+}
+
+// Returns the targets' stores.
+func Stores(targets []*Target) []storesPkg.Store {
+	stores := make([]storesPkg.Store, 0, len(targets))
+	for _, target := range targets {
+		stores = append(stores, target.Store)
+	}
+	return stores
+}
+
+// Plans shares' placement: pinned shares (by share ID) go to the target named after their pin, and the strategy
+// places the rest among the remaining targets. The plan is validated before being returned.
+func Place(strategy Strategy, fileID string, shareIDs []string, size int64, targets []*Target,
+	pins map[string]string) (Plan, error) {
+	known := make(map[string]bool, len(shareIDs))
+	for _, shareID := range shareIDs {
+		known[shareID] = true
+	}
+
+	plan := make(Plan, len(shareIDs))
+	pinned := make(map[*Target]bool, len(pins))
+	for shareID, storeName := range pins {
+		if !known[shareID] {
+			return nil, errors.WithMessagef(ErrUnknownShare, "pinned share '%s'", shareID)
+		}
+
+		target := findTarget(targets, storeName)
+		if target == nil {
+			return nil, errors.WithMessagef(ErrPinnedStoreNotFound, "share '%s' pinned to '%s'", shareID, storeName)
+		} else if pinned[target] {
+			return nil, errors.WithMessagef(ErrStorePlannedTwice, "store '%s'", storeName)
+		}
+
+		plan[shareID] = target
+		pinned[target] = true
+	}
+
+	unpinnedShareIDs := make([]string, 0, len(shareIDs))
+	for _, shareID := range shareIDs {
+		if plan[shareID] == nil {
+			unpinnedShareIDs = append(unpinnedShareIDs, shareID)
+		}
+	}
+
+	unpinnedTargets := make([]*Target, 0, len(targets))
+	for _, target := range targets {
+		if !pinned[target] {
+			unpinnedTargets = append(unpinnedTargets, target)
+		}
+	}
+
+	if len(unpinnedShareIDs) > 0 {
+		strategyPlan, err := strategy.Place(fileID, unpinnedShareIDs, size, unpinnedTargets, plan)
+		if err != nil {
+			return nil, err
+		}
+
+		for shareID, target := range strategyPlan {
+			plan[shareID] = target
+		}
+	}
+
+	if err := plan.Validate(shareIDs); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Checks every share has a target planned, and no store is planned for more than one share (as a store holds a
+// single share of each file).
+func (p Plan) Validate(shareIDs []string) error {
+	planned := make(map[storesPkg.Store]string, len(p))
+	for _, shareID := range shareIDs {
+		target := p[shareID]
+		if target == nil || target.Store == nil {
+			return errors.WithMessagef(ErrUnplannedShare, "share '%s'", shareID)
+		}
+
+		if other, ok := planned[target.Store]; ok {
+			return errors.WithMessagef(ErrStorePlannedTwice, "store '%s' for shares '%s' and '%s'",
+				target.Store.Name(), other, shareID)
+		}
+		planned[target.Store] = shareID
+	}
+	return nil
+}
+
+// Orders the planned stores along the given shares, so that each share is put in its own (see Gasper's PutShares).
+func (p Plan) Stores(shares []*sharesPkg.Share) ([]storesPkg.Store, error) {
+	stores := make([]storesPkg.Store, 0, len(shares))
+	for _, share := range shares {
+		target := p[share.ID]
+		if target == nil {
+			return nil, errors.WithMessagef(ErrUnplannedShare, "share '%s'", share.ID)
+		}
+		stores = append(stores, target.Store)
+	}
+	return stores, nil
+}
+
+// Ordered places shares in targets' order.
+type Ordered struct{}
+
+func (o *Ordered) Place(fileID string, shareIDs []string, size int64, targets []*Target,
+	planned Plan) (Plan, error) {
+	return planInOrder(shareIDs, targets)
+}
+
+// RoundRobin places shares in targets' order, starting from a target derived from the file ID.
+type RoundRobin struct{}
+
+func (rr *RoundRobin) Place(fileID string, shareIDs []string, size int64, targets []*Target,
+	planned Plan) (Plan, error) {
+	return planInOrder(shareIDs, rotate(fileID, targets))
+}
+
+// Spread places each share in the target sharing the fewest labels with the already planned ones. Labels are
+// compared one at a time, most significant first: a target in an unused region beats one in an unused provider.
+// Targets lacking a label are considered a failure domain of their own. Ties go round-robin.
+type Spread struct {
+	Labels []string
+}
+
+func (s *Spread) Place(fileID string, shareIDs []string, size int64, targets []*Target,
+	planned Plan) (Plan, error) {
+	if len(targets) < len(shareIDs) {
+		return nil, errors.WithMessagef(ErrNotEnoughTargets, "%d shares, %d stores", len(shareIDs), len(targets))
+	}
+
+	// How many planned targets use each label value, by label.
+	used := make(map[string]map[string]int, len(s.Labels))
+	for _, label := range s.Labels {
+		used[label] = make(map[string]int)
+	}
+	for _, target := range planned {
+		s.use(target, used)
+	}
+
+	candidates := rotate(fileID, targets)
+	plan := make(Plan, len(shareIDs))
+	for _, shareID := range shareIDs {
+		best := -1
+		for i, candidate := range candidates {
+			if candidate != nil && (best < 0 || s.less(candidate, candidates[best], used)) {
+				best = i
+			}
+		}
+
+		target := candidates[best]
+		candidates[best] = nil
+		plan[shareID] = target
+		s.use(target, used)
+	}
+	return plan, nil
+}
+
+func (s *Spread) use(target *Target, used map[string]map[string]int) {
+	for _, label := range s.Labels {
+		if value, ok := target.Labels[label]; ok {
+			used[label][value]++
+		}
+	}
+}
+
+// Whether target a shares fewer labels with planned targets than target b does.
+func (s *Spread) less(a, b *Target, used map[string]map[string]int) bool {
+	for _, label := range s.Labels {
+		aUses, bUses := labelUses(a, label, used), labelUses(b, label, used)
+		if aUses != bUses {
+			return aUses < bUses
+		}
+	}
+	return false
+}
+
+func labelUses(target *Target, label string, used map[string]map[string]int) int {
+	value, ok := target.Labels[label]
+	if !ok {
+		return 0
+	}
+	return used[label][value]
+}
+
+// Capacity places shares in the targets having the most free space, skipping those without room for a share.
+// Targets which can't tell their free space (see stores' CapacityReporter) come last.
+type Capacity struct{}
+
+func (c *Capacity) Place(fileID string, shareIDs []string, size int64, targets []*Target,
+	planned Plan) (Plan, error) {
+	type candidate struct {
+		target *Target
+		free   uint64
+		known  bool
+	}
+
+	candidates := make([]*candidate, 0, len(targets))
+	for _, target := range targets {
+		cand := &candidate{target: target}
+		if reporter, ok := target.Store.(storesPkg.CapacityReporter); ok {
+			if free, err := reporter.FreeSpace(); err == nil {
+				cand.free, cand.known = free, true
+			}
+		}
+
+		if cand.known && size > 0 && cand.free < uint64(size) {
+			continue
+		}
+		candidates = append(candidates, cand)
+	}
+
+	if len(candidates) < len(shareIDs) {
+		return nil, errors.WithMessagef(ErrNotEnoughCapacity, "%d shares of %d bytes, %d stores with room",
+			len(shareIDs), size, len(candidates))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].known != candidates[j].known {
+			return candidates[i].known
+		}
+		return candidates[i].free > candidates[j].free
+	})
+
+	ordered := make([]*Target, 0, len(candidates))
+	for _, cand := range candidates {
+		ordered = append(ordered, cand.target)
+	}
+	return planInOrder(shareIDs, ordered)
+}
+
+// Places the i-th share in the i-th target.
+func planInOrder(shareIDs []string, targets []*Target) (Plan, error) {
+	if len(targets) < len(shareIDs) {
+		return nil, errors.WithMessagef(ErrNotEnoughTargets, "%d shares, %d stores", len(shareIDs), len(targets))
+	}
+
+	plan := make(Plan, len(shareIDs))
+	for i, shareID := range shareIDs {
+		plan[shareID] = targets[i]
+	}
+	return plan, nil
+}
+
+// Rotates targets to start from one derived from the file ID.
+func rotate(fileID string, targets []*Target) []*Target {
+	rotated := make([]*Target, 0, len(targets))
+	if len(targets) == 0 {
+		return rotated
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(fileID))
+	offset := int(hash.Sum32() % uint32(len(targets)))
+
+	rotated = append(rotated, targets[offset:]...)
+	return append(rotated, targets[:offset]...)
+}
+
+func findTarget(targets []*Target, storeName string) *Target {
+	for _, target := range targets {
+		if target.Store.Name() == storeName {
+			return target
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package placement
+
+import (
+	"github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+// Store which only has a name, and maybe free space.
+type fakeStore struct {
+	name  string
+	free  uint64
+	known bool
+}
+
+func (fs *fakeStore) Type() string                      { return "fake" }
+func (fs *fakeStore) Name() string                      { return fs.name }
+func (fs *fakeStore) Available() (bool, error)          { return true, nil }
+func (fs *fakeStore) Put(*shares.Share) error           { return nil }
+func (fs *fakeStore) Get(string) (*shares.Share, error) { return nil, storesPkg.ErrShareNotExists }
+func (fs *fakeStore) Delete(string) error               { return storesPkg.ErrShareNotExists }
+func (fs *fakeStore) List() ([]string, error)           { return nil, nil }
+func (fs *fakeStore) FreeSpace() (uint64, error) {
+	if !fs.known {
+		return 0, storesPkg.ErrCapacityUnknown
+	}
+	return fs.free, nil
+}
+
+func newTarget(name string, labels map[string]string) *Target {
+	return &Target{Store: &fakeStore{name: name}, Labels: labels}
+}
+
+func newTargets(names ...string) []*Target {
+	targets := make([]*Target, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, newTarget(name, nil))
+	}
+	return targets
+}
+
+// Returns the names of the stores planned for each share, in share order.
+func planNames(plan Plan, shareIDs []string) []string {
+	names := make([]string, 0, len(shareIDs))
+	for _, shareID := range shareIDs {
+		names = append(names, plan[shareID].Store.Name())
+	}
+	return names
+}
+
+func TestOrdered(t *testing.T) {
+	plan, err := Place(&Ordered{}, "file", ShareIDs(3), 0, newTargets("a", "b", "c", "d"), nil)
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	if names := planNames(plan, ShareIDs(3)); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("planned %v, want [a b c]", names)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	targets := newTargets("a", "b", "c", "d")
+
+	first := make(map[string]bool)
+	for _, fileID := range []string{"file-1", "file-2", "file-3", "file-4", "file-5", "file-6", "file-7", "file-8"} {
+		plan, err := Place(&RoundRobin{}, fileID, ShareIDs(2), 0, targets, nil)
+		if err != nil {
+			t.Fatalf("place '%s': %v", fileID, err)
+		}
+
+		again, err := Place(&RoundRobin{}, fileID, ShareIDs(2), 0, targets, nil)
+		if err != nil {
+			t.Fatalf("place '%s': %v", fileID, err)
+		} else if !reflect.DeepEqual(planNames(plan, ShareIDs(2)), planNames(again, ShareIDs(2))) {
+			t.Errorf("file '%s' planned differently twice", fileID)
+		}
+		first[plan["1"].Store.Name()] = true
+	}
+
+	if len(first) < 2 {
+		t.Errorf("every file starts from the same store")
+	}
+}
+
+func TestSpread(t *testing.T) {
+	targets := []*Target{
+		newTarget("eu-1", map[string]string{"region": "eu", "provider": "x"}),
+		newTarget("eu-2", map[string]string{"region": "eu", "provider": "y"}),
+		newTarget("us-1", map[string]string{"region": "us", "provider": "x"}),
+		newTarget("us-2", map[string]string{"region": "us", "provider": "y"}),
+		newTarget("ap-1", map[string]string{"region": "ap", "provider": "x"}),
+	}
+
+	plan, err := Place(&Spread{Labels: DefaultSpreadLabels}, "file", ShareIDs(3), 0, targets, nil)
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	regions := make(map[string]bool)
+	for _, target := range plan {
+		regions[target.Labels["region"]] = true
+	}
+	if len(regions) != 3 {
+		t.Errorf("shares span %d regions, want 3", len(regions))
+	}
+
+	// With a region of each planned, the next best spreads providers.
+	plan, err = Place(&Spread{Labels: DefaultSpreadLabels}, "file", ShareIDs(4), 0, targets, nil)
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	providers := make(map[string]int)
+	for _, target := range plan {
+		providers[target.Labels["provider"]]++
+	}
+	if providers["x"] != 2 || providers["y"] != 2 {
+		t.Errorf("shares per provider: %v, want 2 each", providers)
+	}
+}
+
+func TestSpreadAroundPins(t *testing.T) {
+	targets := []*Target{
+		newTarget("eu-1", map[string]string{"region": "eu"}),
+		newTarget("eu-2", map[string]string{"region": "eu"}),
+		newTarget("us-1", map[string]string{"region": "us"}),
+	}
+
+	plan, err := Place(&Spread{Labels: DefaultSpreadLabels}, "file", ShareIDs(2), 0, targets,
+		map[string]string{"1": "eu-1"})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	if names := planNames(plan, ShareIDs(2)); !reflect.DeepEqual(names, []string{"eu-1", "us-1"}) {
+		t.Errorf("planned %v, want [eu-1 us-1]", names)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	targets := []*Target{
+		{Store: &fakeStore{name: "small", free: 100, known: true}},
+		{Store: &fakeStore{name: "unknown"}},
+		{Store: &fakeStore{name: "large", free: 10000, known: true}},
+		{Store: &fakeStore{name: "medium", free: 1000, known: true}},
+	}
+
+	tests := []struct {
+		name   string
+		shares byte
+		size   int64
+		want   []string
+		err    error
+	}{
+		{name: "most free space first", shares: 2, size: 10, want: []string{"large", "medium"}},
+		{name: "unknown capacity last", shares: 4, size: 10, want: []string{"large", "medium", "small", "unknown"}},
+		{name: "stores without room skipped", shares: 3, size: 500, want: []string{"large", "medium", "unknown"}},
+		{name: "not enough room", shares: 3, size: 5000, err: ErrNotEnoughCapacity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := Place(&Capacity{}, "file", ShareIDs(test.shares), test.size, targets, nil)
+			if errors.Cause(err) != test.err {
+				t.Fatalf("place: %v, want %v", err, test.err)
+			} else if err != nil {
+				return
+			}
+
+			if names := planNames(plan, ShareIDs(test.shares)); !reflect.DeepEqual(names, test.want) {
+				t.Errorf("planned %v, want %v", names, test.want)
+			}
+		})
+	}
+}
+
+func TestPlaceErrors(t *testing.T) {
+	targets := newTargets("a", "b", "c")
+
+	tests := []struct {
+		name   string
+		shares byte
+		pins   map[string]string
+		err    error
+	}{
+		{name: "not enough stores", shares: 4, err: ErrNotEnoughTargets},
+		{name: "unknown pinned share", shares: 2, pins: map[string]string{"3": "a"}, err: ErrUnknownShare},
+		{name: "unknown pinned store", shares: 2, pins: map[string]string{"1": "z"}, err: ErrPinnedStoreNotFound},
+		{name: "store pinned twice", shares: 2, pins: map[string]string{"1": "a", "2": "a"}, err: ErrStorePlannedTwice},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Place(&Ordered{}, "file", ShareIDs(test.shares), 0, targets, test.pins)
+			if errors.Cause(err) != test.err {
+				t.Errorf("place: %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestPinnedShares(t *testing.T) {
+	plan, err := Place(&Ordered{}, "file", ShareIDs(3), 0, newTargets("a", "b", "c"), map[string]string{"2": "a"})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	if names := planNames(plan, ShareIDs(3)); !reflect.DeepEqual(names, []string{"b", "a", "c"}) {
+		t.Errorf("planned %v, want [b a c]", names)
+	}
+}
+
+func TestFromName(t *testing.T) {
+	for _, name := range []string{"", StrategyOrdered, StrategyRoundRobin, StrategySpread, StrategyCapacity} {
+		if _, err := FromName(name, nil); err != nil {
+			t.Errorf("strategy '%s': %v", name, err)
+		}
+	}
+
+	if _, err := FromName("random", nil); errors.Cause(err) != ErrUnknownStrategy {
+		t.Errorf("unknown strategy: %v, want %v", err, ErrUnknownStrategy)
+	}
+}
@@ -0,0 +1,94 @@
+package placement
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+var ErrInvalidRule = errors.New("invalid placement rule")
+
+// Rule caps how many of a file's shares may go to stores sharing a label value (anti-affinity), e.g. "no two shares
+// in the same zone", or "at most threshold-1 shares with the same provider" so that no single provider can recover the
+// file on its own. Stores lacking the label aren't counted.
+type Rule struct {
+	Label string `json:"label"`
+
+	// Most shares per label value, if set.
+	MaxShares int `json:"max-shares,omitempty"`
+
+	// Whether shares per label value are capped below the minimum shares threshold.
+	BelowThreshold bool `json:"below-threshold,omitempty"`
+}
+
+// Violation describes a label value holding more shares than a rule allows.
+type Violation struct {
+	Rule   *Rule
+	Value  string
+	Shares int
+	Limit  int
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%d shares with %s '%s', at most %d allowed", v.Shares, v.Rule.Label, v.Value, v.Limit)
+}
+
+func (r *Rule) Validate() error {
+	if r.Label == "" {
+		return errors.WithMessage(ErrInvalidRule, "no label")
+	} else if r.MaxShares < 0 {
+		return errors.WithMessagef(ErrInvalidRule, "negative max shares for label '%s'", r.Label)
+	} else if r.MaxShares == 0 && !r.BelowThreshold {
+		return errors.WithMessagef(ErrInvalidRule, "neither max shares nor below threshold for label '%s'", r.Label)
+	}
+	return nil
+}
+
+// Returns how many shares rule allows per label value, given the minimum shares threshold.
+func (r *Rule) Limit(threshold int) int {
+	limit := r.MaxShares
+	if r.BelowThreshold && (limit == 0 || threshold-1 < limit) {
+		limit = threshold - 1
+	}
+	return limit
+}
+
+// Checks the targets holding (or planned to hold) each of a file's shares against rules, given its minimum shares
+// threshold. Returns the violations, sorted by label and value.
+func CheckRules(targets []*Target, rules []*Rule, threshold int) []*Violation {
+	violations := make([]*Violation, 0)
+	for _, rule := range rules {
+		shares := make(map[string]int)
+		for _, target := range targets {
+			if value, ok := target.Labels[rule.Label]; ok {
+				shares[value]++
+			}
+		}
+
+		limit := rule.Limit(threshold)
+		for value, count := range shares {
+			if count > limit {
+				violations = append(violations, &Violation{Rule: rule, Value: value, Shares: count, Limit: limit})
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Rule.Label != violations[j].Rule.Label {
+			return violations[i].Rule.Label < violations[j].Rule.Label
+		}
+		return violations[i].Value < violations[j].Value
+	})
+	return violations
+}
+
+// Returns the planned targets, in the order of the given share IDs.
+func (p Plan) Targets(shareIDs []string) []*Target {
+	targets := make([]*Target, 0, len(shareIDs))
+	for _, shareID := range shareIDs {
+		if target := p[shareID]; target != nil {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,104 @@
+package placement
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  *Rule
+		valid bool
+	}{
+		{name: "max shares", rule: &Rule{Label: "zone", MaxShares: 1}, valid: true},
+		{name: "below threshold", rule: &Rule{Label: "provider", BelowThreshold: true}, valid: true},
+		{name: "both", rule: &Rule{Label: "provider", MaxShares: 2, BelowThreshold: true}, valid: true},
+		{name: "no label", rule: &Rule{MaxShares: 1}},
+		{name: "negative max shares", rule: &Rule{Label: "zone", MaxShares: -1}},
+		{name: "no limit", rule: &Rule{Label: "zone"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.Validate()
+			if test.valid && err != nil {
+				t.Errorf("validate: %v, want valid", err)
+			} else if !test.valid && errors.Cause(err) != ErrInvalidRule {
+				t.Errorf("validate: %v, want %v", err, ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func TestRuleLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      *Rule
+		threshold int
+		want      int
+	}{
+		{name: "max shares", rule: &Rule{Label: "zone", MaxShares: 2}, threshold: 5, want: 2},
+		{name: "below threshold", rule: &Rule{Label: "zone", BelowThreshold: true}, threshold: 3, want: 2},
+		{name: "max shares tighter", rule: &Rule{Label: "zone", MaxShares: 1, BelowThreshold: true}, threshold: 3,
+			want: 1},
+		{name: "threshold tighter", rule: &Rule{Label: "zone", MaxShares: 4, BelowThreshold: true}, threshold: 3,
+			want: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.rule.Limit(test.threshold); got != test.want {
+				t.Errorf("limit: %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	targets := []*Target{
+		newTarget("a", map[string]string{"zone": "z1", "provider": "x"}),
+		newTarget("b", map[string]string{"zone": "z1", "provider": "x"}),
+		newTarget("c", map[string]string{"zone": "z2", "provider": "x"}),
+		newTarget("d", map[string]string{"zone": "z2"}),
+		newTarget("e", nil),
+	}
+
+	zoneRule := &Rule{Label: "zone", MaxShares: 1}
+	providerRule := &Rule{Label: "provider", BelowThreshold: true}
+	regionRule := &Rule{Label: "region", MaxShares: 1}
+
+	violations := CheckRules(targets, []*Rule{zoneRule, providerRule, regionRule}, 3)
+	want := []*Violation{
+		{Rule: providerRule, Value: "x", Shares: 3, Limit: 2},
+		{Rule: zoneRule, Value: "z1", Shares: 2, Limit: 1},
+		{Rule: zoneRule, Value: "z2", Shares: 2, Limit: 1},
+	}
+
+	if len(violations) != len(want) {
+		t.Fatalf("%d violations (%v), want %d", len(violations), violations, len(want))
+	}
+	for i, violation := range violations {
+		if *violation != *want[i] {
+			t.Errorf("violation %d: %s, want %s", i, violation, want[i])
+		}
+	}
+
+	if violations := CheckRules(targets[2:], []*Rule{zoneRule, providerRule}, 3); len(violations) != 1 {
+		t.Errorf("%d violations (%v), want 1", len(violations), violations)
+	}
+
+	if violations := CheckRules(targets, nil, 3); len(violations) != 0 {
+		t.Errorf("%d violations without rules, want none", len(violations))
+	}
+}
+
+func TestPlanTargets(t *testing.T) {
+	targets := newTargets("a", "b", "c")
+	plan := Plan{"1": targets[2], "3": targets[0]}
+
+	got := plan.Targets(ShareIDs(3))
+	if len(got) != 2 || got[0] != targets[2] || got[1] != targets[0] {
+		t.Errorf("targets: %v, want [c a]", Stores(got))
+	}
+}
@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"github.com/codahale/sss"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+// Size of an encoded key point: policy node index, x-coordinate, and y-value.
+const policyPointSize = 2 + randomKeySize
+
+// policyPoint is a point of a policy node's key polynomial, held by a party.
+type policyPoint struct {
+	node byte
+	x    byte
+	y    []byte
+}
+
+// Splits payload along manifest's access policy: payload is encrypted with a random key, which is split among the
+// policy root's members. A threshold node member's point is split again among its own members, and so on, while a
+// party of weight w is given w points. Each share holds its party's points followed by the ciphertext.
+// Share count, minimum shares threshold and ciphertext digest are set in manifest.
+func splitPolicy(payload []byte, manifest *sharesPkg.Manifest) (map[byte][]byte, error) {
+	if manifest.Policy == nil {
+		return nil, ErrNoPolicy
+	} else if err := manifest.Policy.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "validate policy")
+	}
+
+	key, ciphertext, err := encryptWithRandomKey(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make(map[string][]*policyPoint)
+	node := byte(0)
+	if err := splitPolicyNode(manifest.Policy, key, &node, points); err != nil {
+		return nil, errors.WithMessage(err, "split key")
+	}
+
+	parties := manifest.Policy.Parties()
+	manifest.ShareCount = byte(len(parties))
+	manifest.MinSharesThreshold = byte(manifest.Policy.MinParties())
+	manifest.CiphertextDigest = sharesPkg.ShareDigest(ciphertext)
+
+	sharesBytes := make(map[byte][]byte, len(parties))
+	for i, party := range parties {
+		partyPoints := points[party]
+
+		data := make([]byte, 0, 1+len(partyPoints)*policyPointSize+len(ciphertext))
+		data = append(data, byte(len(partyPoints)))
+		for _, point := range partyPoints {
+			data = append(append(data, point.node, point.x), point.y...)
+		}
+		sharesBytes[byte(i+1)] = append(data, ciphertext...)
+	}
+	return sharesBytes, nil
+}
+
+// Splits a policy node's secret among its members, appending their points to points (by party).
+// Node indexes are assigned in pre-order.
+func splitPolicyNode(policy *sharesPkg.Policy, secret []byte, node *byte, points map[string][]*policyPoint) error {
+	index := *node
+	*node++
+
+	totalWeight := 0
+	for _, member := range policy.Members {
+		totalWeight += member.MemberWeight()
+	}
+
+	var ys map[byte][]byte
+	if policy.Threshold == 1 {
+		ys = make(map[byte][]byte, totalWeight)
+		for x := 1; x <= totalWeight; x++ {
+			ys[byte(x)] = secret
+		}
+	} else {
+		var err error
+		if ys, err = sss.Split(byte(totalWeight), policy.Threshold, secret); err != nil {
+			return err
+		}
+	}
+
+	x := 1
+	for _, member := range policy.Members {
+		if !member.IsLeaf() {
+			if err := splitPolicyNode(member, ys[byte(x)], node, points); err != nil {
+				return err
+			}
+			x++
+			continue
+		}
+
+		for i := 0; i < member.MemberWeight(); i++ {
+			point := &policyPoint{node: index, x: byte(x), y: ys[byte(x)]}
+			points[member.Party] = append(points[member.Party], point)
+			x++
+		}
+	}
+	return nil
+}
+
+// Recovers payload out of shares split along manifest's access policy.
+// Shares failing their integrity tag, or which can't be parsed, are added to recovery's bad shares, and good ones to
+// its used shares. If good shares don't satisfy the policy, the returned error explains what is still missing.
+func recoverPolicy(shares []*sharesPkg.Share, manifest *sharesPkg.Manifest, recovery *Recovery) ([]byte, error) {
+	var (
+		points     = make(map[byte]map[byte][]byte)
+		present    = make(map[string]bool, len(shares))
+		ciphertext []byte
+	)
+
+	for _, share := range shares {
+		party := manifest.Party(share.ID)
+		sharePoints, shareCiphertext, err := parsePolicyShare(share)
+		if party == "" || present[party] || err != nil || share.Manifest == nil || !manifest.CheckShare(share) ||
+			sharesPkg.ShareDigest(shareCiphertext) != manifest.CiphertextDigest {
+			recovery.Bad = append(recovery.Bad, share)
+			continue
+		}
+
+		for _, point := range sharePoints {
+			if points[point.node] == nil {
+				points[point.node] = make(map[byte][]byte)
+			}
+			points[point.node][point.x] = point.y
+		}
+
+		present[party] = true
+		ciphertext = shareCiphertext
+		recovery.Used = append(recovery.Used, share)
+	}
+
+	if !manifest.Policy.Satisfied(present) {
+		return nil, errors.WithMessagef(ErrPolicyNotSatisfied, "still missing %s", manifest.Policy.Explain(present))
+	}
+
+	node := byte(0)
+	key := combinePolicyNode(manifest.Policy, &node, points)
+	if key == nil {
+		return nil, errors.WithMessage(ErrPolicyNotSatisfied, "not enough key points")
+	}
+	return decryptWithKey(key, ciphertext)
+}
+
+// Combines a policy node's secret out of its members' points (by node index, then x-coordinate), recursively
+// combining threshold node members. Returns nil if there aren't enough points.
+func combinePolicyNode(policy *sharesPkg.Policy, node *byte, points map[byte]map[byte][]byte) []byte {
+	index := *node
+	*node++
+
+	available := make(map[byte][]byte, len(policy.Members))
+	x := 1
+	for _, member := range policy.Members {
+		if !member.IsLeaf() {
+			if y := combinePolicyNode(member, node, points); y != nil {
+				available[byte(x)] = y
+			}
+			x++
+			continue
+		}
+
+		for i := 0; i < member.MemberWeight(); i++ {
+			if y, ok := points[index][byte(x)]; ok {
+				available[byte(x)] = y
+			}
+			x++
+		}
+	}
+
+	if len(available) < int(policy.Threshold) {
+		return nil
+	}
+
+	subset := make(map[byte][]byte, policy.Threshold)
+	for x, y := range available {
+		if len(subset) == int(policy.Threshold) {
+			break
+		}
+		subset[x] = y
+	}
+
+	if policy.Threshold == 1 {
+		for _, y := range subset {
+			return y
+		}
+	}
+	return sss.Combine(subset)
+}
+
+func parsePolicyShare(share *sharesPkg.Share) ([]*policyPoint, []byte, error) {
+	if len(share.Data) < 1 {
+		return nil, nil, errors.New("share is too short")
+	}
+
+	count := int(share.Data[0])
+	if len(share.Data) < 1+count*policyPointSize {
+		return nil, nil, errors.New("share is too short")
+	}
+
+	points := make([]*policyPoint, 0, count)
+	for i := 0; i < count; i++ {
+		encoded := share.Data[1+i*policyPointSize : 1+(i+1)*policyPointSize]
+		points = append(points, &policyPoint{node: encoded[0], x: encoded[1], y: encoded[2:]})
+	}
+	return points, share.Data[1+count*policyPointSize:], nil
+}
+
+// Orders stores so that each share goes to its party's store - that is, the store named after the party.
+// Returns ErrPartyStoreNotFound if any party has no store.
+func PolicyStores(sharedFile *sharesPkg.SharedFile, stores []storesPkg.Store) ([]storesPkg.Store, error) {
+	byName := make(map[string]storesPkg.Store, len(stores))
+	for _, store := range stores {
+		byName[store.Name()] = store
+	}
+
+	targets := make([]storesPkg.Store, 0, len(sharedFile.Shares))
+	for _, share := range sharedFile.Shares {
+		party := sharedFile.Manifest.Party(share.ID)
+		store, ok := byName[party]
+		if !ok {
+			return nil, errors.WithMessagef(ErrPartyStoreNotFound, "party '%s'", party)
+		}
+		targets = append(targets, store)
+	}
+	return targets, nil
+}
+
+// Explains what the given placements still miss to satisfy their access policy, or returns an empty string if they
+// satisfy it (or weren't split along a policy).
+func PolicyMissing(placements []*Placement) string {
+	manifest := PlacementsManifest(placements)
+	if manifest == nil || manifest.Policy == nil {
+		return ""
+	}
+
+	present := make(map[string]bool, len(placements))
+	for _, placement := range placements {
+		if party := manifest.Party(placement.Share.ID); party != "" {
+			present[party] = true
+		}
+	}
+	return manifest.Policy.Explain(present)
+}
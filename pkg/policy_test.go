@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"bytes"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// "Any 2 admins, or 1 admin plus 3 engineers (where Erin counts twice)".
+func testPolicy() *sharesPkg.Policy {
+	party := func(name string, weight byte) *sharesPkg.Policy {
+		return &sharesPkg.Policy{Party: name, Weight: weight}
+	}
+
+	return &sharesPkg.Policy{Threshold: 1, Members: []*sharesPkg.Policy{
+		{Threshold: 2, Members: []*sharesPkg.Policy{party("alice", 0), party("bob", 0)}},
+		{Threshold: 2, Members: []*sharesPkg.Policy{
+			{Threshold: 1, Members: []*sharesPkg.Policy{party("alice", 0), party("bob", 0)}},
+			{Threshold: 3, Members: []*sharesPkg.Policy{party("carol", 0), party("dave", 0), party("erin", 2)}},
+		}},
+	}}
+}
+
+func TestSplitCombinePolicy(t *testing.T) {
+	data := testData(11, 5000)
+	g := newTestGasper(t, nil, true)
+
+	sharedFile, err := g.Split("file", data, nil, &sharesPkg.Manifest{
+		Scheme: sharesPkg.SchemePolicy,
+		Policy: testPolicy(),
+	})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	} else if sharedFile.Manifest.ShareCount != 5 || sharedFile.Manifest.MinSharesThreshold != 2 {
+		t.Fatalf("%d shares of threshold %d, want 5 of threshold 2", sharedFile.Manifest.ShareCount,
+			sharedFile.Manifest.MinSharesThreshold)
+	}
+
+	byParty := make(map[string]*sharesPkg.Share, len(sharedFile.Shares))
+	for _, share := range sharedFile.Shares {
+		byParty[sharedFile.Manifest.Party(share.ID)] = share
+	}
+
+	tests := []struct {
+		parties   []string
+		satisfied bool
+	}{
+		{parties: []string{"alice", "bob"}, satisfied: true},
+		{parties: []string{"alice", "carol", "dave", "erin"}, satisfied: true},
+		{parties: []string{"erin", "bob", "carol"}, satisfied: true},
+		{parties: []string{"alice", "bob", "carol", "dave", "erin"}, satisfied: true},
+		{parties: []string{"alice", "erin"}},
+		{parties: []string{"carol", "dave", "erin"}},
+		{parties: []string{"bob"}},
+	}
+
+	for _, test := range tests {
+		shares := make([]*sharesPkg.Share, 0, len(test.parties))
+		for _, party := range test.parties {
+			shares = append(shares, byParty[party])
+		}
+
+		recovery, err := g.Recover(&sharesPkg.SharedFile{ID: "file", Checksum: sharedFile.Checksum, Shares: shares})
+		if !test.satisfied {
+			if errors.Cause(err) != ErrPolicyNotSatisfied {
+				t.Errorf("%v: recover: %v, want %v", test.parties, err, ErrPolicyNotSatisfied)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: recover: %v", test.parties, err)
+		} else if !bytes.Equal(recovery.Data, data) {
+			t.Errorf("%v: recovered data differs", test.parties)
+		}
+	}
+}
+
+// A party's tampered share is excluded, and the policy is then judged on the remaining parties.
+func TestRecoverPolicyBadShare(t *testing.T) {
+	g := newTestGasper(t, nil, false)
+	sharedFile, err := g.Split("file", testData(12, 1000), nil, &sharesPkg.Manifest{
+		Scheme: sharesPkg.SchemePolicy,
+		Policy: testPolicy(),
+	})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	byID := sharesByID(sharedFile)
+	corruptShare(byID["2"]) // Bob's.
+
+	recovery, err := g.Recover(&sharesPkg.SharedFile{ID: "file", Checksum: sharedFile.Checksum,
+		Shares: pickShares(byID, "1", "2")})
+	if errors.Cause(err) != ErrPolicyNotSatisfied {
+		t.Fatalf("recover: %v, want %v", err, ErrPolicyNotSatisfied)
+	} else if len(recovery.Bad) != 1 || recovery.Bad[0].ID != "2" {
+		t.Errorf("bad shares: %v, want bob's", sortedShareIDs(recovery.Bad))
+	}
+}
+
+func TestSplitPolicyInvalid(t *testing.T) {
+	g := newTestGasper(t, nil, false)
+	for _, policy := range []*sharesPkg.Policy{nil, {Threshold: 3, Members: []*sharesPkg.Policy{{Party: "alice"}}}} {
+		if _, err := g.Split("file", []byte("data"), nil, &sharesPkg.Manifest{
+			Scheme: sharesPkg.SchemePolicy,
+			Policy: policy,
+		}); err == nil {
+			t.Errorf("split along %+v", policy)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package pkg
+
+import (
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+)
+
+// Refreshes a shared file's shares (proactive resharing), without changing the file itself.
+// Refreshing is reshaping using the file's current share count and minimum shares threshold: a brand-new polynomial
+// is generated under the next share generation, so that previously leaked shares can no longer be combined with
+// current ones.
+// Returns the refreshed shared file, alongside non-fatal store failures.
+func (g *Gasper) Refresh(fileID, checksum string, placements []*Placement, stores []storesPkg.Store,
+	shareCount, minSharesThreshold byte) (*sharesPkg.SharedFile, []*StoreError, error) {
+	return g.Reshape(fileID, checksum, placements, stores, shareCount, minSharesThreshold)
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"bytes"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"strconv"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	data := testData(8, 5000)
+	stores, cleanup := newTestStores(t, 3)
+	defer cleanup()
+
+	g := newTestGasper(t, stores, true)
+	sharedFile, oldPlacements := storeTestData(t, g, "file", data, stores, 3, 2)
+
+	if _, _, err := g.Refresh("file", sharedFile.Checksum, oldPlacements, stores, 3, 2); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	placements, _ := g.CollectShares("file", stores)
+	if len(placements) != 3 {
+		t.Fatalf("%d refreshed shares, want 3", len(placements))
+	}
+
+	// Any two refreshed shares combine with each other.
+	for i := range placements {
+		pair := []*Placement{placements[i], placements[(i+1)%len(placements)]}
+		combined, err := g.Combine(SharedFileFromPlacements("file", sharedFile.Checksum, pair))
+		if err != nil {
+			t.Fatalf("combine refreshed shares %s and %s: %v", pair[0].Share.ID, pair[1].Share.ID, err)
+		} else if !bytes.Equal(combined, data) {
+			t.Errorf("refreshed shares %s and %s: combined data differs", pair[0].Share.ID, pair[1].Share.ID)
+		}
+	}
+
+	// A leaked old share can't be combined with a refreshed one, and is told apart as stale.
+	for _, placement := range placements {
+		if placement.Share.Generation() <= oldPlacements[0].Share.Generation() {
+			t.Errorf("refreshed share %s of generation %d, not above %d", placement.Share.ID,
+				placement.Share.Generation(), oldPlacements[0].Share.Generation())
+		}
+	}
+
+	mixed := []*Placement{oldPlacements[0], placements[1]}
+	if _, err := g.Combine(SharedFileFromPlacements("file", sharedFile.Checksum, mixed)); err != ErrMixedGenerations {
+		t.Errorf("combine old and refreshed shares: %v, want %v", err, ErrMixedGenerations)
+	}
+
+	current, stale := SelectGeneration(append(append([]*Placement{}, oldPlacements...), placements...))
+	if len(current) != 3 || len(stale) != 3 || current[0].Share.Generation() == stale[0].Share.Generation() {
+		t.Errorf("selected %d current and %d stale shares, want 3 of each", len(current), len(stale))
+	}
+	for _, placement := range current {
+		if placement.Share.Generation() != placements[0].Share.Generation() {
+			t.Errorf("share %s of generation %d selected", placement.Share.ID, placement.Share.Generation())
+		}
+	}
+}
+
+// Refreshing keeps the file's shape: asking for another one fails.
+func TestRefreshInvalidShape(t *testing.T) {
+	stores, cleanup := newTestStores(t, 3)
+	defer cleanup()
+
+	g := newTestGasper(t, stores, false)
+	sharedFile, placements := storeTestData(t, g, "file", testData(9, 100), stores, 3, 2)
+
+	_, _, err := g.Refresh("file", sharedFile.Checksum, placements, stores, 2, 3)
+	if errors.Cause(err) != ErrInvalidSharesThreshold {
+		t.Errorf("refresh: %v, want %v", err, ErrInvalidSharesThreshold)
+	}
+}
+
+// Placements of shares of the given generations, each of threshold 2 (or without manifest, for generation 0).
+func generationPlacements(generations ...uint64) []*Placement {
+	placements := make([]*Placement, 0, len(generations))
+	for i, generation := range generations {
+		share := &sharesPkg.Share{ID: strconv.Itoa(i + 1), FileID: "file"}
+		if generation > 0 {
+			share.Manifest = &sharesPkg.Manifest{Generation: generation, ShareCount: 3, MinSharesThreshold: 2}
+		}
+		placements = append(placements, &Placement{Share: share})
+	}
+	return placements
+}
+
+func TestSelectGeneration(t *testing.T) {
+	tests := []struct {
+		name        string
+		generations []uint64
+		current     uint64
+		stale       int
+	}{
+		{name: "single generation", generations: []uint64{1, 1, 1}, current: 1},
+		{name: "latest generation", generations: []uint64{1, 2, 1, 2, 2}, current: 2, stale: 2},
+		{name: "latest below threshold", generations: []uint64{1, 1, 2}, current: 1, stale: 1},
+		{name: "none reaches threshold", generations: []uint64{1, 2, 3}, current: 3, stale: 2},
+		{name: "without manifests", generations: []uint64{0, 0}, current: 0},
+		{name: "manifest over none", generations: []uint64{0, 4, 4}, current: 4, stale: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, stale := SelectGeneration(generationPlacements(test.generations...))
+			if len(stale) != test.stale || len(current) != len(test.generations)-test.stale {
+				t.Fatalf("%d current and %d stale shares, want %d stale", len(current), len(stale), test.stale)
+			}
+
+			for _, placement := range current {
+				if placement.Share.Generation() != test.current {
+					t.Errorf("current share of generation %d, want %d", placement.Share.Generation(), test.current)
+				}
+			}
+			for _, placement := range stale {
+				if placement.Share.Generation() == test.current {
+					t.Errorf("stale share of the current generation %d", test.current)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"github.com/gasper/internal/encryption"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+// RekeyOptions controls how a shared file is rekeyed.
+type RekeyOptions struct {
+	// Encryption settings to re-encrypt the file with.
+	Encryption *encryption.Settings
+
+	// Whether to keep the current file ID, or to produce a new one.
+	KeepFileID bool
+
+	ShareCount         byte
+	MinSharesThreshold byte
+}
+
+// Rekeys a shared file, without ever writing its plaintext to disk.
+// Collected shares are combined and decrypted in memory using current encryption settings, then re-encrypted using
+// the new ones and re-split into shares (keeping file's metadata), which are put in the given stores. Old shares are
+// deleted only after the new shares were verified readable.
+// Histories holding a version of the file are then rewritten (see rewriteHistories), so that they follow its new file
+// ID and encryption settings. They are looked for beforehand, and every store must be listed for that.
+// Returns the new shared file, alongside non-fatal store failures.
+func (g *Gasper) Rekey(fileID, checksum string, placements []*Placement, stores []storesPkg.Store,
+	options *RekeyOptions) (*sharesPkg.SharedFile, []*StoreError, error) {
+	if options.MinSharesThreshold > options.ShareCount {
+		return nil, nil, ErrInvalidSharesThreshold
+	} else if err := checkPolicyShape(placements, options.ShareCount, options.MinSharesThreshold); err != nil {
+		return nil, nil, err
+	}
+
+	current, _ := SelectGeneration(placements)
+	recovery, err := g.Recover(SharedFileFromPlacements(fileID, checksum, current))
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "combine current shares")
+	}
+
+	rekeyed, err := NewGasper(g.stores, options.Encryption)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "initialize rekeyed gasper")
+	}
+
+	refs, err := g.findHistories(fileID, stores, rekeyed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newFileID := fileID
+	if !options.KeepFileID {
+		if newFileID, err = g.UniqueFileID(); err != nil {
+			return nil, nil, err
+		} else if err := g.CheckFileIDAvailable(newFileID, stores); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	template := resplitTemplate(placements, options.ShareCount, options.MinSharesThreshold)
+	if !options.KeepFileID {
+		template.Generation = 0
+	}
+
+	newSharedFile, err := rekeyed.Split(newFileID, recovery.Data, recovery.Metadata, template)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "split rekeyed data")
+	}
+
+	_, storeErrors, err := g.replaceShares(rekeyed, fileID, placements, newSharedFile, options.MinSharesThreshold,
+		false, stores)
+	if err != nil {
+		return nil, storeErrors, err
+	}
+
+	historyErrors, err := g.rewriteHistories(refs, rekeyed, fileID, newFileID, stores)
+	storeErrors = append(storeErrors, historyErrors...)
+	if err != nil {
+		return newSharedFile, storeErrors, errors.WithMessage(err, "rewrite histories")
+	}
+
+	return newSharedFile, storeErrors, nil
+}
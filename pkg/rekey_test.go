@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/gasper/internal/encryption"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestRekey(t *testing.T) {
+	data := testData(5, 5000)
+	rekeyedSettings := &encryption.Settings{TurnedOn: true, Salt: "fedcba9876543210fedcba9876543210"}
+
+	tests := []struct {
+		name       string
+		encrypted  bool
+		keepFileID bool
+	}{
+		{name: "new salt, new file ID", encrypted: true},
+		{name: "new salt, same file ID", encrypted: true, keepFileID: true},
+		{name: "encrypt, same file ID", keepFileID: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stores, cleanup := newTestStores(t, 4)
+			defer cleanup()
+
+			g := newTestGasper(t, stores, test.encrypted)
+			sharedFile, placements := storeTestData(t, g, "file", data, stores[:3], 3, 2)
+
+			rekeyedFile, _, err := g.Rekey("file", sharedFile.Checksum, placements, stores, &RekeyOptions{
+				Encryption:         rekeyedSettings,
+				KeepFileID:         test.keepFileID,
+				ShareCount:         4,
+				MinSharesThreshold: 3,
+			})
+			if err != nil {
+				t.Fatalf("rekey: %v", err)
+			}
+
+			if test.keepFileID != (rekeyedFile.ID == "file") {
+				t.Errorf("rekeyed file ID: '%s'", rekeyedFile.ID)
+			}
+			if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, []string{rekeyedFile.ID}) {
+				t.Errorf("stored file IDs: %v, want [%s]", fileIDs, rekeyedFile.ID)
+			}
+
+			rekeyed, err := NewGasper(stores, rekeyedSettings)
+			if err != nil {
+				t.Fatalf("new gasper: %v", err)
+			}
+
+			recovered := recoverTestData(t, rekeyed, rekeyedFile.ID, rekeyedFile.Checksum, stores)
+			if !bytes.Equal(recovered, data) {
+				t.Error("rekeyed data differs")
+			}
+
+			current, _ := g.CollectShares(rekeyedFile.ID, stores)
+			if manifest := PlacementsManifest(current); len(current) != 4 || manifest.MinSharesThreshold != 3 {
+				t.Errorf("%d shares of threshold %d, want 4 of threshold 3", len(current), manifest.MinSharesThreshold)
+			}
+		})
+	}
+}
+
+// New shares are staged, verified and switched over to the file's ID, or rolled back leaving old shares untouched.
+func TestReplaceShares(t *testing.T) {
+	oldData, newData := testData(6, 5000), testData(7, 5000)
+
+	tests := []struct {
+		name       string
+		requireAll bool
+		fault      func(stores []*faultyStore)
+		err        error
+
+		// Whether old shares are left untouched, and whether the staged shares are kept.
+		untouched bool
+		staged    bool
+	}{
+		{name: "replaced", fault: func([]*faultyStore) {}},
+		{
+			name: "staged shares unreadable",
+			fault: func(stores []*faultyStore) {
+				for _, store := range stores {
+					store.failGet = func(fileID string) bool { return fileID == StagingID("file") }
+				}
+			},
+			err:       ErrNotEnoughShares,
+			untouched: true,
+		},
+		{
+			name:       "not all shares put",
+			requireAll: true,
+			fault: func(stores []*faultyStore) {
+				stores[1].failPut = func(share *sharesPkg.Share) bool { return true }
+			},
+			err:       ErrNotAllSharesPut,
+			untouched: true,
+		},
+		{
+			name: "switching over fails",
+			fault: func(stores []*faultyStore) {
+				stores[2].failPut = func(share *sharesPkg.Share) bool { return share.FileID == "file" }
+			},
+			err:    ErrSwitchOverFailed,
+			staged: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			faulty, stores, cleanup := newFaultyTestStores(t, 3)
+			defer cleanup()
+
+			g := newTestGasper(t, stores, true)
+			oldFile, oldPlacements := storeTestData(t, g, "file", oldData, stores, 3, 2)
+
+			newFile, err := g.Split("file", newData, nil, resplitTemplate(oldPlacements, 3, 2))
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+
+			test.fault(faulty)
+			_, _, err = g.replaceShares(g, "file", oldPlacements, newFile, 2, test.requireAll, stores)
+			if errors.Cause(err) != test.err {
+				t.Fatalf("replace shares: %v, want %v", err, test.err)
+			}
+
+			for _, store := range faulty {
+				store.failPut, store.failGet = nil, nil
+			}
+
+			wantData, wantChecksum := newData, newFile.Checksum
+			if test.untouched {
+				wantData, wantChecksum = oldData, oldFile.Checksum
+			}
+			if recovered := recoverTestData(t, g, "file", wantChecksum, stores); !bytes.Equal(recovered, wantData) {
+				t.Error("recovered data differs")
+			}
+
+			wantFileIDs := []string{"file"}
+			if test.staged {
+				wantFileIDs = append(wantFileIDs, StagingID("file"))
+				staged := recoverTestData(t, g, StagingID("file"), newFile.Checksum, stores)
+				if !bytes.Equal(staged, newData) {
+					t.Error("staged data differs")
+				}
+			}
+			if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, wantFileIDs) {
+				t.Errorf("stored file IDs: %v, want %v", fileIDs, wantFileIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"github.com/gasper/internal/gf256"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// RepairReport describes a finished repair.
+type RepairReport struct {
+	// Share IDs which were missing, or corrupt, before repairing.
+	MissingShareIDs []string
+
+	// Placements of regenerated shares.
+	Repaired []*Placement
+
+	// Share IDs which couldn't be placed, for lack of healthy stores.
+	Unplaced []string
+}
+
+// Repairs a shared file by regenerating its missing or corrupt shares.
+// Surviving shares of the current generation are first verified to combine into the original file, excluding corrupt
+// ones (see Recover). Missing (or corrupt) share IDs are then recomputed from the very same polynomial (by
+// interpolating the survivors), so they are interchangeable with the lost ones, and placed on the given stores which
+// don't hold a current share of the file.
+// Share count is taken from the shares' manifest, falling back to the given one for shares stored without a manifest.
+// Returns a repair report, alongside non-fatal store failures.
+func (g *Gasper) Repair(fileID, checksum string, placements []*Placement, stores []storesPkg.Store,
+	shareCount byte) (*RepairReport, []*StoreError, error) {
+	current, _ := SelectGeneration(placements)
+	recovery, err := g.Recover(SharedFileFromPlacements(fileID, checksum, current))
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "combine surviving shares")
+	}
+
+	survivors, corrupt := placementsOf(current, recovery.Used), placementsOf(current, recovery.Bad)
+
+	manifest := PlacementsManifest(survivors)
+	if manifest != nil {
+		shareCount = manifest.ShareCount
+	}
+	sharedFile := SharedFileFromPlacements(fileID, checksum, survivors)
+
+	found := make(map[string]bool, len(survivors))
+	holders := make(map[storesPkg.Store]bool, len(survivors))
+	for _, placement := range survivors {
+		found[placement.Share.ID] = true
+		holders[placement.Store] = true
+	}
+
+	report := &RepairReport{}
+	for x := 1; x <= int(shareCount); x++ {
+		if !found[strconv.Itoa(x)] {
+			report.MissingShareIDs = append(report.MissingShareIDs, strconv.Itoa(x))
+		}
+	}
+
+	if len(report.MissingShareIDs) == 0 {
+		return report, nil, nil
+	}
+
+	// Stores holding corrupt shares are the first candidates to hold their regenerated versions.
+	targets := make([]storesPkg.Store, 0, len(stores))
+	for _, placement := range corrupt {
+		if !holders[placement.Store] {
+			targets = append(targets, placement.Store)
+			holders[placement.Store] = true
+		}
+	}
+	for _, store := range stores {
+		if !holders[store] {
+			targets = append(targets, store)
+		}
+	}
+
+	repairedShares := make([]*sharesPkg.Share, 0, len(report.MissingShareIDs))
+	for _, shareID := range report.MissingShareIDs {
+		x, _ := strconv.Atoi(shareID)
+		data, err := regenerateShare(recovery.Used, manifest, byte(x))
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "regenerate share '%s'", shareID)
+		}
+
+		repairedShares = append(repairedShares, &sharesPkg.Share{
+			ID:       shareID,
+			FileID:   fileID,
+			Data:     data,
+			Manifest: manifest,
+		})
+	}
+
+	var storeErrors []*StoreError
+	report.Repaired, storeErrors = g.PutShares(repairedShares, targets)
+
+	placed := make(map[string]bool, len(report.Repaired))
+	for _, placement := range report.Repaired {
+		placed[placement.Share.ID] = true
+	}
+	for _, share := range repairedShares {
+		if !placed[share.ID] {
+			report.Unplaced = append(report.Unplaced, share.ID)
+		}
+	}
+
+	if len(report.Repaired) > 0 {
+		all := append(append([]*Placement{}, survivors...), report.Repaired...)
+		if err := g.verifyPlacements(sharedFile, all, 0); err != nil {
+			return report, storeErrors, errors.WithMessage(err, "verify repaired shares")
+		}
+	}
+
+	return report, storeErrors, nil
+}
+
+// Regenerates the share data at x out of good shares, using the very same polynomial they were split with.
+func regenerateShare(shares []*sharesPkg.Share, manifest *sharesPkg.Manifest, x byte) ([]byte, error) {
+	if manifest != nil && manifest.SchemeOrDefault() == sharesPkg.SchemeVSS {
+		return interpolateVerifiable(shares, x)
+	} else if manifest != nil && manifest.SchemeOrDefault() == sharesPkg.SchemePolicy {
+		return nil, ErrPolicyRepair
+	}
+
+	points := make(map[byte][]byte, len(shares))
+	for _, share := range shares {
+		shareX, err := strconv.Atoi(share.ID)
+		if err != nil {
+			return nil, errors.WithMessage(err, "convert share ID from string to int")
+		}
+		points[byte(shareX)] = share.Data
+	}
+	return gf256.Interpolate(points, x), nil
+}
@@ -0,0 +1,193 @@
+package pkg
+
+import (
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+// Prefix of the file ID new shares are staged under while replacing a file's shares in place.
+const stagingIDPrefix = "staged-"
+
+// Replaces a file's old shares with the shares of a new shared file.
+// New shares are put in the given stores (preferring the ones holding old shares), and are verified readable by the
+// given reader (under an access policy, each share goes to its party's store). Only then old shares are deleted. If
+// verification fails, or if not all new shares could be put while requireAll is set, new shares are deleted and old
+// shares are left untouched.
+// When the new shares keep the file's ID, a store's new share would replace its old one, so new shares are staged
+// under a staging ID first (see StagingID), and only switched over to the file's ID once verified. Should switching
+// over fail midway, the staged shares are kept, so that a verified copy of the new shares is always around.
+// Returns the new shares placements, alongside non-fatal store failures.
+func (g *Gasper) replaceShares(reader *Gasper, oldFileID string, oldPlacements []*Placement,
+	newSharedFile *sharesPkg.SharedFile, minSharesThreshold byte, requireAll bool,
+	stores []storesPkg.Store) ([]*Placement, []*StoreError, error) {
+	oldShares := make(map[storesPkg.Store]*sharesPkg.Share, len(oldPlacements))
+	for _, placement := range oldPlacements {
+		oldShares[placement.Store] = placement.Share
+	}
+
+	targets := make([]storesPkg.Store, 0, len(stores))
+	targets = append(targets, PlacementStores(oldPlacements)...)
+	for _, store := range stores {
+		if _, ok := oldShares[store]; !ok {
+			targets = append(targets, store)
+		}
+	}
+
+	if newSharedFile.Manifest != nil && newSharedFile.Manifest.Policy != nil {
+		var err error
+		if targets, err = PolicyStores(newSharedFile, stores); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	putFile := newSharedFile
+	sameFileID := oldFileID == newSharedFile.ID
+	if sameFileID {
+		putFile = stagedSharedFile(newSharedFile)
+	}
+
+	putPlacements, storeErrors := reader.PutShares(putFile.Shares, targets)
+
+	var verifyErr error
+	if requireAll && len(putPlacements) < len(putFile.Shares) {
+		verifyErr = ErrNotAllSharesPut
+	} else {
+		verifyErr = reader.verifyPlacements(putFile, putPlacements, minSharesThreshold)
+	}
+
+	if verifyErr != nil {
+		storeErrors = append(storeErrors, deletePlacements(putFile.ID, putPlacements)...)
+		return nil, storeErrors, errors.WithMessage(verifyErr, "verify new shares (rolled back)")
+	}
+
+	newPlacements := putPlacements
+	if sameFileID {
+		var switchErrors []*StoreError
+		newPlacements, switchErrors = switchOver(newSharedFile, putPlacements)
+		if len(switchErrors) > 0 {
+			storeErrors = append(storeErrors, switchErrors...)
+			return nil, storeErrors, errors.WithMessagef(ErrSwitchOverFailed, "new shares are kept under '%s'",
+				putFile.ID)
+		}
+		storeErrors = append(storeErrors, deletePlacements(putFile.ID, putPlacements)...)
+	}
+
+	overwritten := make(map[storesPkg.Store]bool, len(newPlacements))
+	if sameFileID {
+		for _, placement := range newPlacements {
+			overwritten[placement.Store] = true
+		}
+	}
+
+	for _, placement := range oldPlacements {
+		if overwritten[placement.Store] {
+			continue
+		}
+
+		if err := placement.Store.Delete(oldFileID); err != nil && err != storesPkg.ErrShareNotExists {
+			storeErrors = append(storeErrors, &StoreError{Store: placement.Store, Err: err})
+		}
+	}
+
+	return newPlacements, storeErrors, nil
+}
+
+// Returns the ID a file's new shares are staged under while replacing its shares in place.
+func StagingID(fileID string) string {
+	return stagingIDPrefix + fileID
+}
+
+// Returns a copy of a shared file, whose shares are staged under its staging ID.
+func stagedSharedFile(sharedFile *sharesPkg.SharedFile) *sharesPkg.SharedFile {
+	staged := *sharedFile
+	staged.ID = StagingID(sharedFile.ID)
+	staged.Shares = make([]*sharesPkg.Share, 0, len(sharedFile.Shares))
+	for _, share := range sharedFile.Shares {
+		stagedShare := *share
+		stagedShare.FileID = staged.ID
+		staged.Shares = append(staged.Shares, &stagedShare)
+	}
+	return &staged
+}
+
+// Puts each new share under the file's ID, in the store holding its staged copy, replacing the store's old share.
+func switchOver(sharedFile *sharesPkg.SharedFile, stagedPlacements []*Placement) ([]*Placement, []*StoreError) {
+	shares := make(map[string]*sharesPkg.Share, len(sharedFile.Shares))
+	for _, share := range sharedFile.Shares {
+		shares[share.ID] = share
+	}
+
+	placements := make([]*Placement, 0, len(stagedPlacements))
+	storeErrors := make([]*StoreError, 0)
+	for _, staged := range stagedPlacements {
+		share := shares[staged.Share.ID]
+		if err := staged.Store.Put(share); err != nil {
+			storeErrors = append(storeErrors, &StoreError{Store: staged.Store, Err: err})
+			continue
+		}
+		placements = append(placements, &Placement{Store: staged.Store, Share: share})
+	}
+	return placements, storeErrors
+}
+
+// Deletes a file's shares from the given placements' stores. Returns failures.
+func deletePlacements(fileID string, placements []*Placement) []*StoreError {
+	storeErrors := make([]*StoreError, 0)
+	for _, placement := range placements {
+		if err := placement.Store.Delete(fileID); err != nil && err != storesPkg.ErrShareNotExists {
+			storeErrors = append(storeErrors, &StoreError{Store: placement.Store, Err: err})
+		}
+	}
+	return storeErrors
+}
+
+// Reads back the given placements' shares and checks they combine into the shared file's data.
+func (g *Gasper) verifyPlacements(sharedFile *sharesPkg.SharedFile, placements []*Placement,
+	minSharesThreshold byte) error {
+	readBack, _ := g.CollectShares(sharedFile.ID, PlacementStores(placements))
+	if len(readBack) < int(minSharesThreshold) {
+		return ErrNotEnoughShares
+	}
+
+	_, err := g.Combine(SharedFileFromPlacements(sharedFile.ID, sharedFile.Checksum, readBack))
+	return err
+}
+
+// Checks the given share count and minimum shares threshold are the ones of the placements' access policy, if any.
+func checkPolicyShape(placements []*Placement, shareCount, minSharesThreshold byte) error {
+	current, _ := SelectGeneration(placements)
+	manifest := PlacementsManifest(current)
+	if manifest != nil && manifest.Policy != nil &&
+		(shareCount != manifest.ShareCount || minSharesThreshold != manifest.MinSharesThreshold) {
+		return ErrPolicyFixedShape
+	}
+	return nil
+}
+
+// Returns the generation following the latest one of the given placements.
+func nextGeneration(placements []*Placement) uint64 {
+	latest := uint64(0)
+	for _, placement := range placements {
+		if generation := placement.Share.Generation(); generation > latest {
+			latest = generation
+		}
+	}
+	return latest + 1
+}
+
+// Derives the manifest template a file is re-split with: current scheme, access policy and content type are kept,
+// under the next share generation.
+func resplitTemplate(placements []*Placement, shareCount, minSharesThreshold byte) *sharesPkg.Manifest {
+	template := &sharesPkg.Manifest{
+		Generation:         nextGeneration(placements),
+		ShareCount:         shareCount,
+		MinSharesThreshold: minSharesThreshold,
+	}
+
+	current, _ := SelectGeneration(placements)
+	if manifest := PlacementsManifest(current); manifest != nil {
+		template.Scheme, template.Policy, template.Content = manifest.Scheme, manifest.Policy, manifest.Content
+	}
+	return template
+}
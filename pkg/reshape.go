@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+// Reshapes a shared file into a new share count and minimum shares threshold, without changing the file itself.
+// Current generation's collected shares are combined in memory and re-split using the new parameters under the next
+// share generation. New shares are put in the given stores, preferring the ones holding old shares. Every share (of
+// any generation) is then replaced, or none is.
+// A chunk index's chunks are reshaped first, each on its own (see reshapeChunks).
+// Returns the reshaped shared file, alongside non-fatal store failures.
+func (g *Gasper) Reshape(fileID, checksum string, placements []*Placement, stores []storesPkg.Store,
+	shareCount, minSharesThreshold byte) (*sharesPkg.SharedFile, []*StoreError, error) {
+	if minSharesThreshold > shareCount {
+		return nil, nil, ErrInvalidSharesThreshold
+	} else if int(shareCount) > len(stores) {
+		return nil, nil, ErrNotEnoughStores
+	} else if err := checkPolicyShape(placements, shareCount, minSharesThreshold); err != nil {
+		return nil, nil, err
+	}
+
+	current, _ := SelectGeneration(placements)
+	recovery, err := g.Recover(SharedFileFromPlacements(fileID, checksum, current))
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "combine current shares")
+	}
+
+	var storeErrors []*StoreError
+	if manifest := PlacementsManifest(current); manifest != nil && manifest.IsChunkIndex() {
+		if storeErrors, err = g.reshapeChunks(recovery.Data, stores, shareCount, minSharesThreshold); err != nil {
+			return nil, storeErrors, err
+		}
+	}
+
+	template := resplitTemplate(placements, shareCount, minSharesThreshold)
+	newSharedFile, err := g.Split(fileID, recovery.Data, recovery.Metadata, template)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "split reshaped data")
+	}
+
+	_, replaceErrors, err := g.replaceShares(g, fileID, placements, newSharedFile, minSharesThreshold, true, stores)
+	storeErrors = append(storeErrors, replaceErrors...)
+	if err != nil {
+		return nil, storeErrors, err
+	}
+
+	return newSharedFile, storeErrors, nil
+}
+
+// Reshapes the chunks a chunk index references, each under its own next generation and with its own key, so that their
+// previous shares become useless along with the index's. Chunks shared with other indexes are reshaped for them too.
+// A failure leaves the chunks reshaped so far in their new shape, which every index referencing them still reads.
+func (g *Gasper) reshapeChunks(indexData []byte, stores []storesPkg.Store,
+	shareCount, minSharesThreshold byte) ([]*StoreError, error) {
+	index := &ChunkIndex{}
+	if err := json.Unmarshal(indexData, index); err != nil {
+		return nil, errors.WithMessage(err, "unmarshal chunk index")
+	}
+
+	storeErrors := make([]*StoreError, 0)
+	reshaped := make(map[string]bool, len(index.Chunks))
+	for _, ref := range index.Chunks {
+		if reshaped[ref.ID] {
+			continue
+		}
+		reshaped[ref.ID] = true
+
+		key, err := hex.DecodeString(ref.Key)
+		if err != nil {
+			return storeErrors, errors.WithMessagef(err, "decode key of chunk '%s'", ref.ID)
+		}
+
+		chunkGasper, err := newChunkGasper(key)
+		if err != nil {
+			return storeErrors, err
+		}
+
+		placements, collectErrors := g.CollectShares(ref.ID, stores)
+		storeErrors = append(storeErrors, collectErrors...)
+
+		_, chunkErrors, err := chunkGasper.Reshape(ref.ID, "", placements, stores, shareCount, minSharesThreshold)
+		storeErrors = append(storeErrors, chunkErrors...)
+		if err != nil {
+			return storeErrors, errors.WithMessagef(err, "reshape chunk '%s'", ref.ID)
+		}
+	}
+	return storeErrors, nil
+}
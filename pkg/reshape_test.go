@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestReshape(t *testing.T) {
+	data := testData(10, 5000)
+
+	tests := []struct {
+		name                   string
+		shareCount, threshold  byte
+		newCount, newThreshold byte
+		err                    error
+	}{
+		{name: "more shares, higher threshold", shareCount: 3, threshold: 2, newCount: 5, newThreshold: 3},
+		{name: "fewer shares, same threshold", shareCount: 4, threshold: 2, newCount: 2, newThreshold: 2},
+		{name: "same shares, lower threshold", shareCount: 4, threshold: 4, newCount: 4, newThreshold: 2},
+		{name: "threshold above count", shareCount: 3, threshold: 2, newCount: 3, newThreshold: 4,
+			err: ErrInvalidSharesThreshold},
+		{name: "not enough stores", shareCount: 3, threshold: 2, newCount: 6, newThreshold: 3,
+			err: ErrNotEnoughStores},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stores, cleanup := newTestStores(t, 5)
+			defer cleanup()
+
+			g := newTestGasper(t, stores, true)
+			sharedFile, placements := storeTestData(t, g, "file", data, stores[:test.shareCount], test.shareCount,
+				test.threshold)
+
+			reshaped, _, err := g.Reshape("file", sharedFile.Checksum, placements, stores, test.newCount,
+				test.newThreshold)
+			if errors.Cause(err) != test.err {
+				t.Fatalf("reshape: %v, want %v", err, test.err)
+			} else if err != nil {
+				return
+			}
+
+			manifest := reshaped.Manifest
+			if manifest.ShareCount != test.newCount || manifest.MinSharesThreshold != test.newThreshold {
+				t.Errorf("reshaped into %d shares of threshold %d, want %d of threshold %d", manifest.ShareCount,
+					manifest.MinSharesThreshold, test.newCount, test.newThreshold)
+			}
+
+			// Every old share is replaced, and only the new ones are around.
+			current, stale := SelectGeneration(collectTestPlacements(t, g, "file", stores))
+			if len(current) != int(test.newCount) || len(stale) != 0 {
+				t.Fatalf("%d current and %d stale shares, want %d current", len(current), len(stale), test.newCount)
+			}
+
+			// A threshold of new shares combines, one fewer doesn't.
+			enough := current[:test.newThreshold]
+			combined, err := g.Combine(SharedFileFromPlacements("file", sharedFile.Checksum, enough))
+			if err != nil {
+				t.Fatalf("combine: %v", err)
+			} else if !bytes.Equal(combined, data) {
+				t.Error("combined data differs")
+			}
+
+			fewer := current[:test.newThreshold-1]
+			if _, err := g.Combine(SharedFileFromPlacements("file", sharedFile.Checksum, fewer)); err == nil {
+				t.Error("combined fewer shares than the threshold")
+			}
+
+			if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, []string{"file"}) {
+				t.Errorf("stored file IDs: %v, want [file]", fileIDs)
+			}
+		})
+	}
+}
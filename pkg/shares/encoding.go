@@ -0,0 +1,81 @@
+package shares
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+const (
+	encodingMagic   = "GASPER"
+	encodingVersion = byte(1)
+
+	manifestLengthSize = 4
+)
+
+var ErrInvalidEncoding = errors.New("invalid share encoding")
+
+// Encodes a share's data along with its manifest, for stores to persist.
+// Format: "GASPER" | version (1 byte) | manifest length (4 bytes, big endian) | JSON manifest | share data.
+func Encode(share *Share) ([]byte, error) {
+	if share.Manifest == nil {
+		return share.Data, nil
+	}
+
+	manifestBytes, err := json.Marshal(share.Manifest)
+	if err != nil {
+		return nil, errors.WithMessage(err, "marshal manifest")
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(encodingMagic) + 1 + manifestLengthSize + len(manifestBytes) + len(share.Data))
+	buffer.WriteString(encodingMagic)
+	buffer.WriteByte(encodingVersion)
+
+	manifestLength := make([]byte, manifestLengthSize)
+	binary.BigEndian.PutUint32(manifestLength, uint32(len(manifestBytes)))
+	buffer.Write(manifestLength)
+	buffer.Write(manifestBytes)
+	buffer.Write(share.Data)
+
+	return buffer.Bytes(), nil
+}
+
+// Decodes a share persisted by a store.
+// Shares persisted before manifests were introduced are decoded as-is, with a nil manifest.
+func Decode(fileID, shareID string, raw []byte) (*Share, error) {
+	share := &Share{
+		ID:     shareID,
+		FileID: fileID,
+	}
+
+	if !bytes.HasPrefix(raw, []byte(encodingMagic)) {
+		share.Data = raw
+		return share, nil
+	}
+
+	raw = raw[len(encodingMagic):]
+	if len(raw) < 1+manifestLengthSize {
+		return nil, ErrInvalidEncoding
+	}
+
+	if version := raw[0]; version != encodingVersion {
+		return nil, errors.Errorf("unsupported share encoding version %d", version)
+	}
+
+	manifestLength := binary.BigEndian.Uint32(raw[1 : 1+manifestLengthSize])
+	raw = raw[1+manifestLengthSize:]
+	if uint64(len(raw)) < uint64(manifestLength) {
+		return nil, ErrInvalidEncoding
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(raw[:manifestLength], manifest); err != nil {
+		return nil, errors.WithMessage(err, "unmarshal manifest")
+	}
+
+	share.Manifest = manifest
+	share.Data = raw[manifestLength:]
+	return share, nil
+}
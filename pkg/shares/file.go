@@ -4,4 +4,5 @@ type SharedFile struct {
 	ID       string
 	Checksum string
 	Shares   []*Share
+	Manifest *Manifest
 }
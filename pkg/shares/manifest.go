@@ -0,0 +1,171 @@
+package shares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+)
+
+// Secret sharing schemes.
+const (
+	// Shamir's secret sharing of the whole (optionally encrypted) file, over GF(2^8).
+	SchemeShamir = "shamir"
+
+	// Feldman's verifiable secret sharing: file is encrypted with a random key, which is split using Shamir's secret
+	// sharing over a prime-order group along with public commitments. Each share holds a key share and the ciphertext.
+	SchemeVSS = "vss"
+
+	// Krawczyk's secret sharing made short (computational secret sharing): file is encrypted with a random key, the
+	// ciphertext is erasure-coded into fragments of about 1/threshold of its size, and only the key is split using
+	// Shamir's secret sharing. Each share holds a key share and a fragment.
+	SchemeSSMS = "ssms"
+
+	// Access policy: file is encrypted with a random key, which is split along a policy (see Policy) by nesting
+	// Shamir's secret sharing splits. Each share holds its party's key points and the ciphertext.
+	SchemePolicy = "policy"
+)
+
+// Shared content types.
+const (
+	// A single regular file.
+	ContentFile = "file"
+
+	// A directory tree, bundled into a tar archive.
+	ContentDirectory = "directory"
+
+	// A chunk index: the list of content-defined chunks a file (or directory tree) was cut into, stored separately.
+	ContentChunkIndex = "chunk-index"
+
+	// A single content-defined chunk, encrypted under its own key, and shared by every index referencing it.
+	ContentChunk = "chunk"
+
+	// A named object's version history.
+	ContentHistory = "history"
+)
+
+// Manifest holds a shared file's metadata. A copy of it is committed in each of the file's shares.
+type Manifest struct {
+	// Secret sharing scheme. Empty means SchemeShamir.
+	Scheme string `json:"scheme,omitempty"`
+
+	// Shared content type. Empty means ContentFile.
+	Content string `json:"content,omitempty"`
+
+	// Share generation (epoch). Bumped whenever a file's shares are regenerated, so that shares of different
+	// generations are never combined together.
+	Generation uint64 `json:"generation"`
+
+	ShareCount         byte `json:"share-count"`
+	MinSharesThreshold byte `json:"shares-threshold"`
+
+	// Integrity tags: hex-encoded SHA-256 digests of each share's data, by share ID.
+	// Let a single share be checked on its own, so bad shares can be told apart before combining. Each share's data
+	// holds a share of a random secret (key or nonce) which salts its tag, so that tags reveal nothing about shares one
+	// doesn't hold.
+	ShareDigests map[string]string `json:"share-digests,omitempty"`
+
+	// Shamir's secret sharing: size of the random nonce appended to the payload before splitting it, so that each share
+	// ends with a share of the nonce. Zero for shares split without one.
+	NonceSize int `json:"nonce-size,omitempty"`
+
+	// Verifiable secret sharing: hex-encoded commitments to the key polynomial's coefficients, and hex-encoded
+	// SHA-256 digest of the ciphertext held by every share.
+	Commitments      []string `json:"commitments,omitempty"`
+	CiphertextDigest string   `json:"ciphertext-digest,omitempty"`
+
+	// Secret sharing made short: size of the erasure-coded ciphertext.
+	CiphertextSize int `json:"ciphertext-size,omitempty"`
+
+	// Access policy shares were split along. Share count is then its number of parties, and minimum shares threshold
+	// the minimum number of parties which can satisfy it.
+	Policy *Policy `json:"policy,omitempty"`
+}
+
+// Returns manifest's scheme, defaulting to SchemeShamir.
+func (m *Manifest) SchemeOrDefault() string {
+	if m.Scheme == "" {
+		return SchemeShamir
+	}
+	return m.Scheme
+}
+
+// Whether shared content is a directory tree, rather than a single file.
+func (m *Manifest) IsDirectory() bool {
+	return m.Content == ContentDirectory
+}
+
+// Whether shared content is a chunk index, rather than the content itself.
+func (m *Manifest) IsChunkIndex() bool {
+	return m.Content == ContentChunkIndex
+}
+
+// Computes the integrity tag of a share's data.
+func ShareDigest(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// Checks a share's data against its integrity tag.
+// Shares of manifests without integrity tags always pass.
+func (m *Manifest) CheckShare(share *Share) bool {
+	if len(m.ShareDigests) == 0 {
+		return true
+	}
+
+	digest, ok := m.ShareDigests[share.ID]
+	return ok && digest == ShareDigest(share.Data)
+}
+
+// Whether manifest holds sensible share parameters.
+// Under an access policy, a single (heavily weighted) party may be enough.
+func (m *Manifest) Valid() bool {
+	if m.Policy != nil {
+		return m.Policy.Validate() == nil && m.MinSharesThreshold >= 1 && m.MinSharesThreshold <= m.ShareCount
+	}
+	return m.MinSharesThreshold > 1 && m.MinSharesThreshold <= m.ShareCount
+}
+
+// Returns the party holding a share under manifest's access policy, or an empty string if there's none.
+func (m *Manifest) Party(shareID string) string {
+	if m.Policy == nil {
+		return ""
+	}
+
+	index, err := strconv.Atoi(shareID)
+	parties := m.Policy.Parties()
+	if err != nil || index < 1 || index > len(parties) {
+		return ""
+	}
+	return parties[index-1]
+}
+
+// Fingerprints manifest, so that copies committed in different shares can be compared.
+func (m *Manifest) Fingerprint() string {
+	manifestBytes, _ := json.Marshal(m) // Note: maps are marshalled in sorted key order.
+	digest := sha256.Sum256(manifestBytes)
+	return hex.EncodeToString(digest[:])
+}
+
+// Returns the manifest agreed upon by most of the given shares, or nil if none holds a manifest.
+// A share tampered with along with its own manifest copy is thus outvoted by the other shares.
+func ConsensusManifest(shares []*Share) *Manifest {
+	var (
+		consensus *Manifest
+		votes     = make(map[string]int, len(shares))
+		maxVotes  = 0
+	)
+
+	for _, share := range shares {
+		if share.Manifest == nil {
+			continue
+		}
+
+		fingerprint := share.Manifest.Fingerprint()
+		votes[fingerprint]++
+		if votes[fingerprint] > maxVotes {
+			consensus, maxVotes = share.Manifest, votes[fingerprint]
+		}
+	}
+	return consensus
+}
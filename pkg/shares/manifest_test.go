@@ -0,0 +1,63 @@
+package shares
+
+import (
+	"testing"
+)
+
+func TestCheckShare(t *testing.T) {
+	manifest := &Manifest{ShareDigests: map[string]string{"1": ShareDigest([]byte("one"))}}
+
+	tests := []struct {
+		name     string
+		manifest *Manifest
+		share    *Share
+		good     bool
+	}{
+		{name: "matching tag", manifest: manifest, share: &Share{ID: "1", Data: []byte("one")}, good: true},
+		{name: "tampered data", manifest: manifest, share: &Share{ID: "1", Data: []byte("One")}},
+		{name: "untagged ID", manifest: manifest, share: &Share{ID: "2", Data: []byte("one")}},
+		{name: "untagged manifest", manifest: &Manifest{}, share: &Share{ID: "1", Data: []byte("any")}, good: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if good := test.manifest.CheckShare(test.share); good != test.good {
+				t.Errorf("check: %t, want %t", good, test.good)
+			}
+		})
+	}
+}
+
+func TestConsensusManifest(t *testing.T) {
+	genuine := &Manifest{ShareCount: 3, MinSharesThreshold: 2, ShareDigests: map[string]string{"1": "a", "2": "b"}}
+	copied := *genuine
+	forged := &Manifest{ShareCount: 3, MinSharesThreshold: 2, ShareDigests: map[string]string{"1": "c", "2": "b"}}
+
+	tests := []struct {
+		name      string
+		manifests []*Manifest
+		want      *Manifest
+	}{
+		{name: "no shares"},
+		{name: "no manifests", manifests: []*Manifest{nil, nil}},
+		{name: "unanimous", manifests: []*Manifest{genuine, &copied, genuine}, want: genuine},
+		{name: "forged copy outvoted", manifests: []*Manifest{forged, genuine, &copied}, want: genuine},
+		{name: "missing manifests ignored", manifests: []*Manifest{nil, nil, forged}, want: forged},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares := make([]*Share, 0, len(test.manifests))
+			for _, manifest := range test.manifests {
+				shares = append(shares, &Share{Manifest: manifest})
+			}
+
+			consensus := ConsensusManifest(shares)
+			if (consensus == nil) != (test.want == nil) {
+				t.Fatalf("consensus: %+v, want %+v", consensus, test.want)
+			} else if consensus != nil && consensus.Fingerprint() != test.want.Fingerprint() {
+				t.Errorf("consensus: %+v, want %+v", consensus, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package shares
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Upper bound on weights under a single policy node, and on policy nodes, as points are identified by a byte each.
+const maxPolicyPoints = 255
+
+// Policy is an access structure: a tree of thresholds over parties (share holders, i.e. stores).
+// A leaf names a party, optionally weighted - a party of weight w counts as w members of its parent. An inner node is
+// satisfied once the total weight of its satisfied members reaches its threshold.
+// For instance, "any 2 admins, or 1 admin plus 3 engineers" is a 1-of node over a 2-of-admins node and a 2-of node
+// over a 1-of-admins node and a 3-of-engineers node.
+type Policy struct {
+	// Inner nodes.
+	Threshold byte      `json:"threshold,omitempty"`
+	Members   []*Policy `json:"members,omitempty"`
+
+	// Leaves.
+	Party  string `json:"party,omitempty"`
+	Weight byte   `json:"weight,omitempty"`
+}
+
+// Whether policy node is a leaf, naming a party.
+func (p *Policy) IsLeaf() bool {
+	return p.Party != ""
+}
+
+// Returns leaf's weight, defaulting to 1. Inner nodes always weigh 1.
+func (p *Policy) MemberWeight() int {
+	if !p.IsLeaf() || p.Weight == 0 {
+		return 1
+	}
+	return int(p.Weight)
+}
+
+// Checks policy is well-formed.
+func (p *Policy) Validate() error {
+	if p.IsLeaf() {
+		return errors.New("policy root must be a threshold node")
+	}
+
+	nodes := 0
+	if err := p.validate(&nodes); err != nil {
+		return err
+	} else if len(p.Parties()) > maxPolicyPoints {
+		return errors.Errorf("policy cannot have more than %d parties", maxPolicyPoints)
+	}
+	return nil
+}
+
+func (p *Policy) validate(nodes *int) error {
+	if p.IsLeaf() {
+		if p.Threshold != 0 || len(p.Members) != 0 {
+			return errors.Errorf("party '%s' cannot have a threshold or members", p.Party)
+		}
+		return nil
+	}
+
+	if *nodes++; *nodes > maxPolicyPoints {
+		return errors.Errorf("policy cannot have more than %d threshold nodes", maxPolicyPoints)
+	} else if p.Weight != 0 {
+		return errors.New("only parties can be weighted")
+	} else if len(p.Members) == 0 {
+		return errors.New("threshold node must have members")
+	}
+
+	totalWeight := 0
+	for _, member := range p.Members {
+		if member == nil {
+			return errors.New("nil policy member")
+		}
+
+		if err := member.validate(nodes); err != nil {
+			return err
+		}
+		totalWeight += member.MemberWeight()
+	}
+
+	if totalWeight > maxPolicyPoints {
+		return errors.Errorf("members' total weight cannot be larger than %d", maxPolicyPoints)
+	} else if p.Threshold < 1 || int(p.Threshold) > totalWeight {
+		return errors.Errorf("threshold must be >= 1 and <= members' total weight (%d)", totalWeight)
+	}
+	return nil
+}
+
+// Lists policy's parties, in order of first appearance.
+// Under a policy, share IDs are 1-based indexes into this list.
+func (p *Policy) Parties() []string {
+	var parties []string
+	seen := make(map[string]bool)
+	p.walk(func(node *Policy) {
+		if node.IsLeaf() && !seen[node.Party] {
+			seen[node.Party] = true
+			parties = append(parties, node.Party)
+		}
+	})
+	return parties
+}
+
+// Calls fn with each policy node, in pre-order.
+func (p *Policy) walk(fn func(node *Policy)) {
+	fn(p)
+	for _, member := range p.Members {
+		member.walk(fn)
+	}
+}
+
+// Whether the given parties satisfy the policy.
+func (p *Policy) Satisfied(present map[string]bool) bool {
+	return p.satisfiedWeight(present) >= int(p.Threshold)
+}
+
+func (p *Policy) satisfiedWeight(present map[string]bool) int {
+	weight := 0
+	for _, member := range p.Members {
+		if member.IsLeaf() && present[member.Party] || !member.IsLeaf() && member.Satisfied(present) {
+			weight += member.MemberWeight()
+		}
+	}
+	return weight
+}
+
+// Returns the minimum number of parties which can satisfy the policy.
+// Note: tries sets of parties of increasing size, which is meant for policies over a handful of custodians.
+func (p *Policy) MinParties() int {
+	parties := p.Parties()
+	for size := 1; size < len(parties); size++ {
+		found := false
+		forEachCombination(len(parties), size, func(indexes []int) bool {
+			present := make(map[string]bool, size)
+			for _, index := range indexes {
+				present[parties[index]] = true
+			}
+
+			found = p.Satisfied(present)
+			return !found
+		})
+
+		if found {
+			return size
+		}
+	}
+	return len(parties)
+}
+
+// Explains what the given parties still miss to satisfy the policy, or returns an empty string if they satisfy it.
+// E.g. "1 more of (2 more of (bob, carol), 3 more of (dave, erin, frank))".
+func (p *Policy) Explain(present map[string]bool) string {
+	if p.Satisfied(present) {
+		return ""
+	}
+
+	var missing []string
+	for _, member := range p.Members {
+		if member.IsLeaf() {
+			if !present[member.Party] {
+				missing = append(missing, member.describe())
+			}
+		} else if explanation := member.Explain(present); explanation != "" {
+			missing = append(missing, explanation)
+		}
+	}
+
+	return fmt.Sprintf("%d more of (%s)", int(p.Threshold)-p.satisfiedWeight(present),
+		strings.Join(missing, ", "))
+}
+
+func (p *Policy) describe() string {
+	if p.MemberWeight() > 1 {
+		return fmt.Sprintf("%s [weight %d]", p.Party, p.MemberWeight())
+	}
+	return p.Party
+}
+
+// Calls fn with each size-sized combination of indexes 0..n-1, in lexicographic order, for as long as it returns true.
+func forEachCombination(n, size int, fn func(indexes []int) bool) {
+	indexes := make([]int, size)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	for {
+		if !fn(indexes) {
+			return
+		}
+
+		i := size - 1
+		for i >= 0 && indexes[i] == n-size+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+
+		indexes[i]++
+		for j := i + 1; j < size; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
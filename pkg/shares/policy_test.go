@@ -0,0 +1,95 @@
+package shares
+
+import (
+	"reflect"
+	"testing"
+)
+
+func party(name string, weight byte) *Policy {
+	return &Policy{Party: name, Weight: weight}
+}
+
+// "Any 2 admins, or 1 admin plus 3 engineers (where Erin counts twice)".
+func testPolicy() *Policy {
+	return &Policy{Threshold: 1, Members: []*Policy{
+		{Threshold: 2, Members: []*Policy{party("alice", 0), party("bob", 0)}},
+		{Threshold: 2, Members: []*Policy{
+			{Threshold: 1, Members: []*Policy{party("alice", 0), party("bob", 0)}},
+			{Threshold: 3, Members: []*Policy{party("carol", 0), party("dave", 0), party("erin", 2)}},
+		}},
+	}}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		valid  bool
+	}{
+		{name: "nested", policy: testPolicy(), valid: true},
+		{name: "flat", policy: &Policy{Threshold: 2, Members: []*Policy{party("a", 0), party("b", 0)}}, valid: true},
+		{name: "weight reaches threshold", policy: &Policy{Threshold: 3, Members: []*Policy{party("a", 3)}},
+			valid: true},
+		{name: "leaf root", policy: party("a", 0)},
+		{name: "no members", policy: &Policy{Threshold: 1}},
+		{name: "zero threshold", policy: &Policy{Members: []*Policy{party("a", 0)}}},
+		{name: "threshold above weight", policy: &Policy{Threshold: 3, Members: []*Policy{party("a", 0),
+			party("b", 0)}}},
+		{name: "weighted threshold node", policy: &Policy{Threshold: 1, Members: []*Policy{
+			{Threshold: 1, Weight: 2, Members: []*Policy{party("a", 0)}}}}},
+		{name: "party with members", policy: &Policy{Threshold: 1, Members: []*Policy{
+			{Party: "a", Threshold: 1, Members: []*Policy{party("b", 0)}}}}},
+		{name: "nil member", policy: &Policy{Threshold: 1, Members: []*Policy{nil}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.policy.Validate(); (err == nil) != test.valid {
+				t.Errorf("validate: %v, want valid: %t", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestPolicySatisfied(t *testing.T) {
+	tests := []struct {
+		parties   []string
+		satisfied bool
+	}{
+		{parties: []string{"alice", "bob"}, satisfied: true},
+		{parties: []string{"alice", "carol", "dave", "erin"}, satisfied: true},
+		{parties: []string{"bob", "carol", "erin"}, satisfied: true},
+		{parties: []string{"alice", "erin"}},
+		{parties: []string{"carol", "dave", "erin"}},
+		{parties: []string{"bob"}},
+		{},
+	}
+
+	policy := testPolicy()
+	for _, test := range tests {
+		present := make(map[string]bool, len(test.parties))
+		for _, name := range test.parties {
+			present[name] = true
+		}
+
+		if satisfied := policy.Satisfied(present); satisfied != test.satisfied {
+			t.Errorf("%v: satisfied %t, want %t", test.parties, satisfied, test.satisfied)
+		} else if explanation := policy.Explain(present); (explanation == "") != test.satisfied {
+			t.Errorf("%v: explained '%s'", test.parties, explanation)
+		}
+	}
+}
+
+func TestPolicyParties(t *testing.T) {
+	policy := testPolicy()
+	if parties := policy.Parties(); !reflect.DeepEqual(parties, []string{"alice", "bob", "carol", "dave", "erin"}) {
+		t.Errorf("parties: %v", parties)
+	} else if minParties := policy.MinParties(); minParties != 2 {
+		t.Errorf("min parties: %d, want 2", minParties)
+	}
+
+	engineers := policy.Members[1].Members[1]
+	if minParties := engineers.MinParties(); minParties != 2 {
+		t.Errorf("engineers' min parties: %d, want 2", minParties)
+	}
+}
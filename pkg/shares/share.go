@@ -1,7 +1,16 @@
 package shares
 
 type Share struct {
-	ID     string
-	FileID string
-	Data   []byte
+	ID       string
+	FileID   string
+	Data     []byte
+	Manifest *Manifest // Nil for shares stored before manifests were introduced.
+}
+
+// Share generation, or 0 if share has no manifest.
+func (s *Share) Generation() uint64 {
+	if s.Manifest == nil {
+		return 0
+	}
+	return s.Manifest.Generation
 }
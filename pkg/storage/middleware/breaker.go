@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("store's circuit is open after repeated failures")
+
+// CircuitBreakerOptions configures a store's circuit breaker.
+type CircuitBreakerOptions struct {
+	// Consecutive failed operations opening the circuit.
+	FailureThreshold int
+
+	// How long the circuit stays open before letting a trial operation through.
+	Cooldown time.Duration
+}
+
+// DefaultCircuitBreakerOptions are used for whatever a store's circuit breaker config doesn't set.
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	FailureThreshold: 5,
+	Cooldown:         30 * time.Second,
+}
+
+// CircuitBreakerStore stops calling a store failing over and over: once enough consecutive operations failed, the
+// circuit opens, and operations fail right away with ErrCircuitOpen (and the store reports itself unavailable) until
+// the cooldown is over. A single trial operation is then let through, closing the circuit if it succeeds, or opening
+// it again otherwise.
+// Failures telling the share doesn't exist (or matches more than once), or refused by inner middleware (quota,
+// read-only), don't count, as the store did answer.
+type CircuitBreakerStore struct {
+	wrapper
+	options CircuitBreakerOptions
+
+	mutex    sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewCircuitBreakerStore(store stores.Store, options CircuitBreakerOptions) *CircuitBreakerStore {
+	return &CircuitBreakerStore{wrapper: wrapper{store: store}, options: options}
+}
+
+// Note: an open circuit reports the store as unavailable, rather than failing, so that it's skipped.
+func (cbs *CircuitBreakerStore) Available() (bool, error) {
+	var available bool
+	err := cbs.call(func() error {
+		var err error
+		available, err = cbs.store.Available()
+		return err
+	})
+	if errors.Cause(err) == ErrCircuitOpen {
+		return false, nil
+	}
+	return available, err
+}
+
+func (cbs *CircuitBreakerStore) Put(share *shares.Share) error {
+	return cbs.call(func() error {
+		return cbs.store.Put(share)
+	})
+}
+
+func (cbs *CircuitBreakerStore) Get(fileID string) (*shares.Share, error) {
+	var share *shares.Share
+	err := cbs.call(func() error {
+		var err error
+		share, err = cbs.store.Get(fileID)
+		return err
+	})
+	return share, err
+}
+
+func (cbs *CircuitBreakerStore) Delete(fileID string) error {
+	return cbs.call(func() error {
+		return cbs.store.Delete(fileID)
+	})
+}
+
+func (cbs *CircuitBreakerStore) List() ([]string, error) {
+	var fileIDs []string
+	err := cbs.call(func() error {
+		var err error
+		fileIDs, err = cbs.store.List()
+		return err
+	})
+	return fileIDs, err
+}
+
+// Runs an operation unless the circuit is open, and records its outcome.
+func (cbs *CircuitBreakerStore) call(operation func() error) error {
+	if !cbs.allow() {
+		return errors.WithMessagef(ErrCircuitOpen, "store '%s' (%s)", cbs.store.Name(), cbs.store.Type())
+	}
+
+	err := operation()
+	switch errors.Cause(err) {
+	case nil, stores.ErrShareNotExists, stores.ErrMoreThanOneMatch, ErrQuotaExceeded, ErrReadOnly:
+		cbs.record(true)
+	default:
+		cbs.record(false)
+	}
+	return err
+}
+
+// Whether an operation may go through: the circuit is closed, or its cooldown is over and no trial is running.
+func (cbs *CircuitBreakerStore) allow() bool {
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+
+	if cbs.failures < cbs.options.FailureThreshold {
+		return true
+	} else if cbs.trial || time.Since(cbs.openedAt) < cbs.options.Cooldown {
+		return false
+	}
+
+	cbs.trial = true
+	return true
+}
+
+func (cbs *CircuitBreakerStore) record(succeeded bool) {
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+
+	cbs.trial = false
+	if succeeded {
+		cbs.failures = 0
+		return
+	}
+
+	cbs.failures++
+	if cbs.failures >= cbs.options.FailureThreshold {
+		cbs.openedAt = time.Now()
+	}
+}
+
+// Parses a store's 'circuit-breaker' attribute: an object of 'failure-threshold' and 'cooldown' (a duration, e.g.
+// '30s').
+func breakerOptionsFromConfig(raw interface{}) (CircuitBreakerOptions, error) {
+	options := DefaultCircuitBreakerOptions
+	config, err := configMap(raw)
+	if err != nil {
+		return options, err
+	}
+
+	if err := configInt(config, "failure-threshold", &options.FailureThreshold); err != nil {
+		return options, err
+	} else if err := configDuration(config, "cooldown", &options.Cooldown); err != nil {
+		return options, err
+	}
+
+	if options.FailureThreshold < 1 {
+		return options, errors.New("'failure-threshold' should be at least 1")
+	}
+	return options, nil
+}
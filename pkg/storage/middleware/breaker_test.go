@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+const testCooldown = 20 * time.Millisecond
+
+func TestCircuitBreakerStore(t *testing.T) {
+	store := newFakeStore(errTransient, errTransient)
+	cbs := NewCircuitBreakerStore(store, CircuitBreakerOptions{FailureThreshold: 2, Cooldown: testCooldown})
+
+	// Consecutive failures open the circuit, and calls stop reaching the store.
+	for i := 0; i < 2; i++ {
+		if _, err := cbs.List(); errors.Cause(err) != errTransient {
+			t.Fatalf("list: %v, want %v", err, errTransient)
+		}
+	}
+	if _, err := cbs.List(); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("list with circuit open: %v, want %v", err, ErrCircuitOpen)
+	} else if available, err := cbs.Available(); available || err != nil {
+		t.Errorf("available with circuit open: %t (%v), want false", available, err)
+	} else if store.callCount() != 2 {
+		t.Errorf("%d calls reached the store, want 2", store.callCount())
+	}
+
+	// A failed trial opens it again.
+	time.Sleep(2 * testCooldown)
+	store.script(errTransient)
+	if _, err := cbs.List(); errors.Cause(err) != errTransient {
+		t.Fatalf("trial: %v, want %v", err, errTransient)
+	} else if _, err := cbs.List(); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("list after failed trial: %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A successful one closes it.
+	time.Sleep(2 * testCooldown)
+	if _, err := cbs.List(); err != nil {
+		t.Fatalf("trial: %v", err)
+	}
+	store.script(errTransient)
+	if _, err := cbs.List(); errors.Cause(err) != errTransient {
+		t.Fatalf("list after successful trial: %v, want %v", err, errTransient)
+	} else if available, err := cbs.Available(); !available || err != nil {
+		t.Errorf("available with circuit closed: %t (%v), want true", available, err)
+	}
+}
+
+// Failures of calls the store did answer don't open the circuit.
+func TestCircuitBreakerStoreAnswered(t *testing.T) {
+	for _, answer := range []error{stores.ErrShareNotExists, stores.ErrMoreThanOneMatch,
+		errors.WithMessage(ErrQuotaExceeded, "store"), errors.WithMessage(ErrReadOnly, "store")} {
+		store := newFakeStore(answer, answer)
+		cbs := NewCircuitBreakerStore(store, CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Hour})
+
+		for i := 0; i < 2; i++ {
+			if err := cbs.Delete("file"); errors.Cause(err) != errors.Cause(answer) {
+				t.Fatalf("delete: %v, want %v", err, answer)
+			}
+		}
+		if _, err := cbs.List(); err != nil {
+			t.Errorf("list after %v: %v", answer, err)
+		}
+	}
+}
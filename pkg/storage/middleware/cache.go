@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached shares are served, unless a cache's config tells otherwise.
+const DefaultCacheTTL = time.Hour
+
+// Names the file a cache directory's owning store is recorded in.
+const cacheOwnerFile = ".gasper-cache-owner"
+
+var ErrSharedCacheDirectory = errors.New("cache directory is used by another store")
+
+var (
+	// Cache directories claimed by stores of this process, by absolute path.
+	cacheOwners      = make(map[string]string)
+	cacheOwnersMutex sync.Mutex
+)
+
+// CacheStore serves shares read from a store out of a local directory (read-through), so that reading them again
+// (e.g. verifying, then retrieving) doesn't hit the store. Put and Delete go to the store first, and then update the
+// cache. Cached shares are served for a limited time only, as other machines may replace them in the store.
+// Note: a cache directory holding several stores' shares could end up holding enough shares to recover every file, so
+// each store must have a cache directory of its own - other stores' are refused.
+type CacheStore struct {
+	wrapper
+	cache *stores.LocalStore
+	path  string
+	owner string
+	ttl   time.Duration
+}
+
+// Returns ErrSharedCacheDirectory if another store already uses the cache directory.
+func NewCacheStore(store stores.Store, directoryPath string, ttl time.Duration) (*CacheStore, error) {
+	path, err := filepath.Abs(directoryPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "resolve cache directory '%s'", directoryPath)
+	}
+
+	owner := fmt.Sprintf("%s:%s", store.Type(), store.Name())
+	if _, ok := store.(placeholder); !ok { // Validating config doesn't claim anything.
+		if err := claimCacheDirectory(path, owner); err != nil {
+			return nil, err
+		}
+	}
+
+	cache, err := stores.NewLocalStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CacheStore{wrapper: wrapper{store: store}, cache: cache, path: path, owner: owner, ttl: ttl}, nil
+}
+
+func (cs *CacheStore) Available() (bool, error) {
+	return cs.store.Available()
+}
+
+func (cs *CacheStore) Put(share *shares.Share) error {
+	if err := cs.store.Put(share); err != nil {
+		_ = cs.cache.Delete(share.FileID)
+		return err
+	}
+
+	cs.fill(share)
+	return nil
+}
+
+func (cs *CacheStore) Get(fileID string) (*shares.Share, error) {
+	if cs.fresh(fileID) {
+		if share, err := cs.cache.Get(fileID); err == nil {
+			return share, nil
+		}
+	}
+
+	share, err := cs.store.Get(fileID)
+	if err != nil {
+		if err == stores.ErrShareNotExists {
+			_ = cs.cache.Delete(fileID)
+		}
+		return nil, err
+	}
+
+	cs.fill(share)
+	return share, nil
+}
+
+func (cs *CacheStore) Delete(fileID string) error {
+	_ = cs.cache.Delete(fileID)
+	return cs.store.Delete(fileID)
+}
+
+func (cs *CacheStore) List() ([]string, error) {
+	return cs.store.List()
+}
+
+// Caches a share, recording the cache directory's owner first. Caching is best effort: failures only mean the next
+// read hits the store.
+func (cs *CacheStore) fill(share *shares.Share) {
+	if err := os.MkdirAll(cs.path, 0700); err != nil {
+		return
+	}
+
+	ownerPath := filepath.Join(cs.path, cacheOwnerFile)
+	if _, err := os.Stat(ownerPath); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(ownerPath, []byte(cs.owner), 0600); err != nil {
+			return
+		}
+	}
+	_ = cs.cache.Put(share)
+}
+
+// Claims a cache directory for a store, unless another store claimed it already - in this process, or in its owner
+// file.
+func claimCacheDirectory(path, owner string) error {
+	cacheOwnersMutex.Lock()
+	defer cacheOwnersMutex.Unlock()
+
+	if claimer, ok := cacheOwners[path]; ok && claimer != owner {
+		return errors.WithMessagef(ErrSharedCacheDirectory, "'%s' is used by '%s'", path, claimer)
+	}
+
+	recorded, err := ioutil.ReadFile(filepath.Join(path, cacheOwnerFile))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithMessagef(err, "read owner of cache directory '%s'", path)
+	} else if err == nil && string(recorded) != owner {
+		return errors.WithMessagef(ErrSharedCacheDirectory, "'%s' is used by '%s'", path, recorded)
+	}
+
+	cacheOwners[path] = owner
+	return nil
+}
+
+// Whether a file's share was cached recently enough to be served.
+func (cs *CacheStore) fresh(fileID string) bool {
+	matches, err := filepath.Glob(filepath.Join(cs.path, fileID+".*.gasper"))
+	if err != nil || len(matches) != 1 {
+		return false
+	}
+
+	info, err := os.Stat(matches[0])
+	return err == nil && time.Since(info.ModTime()) < cs.ttl
+}
+
+// CacheDirectory is a cache directory a store config uses, along with the attribute path of its entry.
+type CacheDirectory struct {
+	Attr string
+	Path string
+}
+
+// Lists the cache directories a store config's middleware entries use, so that stores sharing one can be told apart
+// before anything is cached.
+func CacheDirectories(config map[string]interface{}) []*CacheDirectory {
+	entries, _ := middlewareEntries(config)
+
+	var directories []*CacheDirectory
+	for _, entry := range entries {
+		directoryPath, _ := entry.config["directory-path"].(string)
+		if entry.config["type"] != TypeCache || directoryPath == "" {
+			continue
+		}
+
+		if path, err := filepath.Abs(directoryPath); err == nil {
+			directories = append(directories, &CacheDirectory{Attr: entry.path + ".directory-path", Path: path})
+		}
+	}
+	return directories
+}
+
+// Parses a cache entry: 'directory-path' (required) and 'ttl' (a duration, e.g. '1h').
+func cacheFromConfig(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+	directoryPath, ok := config["directory-path"].(string)
+	if !ok || directoryPath == "" {
+		return nil, errors.New("'directory-path' should be the cache directory")
+	}
+
+	ttl := DefaultCacheTTL
+	if err := configDuration(config, "ttl", &ttl); err != nil {
+		return nil, err
+	}
+	return NewCacheStore(store, directoryPath, ttl)
+}
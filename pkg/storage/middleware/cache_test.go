@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Creates a temporary directory, which the returned function removes, forgetting the directories claimed meanwhile.
+func tempCacheDir(t *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "gasper-cache-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	return directory, func() {
+		forgetCacheOwners()
+		_ = os.RemoveAll(directory)
+	}
+}
+
+// Forgets the cache directories claimed by this process, as if it restarted.
+func forgetCacheOwners() {
+	cacheOwnersMutex.Lock()
+	defer cacheOwnersMutex.Unlock()
+	cacheOwners = make(map[string]string)
+}
+
+func TestCacheStore(t *testing.T) {
+	directory, cleanup := tempCacheDir(t)
+	defer cleanup()
+
+	store := newFakeStore()
+	cs, err := NewCacheStore(store, directory, time.Hour)
+	if err != nil {
+		t.Fatalf("new cache store: %v", err)
+	}
+
+	if err := cs.Put(&shares.Share{ID: "1", FileID: "file", Data: []byte("data")}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// Reads are served from the cache once filled.
+	store.script()
+	if share, err := cs.Get("file"); err != nil || string(share.Data) != "data" {
+		t.Fatalf("get: %v (%v)", share, err)
+	} else if store.callCount() != 0 {
+		t.Errorf("%d calls reached the store, want none", store.callCount())
+	}
+
+	if err := cs.Delete("file"); err != nil {
+		t.Fatalf("delete: %v", err)
+	} else if matches, _ := filepath.Glob(filepath.Join(directory, "file.*")); len(matches) != 0 {
+		t.Errorf("deleted share still cached: %v", matches)
+	}
+}
+
+func TestCacheStoreExpired(t *testing.T) {
+	directory, cleanup := tempCacheDir(t)
+	defer cleanup()
+
+	store := newFakeStore()
+	store.shares["file"] = &shares.Share{ID: "1", FileID: "file", Data: []byte("data")}
+	cs, err := NewCacheStore(store, directory, 0)
+	if err != nil {
+		t.Fatalf("new cache store: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cs.Get("file"); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if store.callCount() != 2 {
+		t.Errorf("%d calls reached the store, want 2", store.callCount())
+	}
+}
+
+func TestCacheStoreSharedDirectory(t *testing.T) {
+	directory, cleanup := tempCacheDir(t)
+	defer cleanup()
+
+	store, other := newFakeStore(), newFakeStore()
+	other.name = "other"
+
+	cs, err := NewCacheStore(store, directory, time.Hour)
+	if err != nil {
+		t.Fatalf("new cache store: %v", err)
+	}
+
+	// Paths are compared once resolved.
+	if _, err := NewCacheStore(other, directory+"/.", time.Hour); errors.Cause(err) != ErrSharedCacheDirectory {
+		t.Fatalf("claim claimed directory: %v, want %v", err, ErrSharedCacheDirectory)
+	} else if _, err := NewCacheStore(store, directory, time.Hour); err != nil {
+		t.Fatalf("claim own directory again: %v", err)
+	}
+
+	// Once a share is cached, the directory's owner is recorded for other processes too.
+	if err := cs.Put(&shares.Share{ID: "1", FileID: "file"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	forgetCacheOwners()
+
+	if _, err := NewCacheStore(other, directory, time.Hour); errors.Cause(err) != ErrSharedCacheDirectory {
+		t.Errorf("claim directory recorded as owned: %v, want %v", err, ErrSharedCacheDirectory)
+	}
+}
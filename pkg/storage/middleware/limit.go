@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// RateLimitStore limits how many requests per second a store gets, letting bursts through.
+type RateLimitStore struct {
+	wrapper
+	requests *bucket
+}
+
+func NewRateLimitStore(store stores.Store, requestsPerSecond float64, burst int) *RateLimitStore {
+	return &RateLimitStore{wrapper: wrapper{store: store}, requests: newBucket(requestsPerSecond, float64(burst))}
+}
+
+func (rls *RateLimitStore) Available() (bool, error) {
+	rls.requests.take(1)
+	return rls.store.Available()
+}
+
+func (rls *RateLimitStore) Put(share *shares.Share) error {
+	rls.requests.take(1)
+	return rls.store.Put(share)
+}
+
+func (rls *RateLimitStore) Get(fileID string) (*shares.Share, error) {
+	rls.requests.take(1)
+	return rls.store.Get(fileID)
+}
+
+func (rls *RateLimitStore) Delete(fileID string) error {
+	rls.requests.take(1)
+	return rls.store.Delete(fileID)
+}
+
+func (rls *RateLimitStore) List() ([]string, error) {
+	rls.requests.take(1)
+	return rls.store.List()
+}
+
+// BandwidthLimitStore limits how many bytes of share data per second go to and come from a store, on average.
+// Shares are sent right away while under the limit, and the next transfers wait for the debt to be paid off.
+type BandwidthLimitStore struct {
+	wrapper
+	bytes *bucket
+}
+
+func NewBandwidthLimitStore(store stores.Store, bytesPerSecond float64) *BandwidthLimitStore {
+	return &BandwidthLimitStore{wrapper: wrapper{store: store}, bytes: newBucket(bytesPerSecond, bytesPerSecond)}
+}
+
+func (bls *BandwidthLimitStore) Available() (bool, error) {
+	return bls.store.Available()
+}
+
+func (bls *BandwidthLimitStore) Put(share *shares.Share) error {
+	bls.bytes.take(float64(len(share.Data)))
+	return bls.store.Put(share)
+}
+
+func (bls *BandwidthLimitStore) Get(fileID string) (*shares.Share, error) {
+	share, err := bls.store.Get(fileID)
+	if err == nil {
+		bls.bytes.take(float64(len(share.Data)))
+	}
+	return share, err
+}
+
+func (bls *BandwidthLimitStore) Delete(fileID string) error {
+	return bls.store.Delete(fileID)
+}
+
+func (bls *BandwidthLimitStore) List() ([]string, error) {
+	return bls.store.List()
+}
+
+// Token bucket, refilled at a steady rate up to its burst size.
+type bucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64) *bucket {
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Takes tokens, waiting for them if there aren't enough. Taking more tokens than the burst size runs into debt, which
+// later takers wait for.
+func (b *bucket) take(tokens float64) {
+	b.mutex.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens < tokens {
+		wait = time.Duration((tokens - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens -= tokens
+	b.mutex.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Parses a rate limit entry: 'requests-per-second' (required) and 'burst' (default: 1).
+func rateLimitFromConfig(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+	requestsPerSecond, burst := 0.0, 1
+	if err := configFloat(config, "requests-per-second", &requestsPerSecond); err != nil {
+		return nil, err
+	} else if err := configInt(config, "burst", &burst); err != nil {
+		return nil, err
+	}
+
+	if requestsPerSecond <= 0 {
+		return nil, errors.New("'requests-per-second' should be positive")
+	} else if burst < 1 {
+		return nil, errors.New("'burst' should be at least 1")
+	}
+	return NewRateLimitStore(store, requestsPerSecond, burst), nil
+}
+
+// Parses a bandwidth limit entry: 'bytes-per-second' (required).
+func bandwidthLimitFromConfig(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+	bytesPerSecond := 0.0
+	if err := configFloat(config, "bytes-per-second", &bytesPerSecond); err != nil {
+		return nil, err
+	} else if bytesPerSecond <= 0 {
+		return nil, errors.New("'bytes-per-second' should be positive")
+	}
+	return NewBandwidthLimitStore(store, bytesPerSecond), nil
+}
@@ -0,0 +1,309 @@
+// Package middleware wraps stores with extra behavior (e.g. retries, caching, quotas), whatever their backend.
+// Each store entry of the stores config lists the middleware it is wrapped with.
+package middleware
+
+import (
+	"fmt"
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
+)
+
+// Store config attributes configuring middleware.
+const (
+	// List of middleware entries, each of them an object of a 'type' and its own attributes.
+	MiddlewareAttr = "middleware"
+
+	// Shorthands for retry and circuit breaker entries, wrapping the store before the listed middleware.
+	RetryAttr          = "retry"
+	CircuitBreakerAttr = "circuit-breaker"
+)
+
+// Supported middleware types.
+const (
+	TypeRetry          = "retry"
+	TypeCircuitBreaker = "circuit-breaker"
+	TypeCache          = "cache"
+	TypeRateLimit      = "rate-limit"
+	TypeBandwidthLimit = "bandwidth-limit"
+	TypeQuota          = "quota"
+	TypeReadOnly       = "read-only"
+)
+
+var (
+	ErrInvalidMiddlewareAttr = errors.New("invalid 'middleware' attribute (list of objects)")
+	ErrUnknownMiddlewareType = errors.New("unknown middleware type")
+)
+
+// Factory wraps a store with a middleware, configured by its middleware entry.
+type Factory func(store stores.Store, config map[string]interface{}) (stores.Store, error)
+
+var factories = map[string]Factory{
+	TypeRetry: func(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+		options, err := retryOptionsFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryStore(store, options), nil
+	},
+	TypeCircuitBreaker: func(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+		options, err := breakerOptionsFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewCircuitBreakerStore(store, options), nil
+	},
+	TypeCache:          cacheFromConfig,
+	TypeRateLimit:      rateLimitFromConfig,
+	TypeBandwidthLimit: bandwidthLimitFromConfig,
+	TypeQuota:          quotaFromConfig,
+	TypeReadOnly: func(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+		return NewReadOnlyStore(store), nil
+	},
+}
+
+// Registers a middleware type, so that stores config entries can be wrapped with it.
+// Note: not safe for concurrent use, register middleware on initialization.
+func Register(middlewareType string, factory Factory) {
+	factories[middlewareType] = factory
+}
+
+// Returns the registered middleware types, sorted.
+func Types() []string {
+	types := make([]string, 0, len(factories))
+	for middlewareType := range factories {
+		types = append(types, middlewareType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Wraps a store with the middleware its config asks for, if any: first with retries and circuit breaking if set as
+// attributes of their own, then with each of the listed middleware in order - the first one wrapping the store
+// itself, and the last one being called first.
+func FromConfig(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+	entries, err := middlewareEntries(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if store, err = entry.wrap(store); err != nil {
+			return nil, errors.WithMessagef(err, "'%s'", entry.path)
+		}
+	}
+	return store, nil
+}
+
+// Checks the middleware a store config asks for, without wrapping any actual store. Returns every problem found,
+// each of them for the attribute path of its entry (e.g. 'middleware[1]').
+// Note: entries are checked by wrapping a placeholder store, so factories should only parse their config.
+func Validate(config map[string]interface{}) stores.ConfigErrors {
+	entries, err := middlewareEntries(config)
+	if configErr, ok := err.(*stores.ConfigError); ok {
+		return stores.ConfigErrors{configErr}
+	}
+
+	configErrors := make(stores.ConfigErrors, 0)
+	for _, entry := range entries {
+		if _, err := entry.wrap(placeholder{}); err != nil {
+			configErrors = append(configErrors, &stores.ConfigError{Attr: entry.path, Err: err})
+		}
+	}
+	return configErrors
+}
+
+// Middleware entry of a store config, along with its attribute path.
+type entry struct {
+	path   string
+	config map[string]interface{}
+}
+
+func (e *entry) wrap(store stores.Store) (stores.Store, error) {
+	middlewareType, _ := e.config["type"].(string)
+	factory, ok := factories[middlewareType]
+	if !ok {
+		return nil, errors.WithMessagef(ErrUnknownMiddlewareType, "'%v'", e.config["type"])
+	}
+	return factory(store, e.config)
+}
+
+// Lists a store config's middleware entries, shorthand attributes first.
+func middlewareEntries(config map[string]interface{}) ([]*entry, error) {
+	entries := make([]*entry, 0)
+	for _, attr := range []string{RetryAttr, CircuitBreakerAttr} {
+		raw, ok := config[attr]
+		if !ok {
+			continue
+		}
+
+		attrConfig, err := configMap(raw)
+		if err != nil {
+			return nil, &stores.ConfigError{Attr: attr, Err: err}
+		}
+
+		typed := map[string]interface{}{"type": attr}
+		for key, value := range attrConfig {
+			typed[key] = value
+		}
+		entries = append(entries, &entry{path: attr, config: typed})
+	}
+
+	raw, ok := config[MiddlewareAttr]
+	if !ok {
+		return entries, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, &stores.ConfigError{Attr: MiddlewareAttr, Err: ErrInvalidMiddlewareAttr}
+	}
+
+	for i, entryRaw := range list {
+		path := fmt.Sprintf("%s[%d]", MiddlewareAttr, i)
+		entryConfig, err := configMap(entryRaw)
+		if err != nil {
+			return nil, &stores.ConfigError{Attr: path, Err: ErrInvalidMiddlewareAttr}
+		}
+		entries = append(entries, &entry{path: path, config: entryConfig})
+	}
+	return entries, nil
+}
+
+// Returns the store a middleware wraps, all the way down.
+func Unwrap(store stores.Store) stores.Store {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() stores.Store })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+// Forwards what middleware doesn't change to the wrapped store, including optional store interfaces.
+type wrapper struct {
+	store stores.Store
+}
+
+func (w *wrapper) Unwrap() stores.Store {
+	return w.store
+}
+
+func (w *wrapper) Type() string {
+	return w.store.Type()
+}
+
+func (w *wrapper) Name() string {
+	return w.store.Name()
+}
+
+// Returns the wrapped store's free space, or ErrCapacityUnknown if it doesn't report it.
+func (w *wrapper) FreeSpace() (uint64, error) {
+	reporter, ok := w.store.(stores.CapacityReporter)
+	if !ok {
+		return 0, stores.ErrCapacityUnknown
+	}
+	return reporter.FreeSpace()
+}
+
+// Returns the wrapped store's strays, or none if it doesn't list them.
+func (w *wrapper) ListStrays() ([]string, error) {
+	lister, ok := w.store.(stores.StrayLister)
+	if !ok {
+		return nil, nil
+	}
+	return lister.ListStrays()
+}
+
+// Returns the wrapped store's share sizes, or ErrSizesUnknown if it doesn't list them.
+func (w *wrapper) ListSizes() (map[string]uint64, error) {
+	lister, ok := w.store.(stores.SizeLister)
+	if !ok {
+		return nil, stores.ErrSizesUnknown
+	}
+	return lister.ListSizes()
+}
+
+// Store standing in for actual ones when validating middleware config.
+type placeholder struct{}
+
+func (placeholder) Type() string                      { return "placeholder" }
+func (placeholder) Name() string                      { return "placeholder" }
+func (placeholder) Available() (bool, error)          { return false, nil }
+func (placeholder) Put(*shares.Share) error           { return nil }
+func (placeholder) Get(string) (*shares.Share, error) { return nil, stores.ErrShareNotExists }
+func (placeholder) Delete(string) error               { return stores.ErrShareNotExists }
+func (placeholder) List() ([]string, error)           { return nil, nil }
+
+// Config helpers.
+
+func configMap(raw interface{}) (map[string]interface{}, error) {
+	config, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("should be an object")
+	}
+	return config, nil
+}
+
+func configInt(config map[string]interface{}, attr string, value *int) error {
+	raw, ok := config[attr]
+	if !ok {
+		return nil
+	}
+
+	number, ok := configNumber(raw)
+	if !ok || number != float64(int(number)) || number < 0 {
+		return errors.Errorf("'%s' should be a non-negative integer", attr)
+	}
+	*value = int(number)
+	return nil
+}
+
+func configFloat(config map[string]interface{}, attr string, value *float64) error {
+	raw, ok := config[attr]
+	if !ok {
+		return nil
+	}
+
+	number, ok := configNumber(raw)
+	if !ok || number < 0 {
+		return errors.Errorf("'%s' should be a non-negative number", attr)
+	}
+	*value = number
+	return nil
+}
+
+func configDuration(config map[string]interface{}, attr string, value *time.Duration) error {
+	raw, ok := config[attr]
+	if !ok {
+		return nil
+	}
+
+	text, ok := raw.(string)
+	if !ok {
+		return errors.Errorf("'%s' should be a duration (e.g. '500ms')", attr)
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil || duration < 0 {
+		return errors.Errorf("'%s' should be a non-negative duration (e.g. '500ms')", attr)
+	}
+	*value = duration
+	return nil
+}
+
+// Note: numbers are decoded as floats from JSON configs, but as integers from YAML ones.
+func configNumber(raw interface{}) (float64, bool) {
+	switch number := raw.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	}
+	return 0, false
+}
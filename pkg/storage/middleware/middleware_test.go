@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"sort"
+	"sync"
+)
+
+// Error telling whether it's temporary, as network errors do.
+type testError struct {
+	temporary bool
+}
+
+func (e *testError) Error() string   { return "test error" }
+func (e *testError) Temporary() bool { return e.temporary }
+
+var (
+	errTransient = &testError{temporary: true}
+	errPermanent = &testError{temporary: false}
+)
+
+// In-memory store, failing its next calls with scripted errors.
+type fakeStore struct {
+	name   string
+	mutex  sync.Mutex
+	shares map[string]*shares.Share
+	errs   []error // Returned by the next calls, in order, before they reach the shares.
+	calls  int
+}
+
+func newFakeStore(errs ...error) *fakeStore {
+	return &fakeStore{name: "fake", shares: make(map[string]*shares.Share), errs: errs}
+}
+
+func (fs *fakeStore) Type() string { return "fake" }
+func (fs *fakeStore) Name() string { return fs.name }
+
+func (fs *fakeStore) Available() (bool, error) {
+	if err := fs.call(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fs *fakeStore) Put(share *shares.Share) error {
+	if err := fs.call(); err != nil {
+		return err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.shares[share.FileID] = share
+	return nil
+}
+
+func (fs *fakeStore) Get(fileID string) (*shares.Share, error) {
+	if err := fs.call(); err != nil {
+		return nil, err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	share, ok := fs.shares[fileID]
+	if !ok {
+		return nil, stores.ErrShareNotExists
+	}
+	return share, nil
+}
+
+func (fs *fakeStore) Delete(fileID string) error {
+	if err := fs.call(); err != nil {
+		return err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if _, ok := fs.shares[fileID]; !ok {
+		return stores.ErrShareNotExists
+	}
+	delete(fs.shares, fileID)
+	return nil
+}
+
+func (fs *fakeStore) List() ([]string, error) {
+	if err := fs.call(); err != nil {
+		return nil, err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fileIDs := make([]string, 0, len(fs.shares))
+	for fileID := range fs.shares {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+	return fileIDs, nil
+}
+
+// Counts a call, and returns its scripted error if any.
+func (fs *fakeStore) call() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.calls++
+	if len(fs.errs) == 0 {
+		return nil
+	}
+
+	err := fs.errs[0]
+	fs.errs = fs.errs[1:]
+	return err
+}
+
+// Scripts the errors of the next calls, and resets the call count.
+func (fs *fakeStore) script(errs ...error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.errs, fs.calls = errs, 0
+}
+
+func (fs *fakeStore) callCount() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.calls
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+var ErrQuotaExceeded = errors.New("store's quota would be exceeded")
+
+// QuotaStore caps how many bytes of shares a store holds (as stored, along with their manifests): puts which would take
+// it over its quota fail with ErrQuotaExceeded. It also caps the free space the store reports, for capacity-aware
+// placement.
+// Usage is measured on first use - from the share sizes the store lists (see stores.SizeLister), or by reading its
+// shares if it can't - then kept up to date by Put and Delete. Shares put by other machines meanwhile aren't accounted
+// for.
+type QuotaStore struct {
+	wrapper
+	maxBytes uint64
+
+	mutex sync.Mutex
+	sizes map[string]uint64 // Share size, by file ID. Nil until measured.
+	used  uint64
+}
+
+func NewQuotaStore(store stores.Store, maxBytes uint64) *QuotaStore {
+	return &QuotaStore{wrapper: wrapper{store: store}, maxBytes: maxBytes}
+}
+
+func (qs *QuotaStore) Available() (bool, error) {
+	return qs.store.Available()
+}
+
+func (qs *QuotaStore) Put(share *shares.Share) error {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if err := qs.measure(); err != nil {
+		return err
+	}
+
+	size, err := shareSize(share)
+	if err != nil {
+		return err
+	}
+
+	if used := qs.used - qs.sizes[share.FileID] + size; used > qs.maxBytes {
+		return errors.WithMessagef(ErrQuotaExceeded, "store '%s' (%s): %d of %d bytes used, share is %d bytes",
+			qs.store.Name(), qs.store.Type(), qs.used, qs.maxBytes, size)
+	}
+
+	if err := qs.store.Put(share); err != nil {
+		return err
+	}
+
+	qs.used = qs.used - qs.sizes[share.FileID] + size
+	qs.sizes[share.FileID] = size
+	return nil
+}
+
+func (qs *QuotaStore) Get(fileID string) (*shares.Share, error) {
+	return qs.store.Get(fileID)
+}
+
+func (qs *QuotaStore) Delete(fileID string) error {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	err := qs.store.Delete(fileID)
+	if (err == nil || err == stores.ErrShareNotExists) && qs.sizes != nil {
+		qs.used -= qs.sizes[fileID]
+		delete(qs.sizes, fileID)
+	}
+	return err
+}
+
+func (qs *QuotaStore) List() ([]string, error) {
+	return qs.store.List()
+}
+
+// Returns whatever is left of the quota, or the store's own free space if lower.
+func (qs *QuotaStore) FreeSpace() (uint64, error) {
+	qs.mutex.Lock()
+	err := qs.measure()
+	left := qs.maxBytes - qs.used
+	if qs.used > qs.maxBytes {
+		left = 0
+	}
+	qs.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	free, err := qs.wrapper.FreeSpace()
+	if err == stores.ErrCapacityUnknown || (err == nil && free > left) {
+		return left, nil
+	}
+	return free, err
+}
+
+// Measures the store's usage, unless already done. Must be called with the mutex locked.
+func (qs *QuotaStore) measure() error {
+	if qs.sizes != nil {
+		return nil
+	}
+
+	sizes, err := qs.wrapper.ListSizes()
+	if err == stores.ErrSizesUnknown {
+		sizes, err = qs.readSizes()
+	}
+	if err != nil {
+		return errors.WithMessage(err, "measuring quota usage")
+	}
+
+	used := uint64(0)
+	for _, size := range sizes {
+		used += size
+	}
+
+	qs.sizes, qs.used = sizes, used
+	return nil
+}
+
+// Reads every share the store holds to tell their sizes, for stores which don't list them.
+func (qs *QuotaStore) readSizes() (map[string]uint64, error) {
+	fileIDs, err := qs.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]uint64, len(fileIDs))
+	for _, fileID := range fileIDs {
+		share, err := qs.store.Get(fileID)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "share of file '%s'", fileID)
+		}
+
+		if sizes[fileID], err = shareSize(share); err != nil {
+			return nil, err
+		}
+	}
+	return sizes, nil
+}
+
+// Returns a share's size as stored, along with its manifest.
+func shareSize(share *shares.Share) (uint64, error) {
+	data, err := shares.Encode(share)
+	if err != nil {
+		return 0, errors.WithMessage(err, "encode share")
+	}
+	return uint64(len(data)), nil
+}
+
+// Parses a quota entry: 'max-bytes' (required).
+func quotaFromConfig(store stores.Store, config map[string]interface{}) (stores.Store, error) {
+	if _, ok := config["max-bytes"]; !ok {
+		return nil, errors.New("'max-bytes' should be the quota, in bytes")
+	}
+
+	maxBytes := 0
+	if err := configInt(config, "max-bytes", &maxBytes); err != nil {
+		return nil, err
+	}
+	return NewQuotaStore(store, uint64(maxBytes)), nil
+}
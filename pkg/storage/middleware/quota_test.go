@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+// Share of the given size as stored, as shares without manifest are stored as their data only.
+func sizedShare(fileID string, size int) *shares.Share {
+	return &shares.Share{ID: "1", FileID: fileID, Data: make([]byte, size)}
+}
+
+func TestQuotaStore(t *testing.T) {
+	store := newFakeStore()
+	store.shares["a"] = sizedShare("a", 4) // Held before the quota applies, and measured on first use.
+	qs := NewQuotaStore(store, 10)
+
+	if err := qs.Put(sizedShare("b", 6)); err != nil {
+		t.Fatalf("put up to the quota: %v", err)
+	} else if err := qs.Put(sizedShare("c", 1)); errors.Cause(err) != ErrQuotaExceeded {
+		t.Fatalf("put over the quota: %v, want %v", err, ErrQuotaExceeded)
+	} else if fileIDs, _ := store.List(); !reflect.DeepEqual(fileIDs, []string{"a", "b"}) {
+		t.Fatalf("stored file IDs: %v, want a and b", fileIDs)
+	}
+
+	// Replacing a share only accounts for the size difference.
+	if err := qs.Put(sizedShare("a", 3)); err != nil {
+		t.Fatalf("put a smaller replacement: %v", err)
+	} else if free, err := qs.FreeSpace(); err != nil || free != 1 {
+		t.Errorf("free space: %d (%v), want 1", free, err)
+	}
+
+	// Deleting a share frees its size.
+	if err := qs.Delete("b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	} else if err := qs.Put(sizedShare("c", 7)); err != nil {
+		t.Errorf("put once freed: %v", err)
+	}
+}
+
+// Failed puts don't count against the quota.
+func TestQuotaStoreFailedPut(t *testing.T) {
+	store := newFakeStore()
+	qs := NewQuotaStore(store, 10)
+
+	store.script(nil, errTransient) // Measuring lists the store first.
+	if err := qs.Put(sizedShare("a", 8)); errors.Cause(err) != errTransient {
+		t.Fatalf("put: %v, want %v", err, errTransient)
+	} else if err := qs.Put(sizedShare("b", 8)); err != nil {
+		t.Errorf("put after failed put: %v", err)
+	} else if _, err := store.Get("a"); errors.Cause(err) != stores.ErrShareNotExists {
+		t.Errorf("get failed put: %v, want %v", err, stores.ErrShareNotExists)
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+)
+
+var ErrReadOnly = errors.New("store is read-only")
+
+// ReadOnlyStore lets shares be read from a store, but not put or deleted (e.g. while migrating away from it).
+// It reports no free space, so that capacity-aware placement skips it.
+type ReadOnlyStore struct {
+	wrapper
+}
+
+func NewReadOnlyStore(store stores.Store) *ReadOnlyStore {
+	return &ReadOnlyStore{wrapper: wrapper{store: store}}
+}
+
+func (ros *ReadOnlyStore) Available() (bool, error) {
+	return ros.store.Available()
+}
+
+func (ros *ReadOnlyStore) Put(share *shares.Share) error {
+	return errors.WithMessagef(ErrReadOnly, "store '%s' (%s)", ros.store.Name(), ros.store.Type())
+}
+
+func (ros *ReadOnlyStore) Get(fileID string) (*shares.Share, error) {
+	return ros.store.Get(fileID)
+}
+
+func (ros *ReadOnlyStore) Delete(fileID string) error {
+	return errors.WithMessagef(ErrReadOnly, "store '%s' (%s)", ros.store.Name(), ros.store.Type())
+}
+
+func (ros *ReadOnlyStore) List() ([]string, error) {
+	return ros.store.List()
+}
+
+func (ros *ReadOnlyStore) FreeSpace() (uint64, error) {
+	return 0, nil
+}
+
+// Whether a store is wrapped with the read-only middleware, at any depth.
+func IsReadOnly(store stores.Store) bool {
+	for {
+		if _, ok := store.(*ReadOnlyStore); ok {
+			return true
+		}
+
+		wrapper, ok := store.(interface{ Unwrap() stores.Store })
+		if !ok {
+			return false
+		}
+		store = wrapper.Unwrap()
+	}
+}
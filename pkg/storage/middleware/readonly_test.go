@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestReadOnlyStore(t *testing.T) {
+	store := newFakeStore()
+	store.shares["file"] = &shares.Share{ID: "1", FileID: "file"}
+	ros := NewReadOnlyStore(store)
+
+	if err := ros.Put(&shares.Share{ID: "1", FileID: "other"}); errors.Cause(err) != ErrReadOnly {
+		t.Errorf("put: %v, want %v", err, ErrReadOnly)
+	} else if err := ros.Delete("file"); errors.Cause(err) != ErrReadOnly {
+		t.Errorf("delete: %v, want %v", err, ErrReadOnly)
+	} else if store.callCount() != 0 {
+		t.Errorf("%d calls reached the store, want none", store.callCount())
+	}
+
+	if share, err := ros.Get("file"); err != nil || share.FileID != "file" {
+		t.Errorf("get: %v (%v)", share, err)
+	} else if free, err := ros.FreeSpace(); err != nil || free != 0 {
+		t.Errorf("free space: %d (%v), want 0", free, err)
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	store := newFakeStore()
+	wrapped := NewRetryStore(NewReadOnlyStore(NewCircuitBreakerStore(store, DefaultCircuitBreakerOptions)),
+		DefaultRetryOptions)
+
+	if !IsReadOnly(wrapped) {
+		t.Error("read-only middleware wrapped in retries not told apart")
+	} else if IsReadOnly(NewRetryStore(store, DefaultRetryOptions)) {
+		t.Error("store without read-only middleware told read-only")
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryOptions configures retries of failed store operations.
+type RetryOptions struct {
+	// Attempts per operation, the first one included.
+	Attempts int
+
+	// Backoff before the first retry, multiplied after each retry, up to the max backoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Fraction of each backoff randomly added or taken off, so that clients don't retry in lockstep.
+	Jitter float64
+}
+
+// DefaultRetryOptions are used for whatever a store's retry config doesn't set.
+var DefaultRetryOptions = RetryOptions{
+	Attempts:       3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// RetryStore retries a store's failed operations, with exponential backoff and jitter.
+// Failures telling the share doesn't exist (or matches more than once) are final, as are errors reporting themselves
+// as not temporary. Put and Delete are idempotent - putting a share replaces the store's share of the same file - so
+// they're retried as is. A retried Delete failing as the share doesn't exist anymore means an earlier attempt went
+// through, so it succeeds.
+type RetryStore struct {
+	wrapper
+	options RetryOptions
+}
+
+func NewRetryStore(store stores.Store, options RetryOptions) *RetryStore {
+	return &RetryStore{wrapper: wrapper{store: store}, options: options}
+}
+
+func (rs *RetryStore) Available() (bool, error) {
+	var available bool
+	err := rs.retry(func() error {
+		var err error
+		available, err = rs.store.Available()
+		return err
+	})
+	return available, err
+}
+
+func (rs *RetryStore) Put(share *shares.Share) error {
+	return rs.retry(func() error {
+		return rs.store.Put(share)
+	})
+}
+
+func (rs *RetryStore) Get(fileID string) (*shares.Share, error) {
+	var share *shares.Share
+	err := rs.retry(func() error {
+		var err error
+		share, err = rs.store.Get(fileID)
+		return err
+	})
+	return share, err
+}
+
+func (rs *RetryStore) Delete(fileID string) error {
+	attempt := 0
+	return rs.retry(func() error {
+		attempt++
+		err := rs.store.Delete(fileID)
+		if err == stores.ErrShareNotExists && attempt > 1 {
+			return nil
+		}
+		return err
+	})
+}
+
+func (rs *RetryStore) List() ([]string, error) {
+	var fileIDs []string
+	err := rs.retry(func() error {
+		var err error
+		fileIDs, err = rs.store.List()
+		return err
+	})
+	return fileIDs, err
+}
+
+// Runs an operation until it succeeds, fails for good, or runs out of attempts. Returns its last error.
+func (rs *RetryStore) retry(operation func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = operation(); err == nil || !Retryable(err) || attempt >= rs.options.Attempts {
+			return err
+		}
+		time.Sleep(rs.backoff(attempt))
+	}
+}
+
+// Returns how long to wait before retrying after the given (1-based) attempt.
+func (rs *RetryStore) backoff(attempt int) time.Duration {
+	backoff := float64(rs.options.InitialBackoff) * math.Pow(rs.options.Multiplier, float64(attempt-1))
+	if maxBackoff := float64(rs.options.MaxBackoff); maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	backoff += backoff * rs.options.Jitter * (2*rand.Float64() - 1)
+	if backoff < 0 {
+		return 0
+	}
+	return time.Duration(backoff)
+}
+
+// Whether a failed store operation is worth retrying.
+func Retryable(err error) bool {
+	cause := errors.Cause(err)
+	switch cause {
+	case stores.ErrShareNotExists, stores.ErrMoreThanOneMatch, ErrCircuitOpen, ErrQuotaExceeded, ErrReadOnly:
+		return false
+	}
+
+	if temporary, ok := cause.(interface{ Temporary() bool }); ok {
+		return temporary.Temporary()
+	}
+	return true
+}
+
+// Parses a store's 'retry' attribute: an object of 'attempts', 'initial-backoff', 'max-backoff' (durations, e.g.
+// '200ms'), 'multiplier' and 'jitter'.
+func retryOptionsFromConfig(raw interface{}) (RetryOptions, error) {
+	options := DefaultRetryOptions
+	config, err := configMap(raw)
+	if err != nil {
+		return options, err
+	}
+
+	if err := configInt(config, "attempts", &options.Attempts); err != nil {
+		return options, err
+	} else if err := configDuration(config, "initial-backoff", &options.InitialBackoff); err != nil {
+		return options, err
+	} else if err := configDuration(config, "max-backoff", &options.MaxBackoff); err != nil {
+		return options, err
+	} else if err := configFloat(config, "multiplier", &options.Multiplier); err != nil {
+		return options, err
+	} else if err := configFloat(config, "jitter", &options.Jitter); err != nil {
+		return options, err
+	}
+
+	if options.Attempts < 1 {
+		return options, errors.New("'attempts' should be at least 1")
+	} else if options.Multiplier < 1 {
+		return options, errors.New("'multiplier' should be at least 1")
+	} else if options.Jitter > 1 {
+		return options, errors.New("'jitter' should be at most 1")
+	}
+	return options, nil
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+var testRetryOptions = RetryOptions{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond,
+	Multiplier: 2}
+
+func TestRetryBackoff(t *testing.T) {
+	rs := NewRetryStore(newFakeStore(), RetryOptions{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second,
+		Multiplier: 2})
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 20, want: time.Second},
+	}
+
+	for _, test := range tests {
+		if backoff := rs.backoff(test.attempt); backoff != test.want {
+			t.Errorf("backoff after attempt %d: %s, want %s", test.attempt, backoff, test.want)
+		}
+	}
+
+	// Jitter stays within its fraction of the backoff, even once capped.
+	rs.options.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if backoff := rs.backoff(1); backoff < 50*time.Millisecond || backoff > 150*time.Millisecond {
+			t.Fatalf("jittered backoff %s, want within 50ms of 100ms", backoff)
+		} else if backoff := rs.backoff(20); backoff < 500*time.Millisecond || backoff > 1500*time.Millisecond {
+			t.Fatalf("jittered capped backoff %s, want within 500ms of 1s", backoff)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "plain", err: errors.New("connection reset"), want: true},
+		{name: "temporary", err: errTransient, want: true},
+		{name: "not temporary", err: errors.WithMessage(errPermanent, "put"), want: false},
+		{name: "share not exists", err: stores.ErrShareNotExists, want: false},
+		{name: "more than one match", err: stores.ErrMoreThanOneMatch, want: false},
+		{name: "circuit open", err: errors.WithMessage(ErrCircuitOpen, "store"), want: false},
+		{name: "quota exceeded", err: errors.WithMessage(ErrQuotaExceeded, "store"), want: false},
+		{name: "read-only", err: errors.WithMessage(ErrReadOnly, "store"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Retryable(test.err); got != test.want {
+				t.Errorf("retryable: %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		errs  []error
+		err   error
+		calls int
+	}{
+		{name: "succeeds", calls: 1},
+		{name: "succeeds once retried", errs: []error{errTransient, errTransient}, calls: 3},
+		{name: "runs out of attempts", errs: []error{errTransient, errTransient, errTransient, nil}, err: errTransient,
+			calls: 3},
+		{name: "fails for good", errs: []error{errPermanent, nil}, err: errPermanent, calls: 1},
+		{name: "share not exists", errs: []error{stores.ErrShareNotExists, nil}, err: stores.ErrShareNotExists,
+			calls: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeStore(test.errs...)
+			rs := NewRetryStore(store, testRetryOptions)
+
+			if err := rs.Put(&shares.Share{ID: "1", FileID: "file"}); errors.Cause(err) != test.err {
+				t.Errorf("put: %v, want %v", err, test.err)
+			} else if store.callCount() != test.calls {
+				t.Errorf("%d calls, want %d", store.callCount(), test.calls)
+			}
+		})
+	}
+}
+
+func TestRetryStoreDelete(t *testing.T) {
+	store := newFakeStore()
+	rs := NewRetryStore(store, testRetryOptions)
+
+	// The share not existing anymore once retried means an earlier attempt went through.
+	store.script(errTransient, stores.ErrShareNotExists)
+	if err := rs.Delete("file"); err != nil {
+		t.Errorf("retried delete: %v", err)
+	} else if store.callCount() != 2 {
+		t.Errorf("%d calls, want 2", store.callCount())
+	}
+
+	// It never existed otherwise.
+	store.script()
+	if err := rs.Delete("file"); errors.Cause(err) != stores.ErrShareNotExists {
+		t.Errorf("delete missing share: %v, want %v", err, stores.ErrShareNotExists)
+	} else if store.callCount() != 1 {
+		t.Errorf("%d calls, want 1", store.callCount())
+	}
+}
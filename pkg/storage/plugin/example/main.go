@@ -0,0 +1,39 @@
+// Example store plugin, serving a local directory (its 'directory-path' config attribute) as a 'local-plugin' store.
+// Build it with 'go build -o gasper-local-plugin ./pkg/storage/plugin/example', and use it as:
+//
+//	{"type": "plugin", "command": "/path/to/gasper-local-plugin", "config": {"directory-path": "/mnt/backup"}}
+package main
+
+import (
+	"github.com/gasper/pkg/storage/plugin"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"log"
+)
+
+type localPluginStore struct {
+	*stores.LocalStore
+}
+
+func (lps *localPluginStore) Type() string {
+	return "local-plugin"
+}
+
+func main() {
+	log.SetPrefix("gasper-local-plugin: ")
+
+	if err := plugin.Serve(func(config map[string]interface{}) (stores.Store, error) {
+		directoryPath, ok := config["directory-path"].(string)
+		if !ok {
+			return nil, errors.New("'directory-path' should be the store's directory")
+		}
+
+		store, err := stores.NewLocalStore(directoryPath)
+		if err != nil {
+			return nil, err
+		}
+		return &localPluginStore{LocalStore: store}, nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package plugin is the SDK for store plugins: it serves any store over gasper's store plugin protocol (see package
+// protocol), so that it can be shipped as an executable of its own, and used as a 'plugin' store.
+//
+// A plugin's main function boils down to:
+//
+//	func main() {
+//		if err := plugin.Serve(func(config map[string]interface{}) (stores.Store, error) {
+//			return NewMyStore(config)
+//		}); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/plugin/protocol"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+)
+
+// Factory creates the served store out of its config (the 'config' attribute of its stores config entry).
+type Factory func(config map[string]interface{}) (stores.Store, error)
+
+// Serves a store over stdin and stdout, until stdin is closed.
+// Note: nothing else should be written to stdout meanwhile - log to stderr instead.
+func Serve(factory Factory) error {
+	return ServeIO(os.Stdin, os.Stdout, factory)
+}
+
+// Serves a store, reading requests from in, and writing responses to out, until in is exhausted.
+// The store is created on 'init', which must come first.
+func ServeIO(in io.Reader, out io.Writer, factory Factory) error {
+	decoder, encoder := json.NewDecoder(in), json.NewEncoder(out)
+
+	var store stores.Store
+	for {
+		request := &protocol.Request{}
+		if err := decoder.Decode(request); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.WithMessage(err, "read request")
+		}
+
+		var response *protocol.Response
+		if request.Op == protocol.OpInit {
+			response, store = initialize(request, factory)
+		} else if store == nil {
+			response = failure(errors.Errorf("'%s' request before '%s'", request.Op, protocol.OpInit))
+		} else {
+			response = handle(store, request)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return errors.WithMessage(err, "write response")
+		}
+	}
+}
+
+func initialize(request *protocol.Request, factory Factory) (*protocol.Response, stores.Store) {
+	if request.ProtocolVersion != protocol.Version {
+		return failure(errors.Errorf("unsupported protocol version %d (supported: %d)", request.ProtocolVersion,
+			protocol.Version)), nil
+	}
+
+	config := request.Config
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+
+	store, err := factory(config)
+	if err != nil {
+		return failure(err), nil
+	}
+	return &protocol.Response{Type: store.Type(), Name: store.Name()}, store
+}
+
+// Runs a request against the store.
+func handle(store stores.Store, request *protocol.Request) *protocol.Response {
+	switch request.Op {
+	case protocol.OpAvailable:
+		available, err := store.Available()
+		if err != nil {
+			return failure(err)
+		}
+		return &protocol.Response{Available: available}
+
+	case protocol.OpPut:
+		if request.Share == nil {
+			return failure(errors.New("no share to put"))
+		}
+
+		share, err := shares.Decode(request.Share.FileID, request.Share.ID, request.Share.Data)
+		if err != nil {
+			return failure(errors.WithMessage(err, "decode share"))
+		} else if err := store.Put(share); err != nil {
+			return failure(err)
+		}
+		return &protocol.Response{}
+
+	case protocol.OpGet:
+		share, err := store.Get(request.FileID)
+		if err != nil {
+			return failure(err)
+		}
+
+		data, err := shares.Encode(share)
+		if err != nil {
+			return failure(errors.WithMessage(err, "encode share"))
+		}
+		return &protocol.Response{Share: &protocol.Share{ID: share.ID, FileID: share.FileID, Data: data}}
+
+	case protocol.OpDelete:
+		if err := store.Delete(request.FileID); err != nil {
+			return failure(err)
+		}
+		return &protocol.Response{}
+
+	case protocol.OpList:
+		fileIDs, err := store.List()
+		if err != nil {
+			return failure(err)
+		}
+		return &protocol.Response{FileIDs: fileIDs}
+
+	case protocol.OpFreeSpace:
+		reporter, ok := store.(stores.CapacityReporter)
+		if !ok {
+			return failure(stores.ErrCapacityUnknown)
+		}
+
+		freeSpace, err := reporter.FreeSpace()
+		if err != nil {
+			return failure(err)
+		}
+		return &protocol.Response{FreeSpace: freeSpace}
+	}
+
+	return failure(errors.Errorf("unknown operation '%s'", request.Op))
+}
+
+// Reports a store error, with the code gasper expects for it, if any.
+func failure(err error) *protocol.Response {
+	code := ""
+	switch errors.Cause(err) {
+	case stores.ErrShareNotExists:
+		code = protocol.CodeShareNotExists
+	case stores.ErrMoreThanOneMatch:
+		code = protocol.CodeMoreThanOneMatch
+	case stores.ErrCapacityUnknown:
+		code = protocol.CodeUnsupported
+	}
+	return &protocol.Response{Error: &protocol.Error{Code: code, Message: fmt.Sprint(err)}}
+}
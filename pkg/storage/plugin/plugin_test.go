@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"bytes"
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Store whose List blocks until released, standing for a plugin which hangs.
+type hangingStore struct {
+	*stores.LocalStore
+	release chan struct{}
+}
+
+func (hs *hangingStore) List() ([]string, error) {
+	<-hs.release
+	return nil, nil
+}
+
+// Serves the store the factory creates in a goroutine, and talks to it through pipes.
+// The returned channel receives what ServeIO returned.
+func servePipes(t *testing.T, factory Factory, config map[string]interface{},
+	timeout time.Duration) (*stores.PluginStore, chan error) {
+	requestsReader, requestsWriter := io.Pipe()
+	responsesReader, responsesWriter := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		err := ServeIO(requestsReader, responsesWriter, factory)
+		_ = responsesWriter.Close()
+		served <- err
+	}()
+
+	store, err := stores.NewPluginStoreIO("in-process", requestsWriter, responsesReader, config, timeout)
+	if err != nil {
+		t.Fatalf("new plugin store: %v", err)
+	}
+	return store, served
+}
+
+func localFactory(config map[string]interface{}) (stores.Store, error) {
+	return stores.NewLocalStore(config["directory-path"].(string))
+}
+
+func TestServeIO(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gasper-plugin-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	store, served := servePipes(t, localFactory, map[string]interface{}{"directory-path": directory}, time.Minute)
+	if store.Type() != stores.TypeLocalStore {
+		t.Errorf("store type: '%s', want '%s'", store.Type(), stores.TypeLocalStore)
+	}
+
+	share := &shares.Share{ID: "2", FileID: "file", Data: []byte("share data"),
+		Manifest: &shares.Manifest{ShareCount: 3, MinSharesThreshold: 2}}
+	if err := store.Put(share); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	got, err := store.Get("file")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	} else if got.ID != share.ID || !bytes.Equal(got.Data, share.Data) || !reflect.DeepEqual(got.Manifest,
+		share.Manifest) {
+		t.Errorf("got %+v, want %+v", got, share)
+	}
+
+	if fileIDs, err := store.List(); err != nil {
+		t.Fatalf("list: %v", err)
+	} else if !reflect.DeepEqual(fileIDs, []string{"file"}) {
+		t.Errorf("listed %v, want [file]", fileIDs)
+	}
+
+	if err := store.Delete("file"); err != nil {
+		t.Fatalf("delete: %v", err)
+	} else if _, err := store.Get("file"); err != stores.ErrShareNotExists {
+		t.Errorf("get deleted share: %v, want %v", err, stores.ErrShareNotExists)
+	} else if err := store.Delete("file"); err != stores.ErrShareNotExists {
+		t.Errorf("delete deleted share: %v, want %v", err, stores.ErrShareNotExists)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	} else if err := <-served; err != nil {
+		t.Errorf("serve: %v", err)
+	}
+}
+
+func TestServeIOInitFailure(t *testing.T) {
+	requestsReader, requestsWriter := io.Pipe()
+	responsesReader, responsesWriter := io.Pipe()
+	go func() {
+		_ = ServeIO(requestsReader, responsesWriter, func(map[string]interface{}) (stores.Store, error) {
+			return nil, errors.New("no such bucket")
+		})
+		_ = responsesWriter.Close()
+	}()
+
+	if _, err := stores.NewPluginStoreIO("in-process", requestsWriter, responsesReader, nil, time.Minute); err == nil {
+		t.Fatal("plugin store created, though its store wasn't")
+	}
+}
+
+// A plugin which doesn't answer in time breaks its store, and gets its pipes closed.
+func TestServeIOTimeout(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gasper-plugin-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	release := make(chan struct{})
+	factory := func(config map[string]interface{}) (stores.Store, error) {
+		store, err := localFactory(config)
+		if err != nil {
+			return nil, err
+		}
+		return &hangingStore{LocalStore: store.(*stores.LocalStore), release: release}, nil
+	}
+
+	store, served := servePipes(t, factory, map[string]interface{}{"directory-path": directory},
+		100*time.Millisecond)
+
+	if _, err := store.List(); errors.Cause(err) != stores.ErrPluginTimedOut {
+		t.Fatalf("list: %v, want %v", err, stores.ErrPluginTimedOut)
+	} else if _, err := store.Available(); errors.Cause(err) != stores.ErrPluginTimedOut {
+		t.Errorf("available once timed out: %v, want %v", err, stores.ErrPluginTimedOut)
+	}
+
+	// Its response can't be written anymore.
+	close(release)
+	if err := <-served; err == nil {
+		t.Error("serve succeeded, though its pipes were closed")
+	}
+
+	if err := store.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
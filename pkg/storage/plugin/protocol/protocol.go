@@ -0,0 +1,79 @@
+// Package protocol defines how gasper talks to store plugins: executables it launches, exchanging newline-delimited
+// JSON messages over their stdin (requests) and stdout (responses).
+// Gasper sends one request at a time, and waits for its response before sending the next one. The first request is
+// always 'init'. The plugin should exit once its stdin is closed. Its stderr is passed through to gasper's, for logs -
+// nothing but responses should be written to its stdout.
+package protocol
+
+// Version is the protocol version, sent along with 'init'. Plugins should fail 'init' for versions they don't speak.
+const Version = 1
+
+// Operations.
+const (
+	// Configures the plugin with the store's config, and asks for the store's type and name.
+	OpInit = "init"
+
+	OpAvailable = "available"
+	OpPut       = "put"
+	OpGet       = "get"
+	OpDelete    = "delete"
+	OpList      = "list"
+
+	// Optional: plugins which can't tell their free space fail it with CodeUnsupported.
+	OpFreeSpace = "free-space"
+)
+
+// Error codes, telling gasper how to handle a failed request. Other failures should leave the code empty.
+const (
+	CodeShareNotExists   = "share-not-exists"
+	CodeMoreThanOneMatch = "more-than-one-match"
+	CodeUnsupported      = "unsupported"
+)
+
+type Request struct {
+	Op string `json:"op"`
+
+	// Init only.
+	ProtocolVersion int                    `json:"protocol-version,omitempty"`
+	Config          map[string]interface{} `json:"config,omitempty"`
+
+	// Get and delete only.
+	FileID string `json:"file-id,omitempty"`
+
+	// Put only.
+	Share *Share `json:"share,omitempty"`
+}
+
+// Response to a request. Only the fields of the request's operation are set, or the error if it failed.
+type Response struct {
+	Error *Error `json:"error,omitempty"`
+
+	// Init.
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// Available.
+	Available bool `json:"available,omitempty"`
+
+	// Get.
+	Share *Share `json:"share,omitempty"`
+
+	// List.
+	FileIDs []string `json:"file-ids,omitempty"`
+
+	// Free space, in bytes.
+	FreeSpace uint64 `json:"free-space,omitempty"`
+}
+
+type Error struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Share as exchanged with plugins. Data is the encoded share (its manifest included), which plugins should persist
+// and give back as is. Being bytes, it's base64-encoded in JSON.
+type Share struct {
+	ID     string `json:"id"`
+	FileID string `json:"file-id"`
+	Data   []byte `json:"data"`
+}
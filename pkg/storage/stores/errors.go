@@ -5,10 +5,14 @@ import "github.com/pkg/errors"
 var (
 	ErrShareNotExists   = errors.New("share doesn't exist in store")
 	ErrMoreThanOneMatch = errors.New("found more than one match for share")
+	ErrCapacityUnknown  = errors.New("store's free space is unknown")
+	ErrSizesUnknown     = errors.New("store's share sizes are unknown")
+	ErrPluginTimedOut   = errors.New("plugin timed out")
 
 	// Missing/invalid config attributes errors.
-	ErrInvalidStoreType         = errors.New("invalid store type")
-	ErrMissingStoreTypeAttr     = errors.New("missing store type")
-	ErrMissingDirectoryPathAttr = errors.New("missing 'directory-path' attribute")
-	ErrInvalidDirectoryPathAttr = errors.New("invalid 'directory-path' attribute (strings only)")
+	ErrInvalidStoreType     = errors.New("invalid store type")
+	ErrMissingStoreTypeAttr = errors.New("missing store type")
+	ErrMissingAttr          = errors.New("missing attribute")
+	ErrInvalidAttr          = errors.New("invalid attribute")
+	ErrUnknownAttr          = errors.New("unknown attribute")
 )
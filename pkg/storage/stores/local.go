@@ -13,6 +13,22 @@ import (
 
 const TypeLocalStore = "local"
 
+// LocalStoreConfig is the config schema of local stores.
+type LocalStoreConfig struct {
+	DirectoryPath string `attr:"directory-path" required:"true" doc:"directory to store shares in (absolute path)"`
+}
+
+func init() {
+	Register(&Registration{
+		Type:        TypeLocalStore,
+		Description: "Store shares in a local directory",
+		Config:      LocalStoreConfig{},
+		Factory: func(config interface{}) (Store, error) {
+			return NewLocalStore(config.(*LocalStoreConfig).DirectoryPath)
+		},
+	})
+}
+
 // Stores files in a local directory.
 // Note: needs to get an absolute path.
 type LocalStore struct {
@@ -29,6 +45,10 @@ func (ls *LocalStore) Type() string {
 	return TypeLocalStore
 }
 
+func (ls *LocalStore) Name() string {
+	return ls.directoryPath
+}
+
 func (ls *LocalStore) Available() (bool, error) {
 	_, err := os.Stat(ls.directoryPath)
 	if err != nil {
@@ -40,9 +60,41 @@ func (ls *LocalStore) Available() (bool, error) {
 	return true, err
 }
 
+// Puts a share in store, replacing any share of the same file it already holds.
+// Share is written to a temporary file first and then renamed, so an existing share is never left half-written.
 func (ls *LocalStore) Put(share *shares.Share) error {
 	filePath := path.Join(ls.directoryPath, ls.filename(share))
-	return ioutil.WriteFile(filePath, share.Data, os.ModePerm)
+	tempFilePath := filePath + ".tmp"
+
+	data, err := shares.Encode(share)
+	if err != nil {
+		return errors.WithMessage(err, "encode share")
+	}
+
+	if err := ioutil.WriteFile(tempFilePath, data, os.ModePerm); err != nil {
+		return errors.WithMessagef(err, "write file '%s'", tempFilePath)
+	}
+
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		_ = os.Remove(tempFilePath)
+		return errors.WithMessagef(err, "rename file '%s'", tempFilePath)
+	}
+
+	matches, err := ls.globFileID(share.FileID)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if match == filePath {
+			continue
+		}
+
+		if err := os.RemoveAll(match); err != nil {
+			return errors.WithMessagef(err, "remove replaced share '%s'", match)
+		}
+	}
+	return nil
 }
 
 func (ls *LocalStore) Get(fileID string) (*shares.Share, error) {
@@ -56,18 +108,17 @@ func (ls *LocalStore) Get(fileID string) (*shares.Share, error) {
 		return nil, errors.WithMessagef(err, "read file '%s'", filePath)
 	}
 
-	splitFilename := strings.Split(path.Base(filePath), ".")
-	if len(splitFilename) != 3 { // Note: should not happen, otherwise glob wouldn't match. But just in case...
-		return nil, errors.New("invalid file format (should be: '<file-id>.<share-id>.gasper')")
+	// Note: should not fail, otherwise glob wouldn't match. But just in case...
+	_, shareID, err := ParseShareFilename(filePath)
+	if err != nil {
+		return nil, err
 	}
 
-	shareID := splitFilename[1]
-
-	return &shares.Share{
-		ID:     shareID,
-		FileID: fileID,
-		Data:   data,
-	}, nil
+	share, err := shares.Decode(fileID, shareID, data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "decode share '%s'", filePath)
+	}
+	return share, nil
 }
 
 func (ls *LocalStore) Delete(fileID string) error {
@@ -79,15 +130,80 @@ func (ls *LocalStore) Delete(fileID string) error {
 	return os.RemoveAll(filePath)
 }
 
+func (ls *LocalStore) List() ([]string, error) {
+	pattern := path.Join(ls.directoryPath, "*.*.gasper")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "glob pattern '%s'", pattern)
+	}
+
+	fileIDs := make([]string, 0, len(matches))
+	seen := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		fileID := strings.Split(path.Base(match), ".")[0]
+		if !seen[fileID] {
+			seen[fileID] = true
+			fileIDs = append(fileIDs, fileID)
+		}
+	}
+	return fileIDs, nil
+}
+
+// Lists the size of each share file, by file ID.
+func (ls *LocalStore) ListSizes() (map[string]uint64, error) {
+	pattern := path.Join(ls.directoryPath, "*.*.gasper")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "glob pattern '%s'", pattern)
+	}
+
+	sizes := make(map[string]uint64, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.WithMessagef(err, "stat file '%s'", match)
+		}
+		sizes[strings.Split(path.Base(match), ".")[0]] += uint64(info.Size())
+	}
+	return sizes, nil
+}
+
+// Lists names of files which look like Gasper's (matching '*.gasper*'), but aren't share files.
+func (ls *LocalStore) ListStrays() ([]string, error) {
+	pattern := path.Join(ls.directoryPath, "*.gasper*")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "glob pattern '%s'", pattern)
+	}
+
+	strays := make([]string, 0)
+	for _, match := range matches {
+		if _, _, err := ParseShareFilename(match); err != nil {
+			strays = append(strays, path.Base(match))
+		}
+	}
+	return strays, nil
+}
+
+// Parses the file ID and share ID out of a share file's name ('<file-id>.<share-id>.gasper').
+func ParseShareFilename(filePath string) (string, string, error) {
+	splitFilename := strings.Split(path.Base(filePath), ".")
+	if len(splitFilename) != 3 || splitFilename[2] != "gasper" {
+		return "", "", errors.New("invalid file format (should be: '<file-id>.<share-id>.gasper')")
+	}
+	return splitFilename[0], splitFilename[1], nil
+}
+
 func (ls *LocalStore) filename(share *shares.Share) string {
 	return fmt.Sprintf("%s.%s.gasper", share.FileID, share.ID)
 }
 
 func (ls *LocalStore) findFileByID(fileID string) (string, error) {
-	pattern := path.Join(ls.directoryPath, fmt.Sprintf("%s.*.gasper", fileID))
-	matches, err := filepath.Glob(pattern)
+	matches, err := ls.globFileID(fileID)
 	if err != nil {
-		return "", errors.WithMessagef(err, "glob pattern '%s'", pattern)
+		return "", err
 	}
 
 	if len(matches) == 0 {
@@ -98,3 +214,12 @@ func (ls *LocalStore) findFileByID(fileID string) (string, error) {
 
 	return matches[0], nil
 }
+
+func (ls *LocalStore) globFileID(fileID string) ([]string, error) {
+	pattern := path.Join(ls.directoryPath, fmt.Sprintf("%s.*.gasper", fileID))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "glob pattern '%s'", pattern)
+	}
+	return matches, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !openbsd
+// +build !linux,!darwin,!freebsd,!dragonfly,!openbsd
+
+package stores
+
+// Note: free space is only reported where the syscall package can stat file systems (not on windows or netbsd, e.g.).
+func (ls *LocalStore) FreeSpace() (uint64, error) {
+	return 0, ErrCapacityUnknown
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || dragonfly
+// +build linux darwin freebsd dragonfly
+
+package stores
+
+import (
+	"github.com/pkg/errors"
+	"syscall"
+)
+
+// Returns the free space available to unprivileged users on the store directory's file system.
+func (ls *LocalStore) FreeSpace() (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(ls.directoryPath, &stat); err != nil {
+		return 0, errors.WithMessagef(err, "stat file system of '%s'", ls.directoryPath)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
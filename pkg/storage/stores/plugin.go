@@ -0,0 +1,305 @@
+package stores
+
+import (
+	"encoding/json"
+	"github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/storage/plugin/protocol"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	TypePluginStore = "plugin"
+
+	// Default time a plugin has to answer a request, before it's killed.
+	DefaultPluginTimeout = 5 * time.Minute
+)
+
+// Time a plugin has to exit once its stdin is closed, before it's killed.
+var pluginExitTimeout = 10 * time.Second
+
+// PluginStoreConfig is the config schema of plugin stores.
+type PluginStoreConfig struct {
+	Command string                 `attr:"command" required:"true" doc:"plugin executable"`
+	Args    []string               `attr:"args" doc:"plugin arguments"`
+	Config  map[string]interface{} `attr:"config" doc:"config passed on to the plugin"`
+	Timeout time.Duration          `attr:"timeout" doc:"request timeout, killing the plugin (default: 5m)"`
+}
+
+func init() {
+	Register(&Registration{
+		Type:        TypePluginStore,
+		Description: "Store shares through an external plugin executable, speaking gasper's store plugin protocol",
+		Config:      PluginStoreConfig{},
+		Factory: func(config interface{}) (Store, error) {
+			pluginConfig := config.(*PluginStoreConfig)
+			if pluginConfig.Config == nil {
+				pluginConfig.Config = make(map[string]interface{})
+			}
+			return NewPluginStore(pluginConfig.Command, pluginConfig.Args, pluginConfig.Config, pluginConfig.Timeout)
+		},
+	})
+}
+
+// Stores files through a plugin: an executable speaking gasper's store plugin protocol (see package protocol), which
+// is launched on creation and kept running until the store is closed (or gasper exits).
+// Its type and name are the ones the plugin reports. A plugin which doesn't answer a request in time is killed.
+type PluginStore struct {
+	command   string
+	storeType string
+	name      string
+	timeout   time.Duration
+
+	mutex   sync.Mutex
+	process *exec.Cmd // Nil for plugins talked to over pipes of their own.
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	encoder *json.Encoder
+	decoder *json.Decoder
+	broken  error // Set once talking to the plugin failed, after which every request fails.
+	killed  bool  // Set once the plugin was killed, for not answering in time.
+	closed  bool
+}
+
+// Launches a plugin, and initializes it with its config. A zero timeout stands for DefaultPluginTimeout.
+func NewPluginStore(command string, args []string, config map[string]interface{}, timeout time.Duration) (*PluginStore,
+	error) {
+	if timeout == 0 {
+		timeout = DefaultPluginTimeout
+	}
+
+	process := exec.Command(command, args...)
+	process.Stderr = os.Stderr
+
+	stdin, err := process.StdinPipe()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "plugin '%s' stdin", command)
+	}
+
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "plugin '%s' stdout", command)
+	}
+
+	if err := process.Start(); err != nil {
+		return nil, errors.WithMessagef(err, "launch plugin '%s'", command)
+	}
+	return newPluginStore(command, process, stdin, stdout, config, timeout)
+}
+
+// Talks to a plugin which is already running, writing requests to stdin and reading responses from stdout, and
+// initializes it with its config. The command only names the plugin in errors. A zero timeout stands for
+// DefaultPluginTimeout. Instead of being killed, a plugin which doesn't answer in time has its pipes closed.
+func NewPluginStoreIO(command string, stdin io.WriteCloser, stdout io.ReadCloser, config map[string]interface{},
+	timeout time.Duration) (*PluginStore, error) {
+	if timeout == 0 {
+		timeout = DefaultPluginTimeout
+	}
+	return newPluginStore(command, nil, stdin, stdout, config, timeout)
+}
+
+func newPluginStore(command string, process *exec.Cmd, stdin io.WriteCloser, stdout io.ReadCloser,
+	config map[string]interface{}, timeout time.Duration) (*PluginStore, error) {
+	ps := &PluginStore{
+		command: command,
+		timeout: timeout,
+		process: process,
+		stdin:   stdin,
+		stdout:  stdout,
+		encoder: json.NewEncoder(stdin),
+		decoder: json.NewDecoder(stdout),
+	}
+
+	response, err := ps.call(&protocol.Request{Op: protocol.OpInit, ProtocolVersion: protocol.Version, Config: config})
+	if err != nil {
+		_ = ps.Close()
+		return nil, errors.WithMessage(err, "init")
+	} else if response.Type == "" || response.Name == "" {
+		_ = ps.Close()
+		return nil, errors.Errorf("plugin '%s' reported no store type or name", command)
+	}
+
+	ps.storeType, ps.name = response.Type, response.Name
+	return ps, nil
+}
+
+func (ps *PluginStore) Type() string {
+	return ps.storeType
+}
+
+func (ps *PluginStore) Name() string {
+	return ps.name
+}
+
+func (ps *PluginStore) Available() (bool, error) {
+	response, err := ps.call(&protocol.Request{Op: protocol.OpAvailable})
+	if err != nil {
+		return false, err
+	}
+	return response.Available, nil
+}
+
+func (ps *PluginStore) Put(share *shares.Share) error {
+	data, err := shares.Encode(share)
+	if err != nil {
+		return errors.WithMessage(err, "encode share")
+	}
+
+	_, err = ps.call(&protocol.Request{
+		Op:    protocol.OpPut,
+		Share: &protocol.Share{ID: share.ID, FileID: share.FileID, Data: data},
+	})
+	return err
+}
+
+func (ps *PluginStore) Get(fileID string) (*shares.Share, error) {
+	response, err := ps.call(&protocol.Request{Op: protocol.OpGet, FileID: fileID})
+	if err != nil {
+		return nil, err
+	} else if response.Share == nil {
+		return nil, errors.Errorf("plugin '%s' returned no share", ps.command)
+	}
+
+	share, err := shares.Decode(fileID, response.Share.ID, response.Share.Data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "decode share of file '%s'", fileID)
+	}
+	return share, nil
+}
+
+func (ps *PluginStore) Delete(fileID string) error {
+	_, err := ps.call(&protocol.Request{Op: protocol.OpDelete, FileID: fileID})
+	return err
+}
+
+func (ps *PluginStore) List() ([]string, error) {
+	response, err := ps.call(&protocol.Request{Op: protocol.OpList})
+	if err != nil {
+		return nil, err
+	}
+	return response.FileIDs, nil
+}
+
+// Returns the plugin's free space, or ErrCapacityUnknown if it can't tell.
+func (ps *PluginStore) FreeSpace() (uint64, error) {
+	response, err := ps.call(&protocol.Request{Op: protocol.OpFreeSpace})
+	if err != nil {
+		return 0, err
+	}
+	return response.FreeSpace, nil
+}
+
+// Closes the plugin's stdin, and waits for it to exit, killing it if it doesn't in time.
+// Closing it again does nothing.
+func (ps *PluginStore) Close() error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+
+	if ps.broken == nil {
+		ps.broken = errors.Errorf("plugin '%s' is closed", ps.command)
+	}
+
+	_ = ps.stdin.Close()
+	if ps.process == nil {
+		_ = ps.stdout.Close()
+		return nil
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- ps.process.Wait()
+	}()
+
+	timer := time.NewTimer(pluginExitTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-exited:
+		if err != nil && !ps.killed {
+			return err
+		}
+		return nil
+	case <-timer.C:
+		ps.kill()
+		<-exited
+		return errors.Errorf("plugin '%s' didn't exit within %s of its stdin being closed, killed it", ps.command,
+			pluginExitTimeout)
+	}
+}
+
+// Sends a request to the plugin, and returns its response, or its error.
+// If the plugin doesn't answer in time, it's killed, and the store is broken.
+func (ps *PluginStore) call(request *protocol.Request) (*protocol.Response, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if ps.broken != nil {
+		return nil, ps.broken
+	}
+
+	// Note: once the plugin is killed, its pipes are closed, so an exchange stuck on them ends.
+	exchanged := make(chan error, 1)
+	response := &protocol.Response{}
+	go func() {
+		if err := ps.encoder.Encode(request); err != nil {
+			exchanged <- errors.WithMessagef(err, "send '%s' request to plugin '%s'", request.Op, ps.command)
+		} else if err := ps.decoder.Decode(response); err != nil {
+			exchanged <- errors.WithMessagef(err, "read '%s' response from plugin '%s'", request.Op, ps.command)
+		} else {
+			exchanged <- nil
+		}
+	}()
+
+	timer := time.NewTimer(ps.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-exchanged:
+		if err != nil {
+			ps.broken = err
+			return nil, ps.broken
+		}
+	case <-timer.C:
+		ps.kill()
+		ps.broken = errors.WithMessagef(ErrPluginTimedOut, "plugin '%s' didn't answer '%s' request within %s",
+			ps.command, request.Op, ps.timeout)
+		return nil, ps.broken
+	}
+
+	if response.Error != nil {
+		return nil, pluginError(response.Error)
+	}
+	return response, nil
+}
+
+// Kills the plugin, and closes its pipes.
+func (ps *PluginStore) kill() {
+	if ps.process != nil {
+		_ = ps.process.Process.Kill()
+	}
+	_ = ps.stdin.Close()
+	_ = ps.stdout.Close()
+	ps.killed = true
+}
+
+// Maps a plugin's error to the matching store error.
+func pluginError(err *protocol.Error) error {
+	switch err.Code {
+	case protocol.CodeShareNotExists:
+		return ErrShareNotExists
+	case protocol.CodeMoreThanOneMatch:
+		return ErrMoreThanOneMatch
+	case protocol.CodeUnsupported:
+		return ErrCapacityUnknown
+	}
+	return errors.New(err.Message)
+}
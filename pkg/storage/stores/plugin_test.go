@@ -0,0 +1,53 @@
+package stores
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Launches a shell script plugin, which answers 'init', then runs the given command.
+func newScriptPlugin(t *testing.T, then string) *PluginStore {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+
+	script := `read request; echo '{"type":"script","name":"script"}'; ` + then
+	store, err := NewPluginStore("/bin/sh", []string{"-c", script}, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("new plugin store: %v", err)
+	}
+	return store
+}
+
+func TestPluginStoreClose(t *testing.T) {
+	store := newScriptPlugin(t, "cat > /dev/null")
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	} else if err := store.Close(); err != nil {
+		t.Errorf("close again: %v", err)
+	}
+
+	if _, err := store.List(); err == nil {
+		t.Error("list once closed")
+	}
+}
+
+// A plugin which doesn't exit once its stdin is closed is killed after a grace period.
+func TestPluginStoreCloseKills(t *testing.T) {
+	exitTimeout := pluginExitTimeout
+	pluginExitTimeout = 100 * time.Millisecond
+	defer func() { pluginExitTimeout = exitTimeout }()
+
+	store := newScriptPlugin(t, "exec sleep 60")
+	started := time.Now()
+	if err := store.Close(); err == nil {
+		t.Error("close of a plugin which had to be killed")
+	} else if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("close took %s", elapsed)
+	}
+
+	if !store.killed {
+		t.Error("plugin not killed")
+	}
+}
@@ -0,0 +1,301 @@
+package stores
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Factory creates a store out of its decoded config: a pointer to a filled copy of its registration's config struct.
+type Factory func(config interface{}) (Store, error)
+
+// Registration of a store type, so that stores config entries of that type can be created.
+type Registration struct {
+	Type        string
+	Description string
+
+	// Config struct (not a pointer to it), which is the type's config schema: each field is an attribute, named by
+	// its 'attr' tag, documented by its 'doc' tag, and required if its 'required' tag is "true". Fields may be strings,
+	// booleans, numbers, durations (time.Duration), lists of strings, or objects (map[string]interface{}).
+	Config interface{}
+
+	Factory Factory
+}
+
+// Attr describes a store config attribute, as told by its field in a config struct.
+type Attr struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Required bool   `json:"required,omitempty"`
+	Doc      string `json:"doc,omitempty"`
+
+	field int
+}
+
+// ConfigError tells what's wrong with an attribute of a store config entry.
+type ConfigError struct {
+	Attr string
+	Err  error
+
+	// What the attribute should be instead (e.g. "a string"), for invalid attributes.
+	Expected string
+}
+
+func (ce *ConfigError) Error() string {
+	return fmt.Sprintf("'%s': %s", ce.Attr, ce.Reason())
+}
+
+// Tells what's wrong with the attribute, without naming it.
+func (ce *ConfigError) Reason() string {
+	if ce.Expected != "" {
+		return fmt.Sprintf("%v (should be %s)", ce.Err, ce.Expected)
+	}
+	return ce.Err.Error()
+}
+
+func (ce *ConfigError) Cause() error {
+	return ce.Err
+}
+
+// ConfigErrors lists everything wrong with a store config entry.
+type ConfigErrors []*ConfigError
+
+func (ces ConfigErrors) Error() string {
+	messages := make([]string, 0, len(ces))
+	for _, err := range ces {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+var registrations = make(map[string]*Registration)
+
+// Registers a store type, replacing any registration of the same type.
+// Note: not safe for concurrent use, register stores on initialization. Panics if the config schema is invalid.
+func Register(registration *Registration) {
+	if _, err := Attrs(registration); err != nil {
+		panic(fmt.Sprintf("invalid config schema for store type '%s': %v", registration.Type, err))
+	}
+	registrations[registration.Type] = registration
+}
+
+// Returns the registered store types, sorted by type.
+func Registrations() []*Registration {
+	sorted := make([]*Registration, 0, len(registrations))
+	for _, registration := range registrations {
+		sorted = append(sorted, registration)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Type < sorted[j].Type
+	})
+	return sorted
+}
+
+// Returns a store type's registration, or nil if there's none.
+func Registered(storeType string) *Registration {
+	return registrations[storeType]
+}
+
+// Returns a store type's config attributes, in field order.
+func Attrs(registration *Registration) ([]*Attr, error) {
+	configType := reflect.TypeOf(registration.Config)
+	if configType == nil || configType.Kind() != reflect.Struct {
+		return nil, errors.New("config should be a struct")
+	}
+
+	attrs := make([]*Attr, 0, configType.NumField())
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		name, ok := field.Tag.Lookup("attr")
+		if !ok {
+			continue
+		}
+
+		kind, ok := attrKind(field.Type)
+		if !ok {
+			return nil, errors.Errorf("attribute '%s' has an unsupported type (%s)", name, field.Type)
+		}
+
+		attrs = append(attrs, &Attr{
+			Name:     name,
+			Kind:     kind,
+			Required: field.Tag.Get("required") == "true",
+			Doc:      field.Tag.Get("doc"),
+			field:    i,
+		})
+	}
+	return attrs, nil
+}
+
+// Decodes and validates a store config entry along its type's schema. Returns ConfigErrors, listing every problem
+// found, if it's invalid. Attributes the schema doesn't know (e.g. labels or middleware) are ignored.
+func Decode(registration *Registration, config map[string]interface{}) (interface{}, error) {
+	attrs, err := Attrs(registration)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := reflect.New(reflect.TypeOf(registration.Config))
+	configErrors := make(ConfigErrors, 0)
+	for _, attr := range attrs {
+		raw, ok := config[attr.Name]
+		if !ok {
+			if attr.Required {
+				configErrors = append(configErrors, &ConfigError{Attr: attr.Name, Err: ErrMissingAttr})
+			}
+			continue
+		}
+
+		if !decodeAttr(raw, decoded.Elem().Field(attr.field)) {
+			configErrors = append(configErrors, &ConfigError{Attr: attr.Name, Err: ErrInvalidAttr, Expected: attr.Kind})
+		}
+	}
+
+	if len(configErrors) > 0 {
+		return nil, configErrors
+	}
+	return decoded.Interface(), nil
+}
+
+// Creates a store out of its stores config entry, along its type's registration.
+func FromConfig(config map[string]interface{}) (Store, error) {
+	storeTypeRaw, ok := config["type"]
+	if !ok {
+		return nil, ErrMissingStoreTypeAttr
+	}
+
+	storeType, _ := storeTypeRaw.(string)
+	registration := Registered(storeType)
+	if registration == nil {
+		return nil, errors.WithMessagef(ErrInvalidStoreType, "'%v'", storeTypeRaw)
+	}
+
+	decoded, err := Decode(registration, config)
+	if err != nil {
+		return nil, err
+	}
+	return registration.Factory(decoded)
+}
+
+// Durations are configured as strings (e.g. '30s'), rather than as integers.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func attrKind(fieldType reflect.Type) (string, bool) {
+	if fieldType == durationType {
+		return "a non-negative duration (e.g. '30s')", true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return "a string", true
+	case reflect.Bool:
+		return "a boolean", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer", true
+	case reflect.Float32, reflect.Float64:
+		return "a number", true
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.String {
+			return "a list of strings", true
+		}
+	case reflect.Map:
+		if fieldType.Key().Kind() == reflect.String && fieldType.Elem().Kind() == reflect.Interface {
+			return "an object", true
+		}
+	}
+	return "", false
+}
+
+// Decodes a raw attribute value into its field. Returns false if it doesn't fit.
+// Note: numbers are decoded as floats from JSON configs, but as integers from YAML ones.
+func decodeAttr(raw interface{}, field reflect.Value) bool {
+	if field.Type() == durationType {
+		text, ok := raw.(string)
+		if !ok {
+			return false
+		}
+
+		duration, err := time.ParseDuration(text)
+		if err != nil || duration < 0 {
+			return false
+		}
+		field.SetInt(int64(duration))
+		return true
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		value, ok := raw.(string)
+		field.SetString(value)
+		return ok
+
+	case reflect.Bool:
+		value, ok := raw.(bool)
+		field.SetBool(value)
+		return ok
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := attrNumber(raw)
+		if !ok || number != float64(int64(number)) || field.OverflowInt(int64(number)) {
+			return false
+		}
+		field.SetInt(int64(number))
+		return true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := attrNumber(raw)
+		if !ok || number < 0 || number != float64(uint64(number)) || field.OverflowUint(uint64(number)) {
+			return false
+		}
+		field.SetUint(uint64(number))
+		return true
+
+	case reflect.Float32, reflect.Float64:
+		number, ok := attrNumber(raw)
+		field.SetFloat(number)
+		return ok
+
+	case reflect.Slice:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return false
+		}
+
+		values := make([]string, 0, len(list))
+		for _, itemRaw := range list {
+			item, ok := itemRaw.(string)
+			if !ok {
+				return false
+			}
+			values = append(values, item)
+		}
+		field.Set(reflect.ValueOf(values))
+		return true
+
+	case reflect.Map:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		field.Set(reflect.ValueOf(object))
+		return true
+	}
+	return false
+}
+
+func attrNumber(raw interface{}) (float64, bool) {
+	switch number := raw.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package stores
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Path    string                 `attr:"path" required:"true" doc:"a path"`
+	Verbose bool                   `attr:"verbose"`
+	Retries int                    `attr:"retries"`
+	Size    uint64                 `attr:"size"`
+	Ratio   float64                `attr:"ratio"`
+	Timeout time.Duration          `attr:"timeout"`
+	Args    []string               `attr:"args"`
+	Extra   map[string]interface{} `attr:"extra"`
+	ignored string                 // Untagged, hence not an attribute.
+}
+
+var testRegistration = &Registration{Type: "test", Config: testConfig{}}
+
+func TestAttrs(t *testing.T) {
+	attrs, err := Attrs(testRegistration)
+	if err != nil {
+		t.Fatalf("attrs: %v", err)
+	}
+
+	names := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		names = append(names, attr.Name)
+	}
+	want := []string{"path", "verbose", "retries", "size", "ratio", "timeout", "args", "extra"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("attrs: %v, want %v", names, want)
+	}
+
+	if !attrs[0].Required || attrs[0].Doc != "a path" || attrs[0].Kind != "a string" {
+		t.Errorf("path attr: %+v", attrs[0])
+	} else if attrs[1].Required {
+		t.Errorf("verbose attr is required")
+	}
+
+	if _, err := Attrs(&Registration{Type: "bad", Config: struct {
+		Values []int `attr:"values"`
+	}{}}); err == nil {
+		t.Error("attrs of unsupported type")
+	} else if _, err := Attrs(&Registration{Type: "bad", Config: &testConfig{}}); err == nil {
+		t.Error("attrs of a struct pointer")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   *testConfig
+	}{
+		{
+			name:   "required only",
+			config: map[string]interface{}{"path": "/tmp"},
+			want:   &testConfig{Path: "/tmp"},
+		},
+		{
+			name: "JSON numbers",
+			config: map[string]interface{}{"path": "/tmp", "verbose": true, "retries": float64(-3),
+				"size": float64(1024), "ratio": 0.5, "timeout": "1m30s", "args": []interface{}{"-v"},
+				"extra": map[string]interface{}{"a": 1}},
+			want: &testConfig{Path: "/tmp", Verbose: true, Retries: -3, Size: 1024, Ratio: 0.5,
+				Timeout: 90 * time.Second, Args: []string{"-v"}, Extra: map[string]interface{}{"a": 1}},
+		},
+		{
+			name:   "YAML numbers",
+			config: map[string]interface{}{"path": "/tmp", "retries": 3, "size": int64(1024), "ratio": 2},
+			want:   &testConfig{Path: "/tmp", Retries: 3, Size: 1024, Ratio: 2},
+		},
+		{
+			name:   "unknown attributes ignored",
+			config: map[string]interface{}{"path": "/tmp", "labels": map[string]interface{}{"zone": "a"}},
+			want:   &testConfig{Path: "/tmp"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := Decode(testRegistration, test.config)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			} else if !reflect.DeepEqual(decoded, test.want) {
+				t.Errorf("decoded %+v, want %+v", decoded, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		errs   map[string]error
+	}{
+		{name: "missing required", config: map[string]interface{}{}, errs: map[string]error{"path": ErrMissingAttr}},
+		{
+			name: "wrong types",
+			config: map[string]interface{}{"path": 1, "verbose": "yes", "args": []interface{}{"-v", 1},
+				"extra": "none"},
+			errs: map[string]error{"path": ErrInvalidAttr, "verbose": ErrInvalidAttr, "args": ErrInvalidAttr,
+				"extra": ErrInvalidAttr},
+		},
+		{
+			name:   "invalid numbers",
+			config: map[string]interface{}{"path": "/tmp", "retries": 1.5, "size": float64(-1)},
+			errs:   map[string]error{"retries": ErrInvalidAttr, "size": ErrInvalidAttr},
+		},
+		{
+			name:   "invalid durations",
+			config: map[string]interface{}{"path": "/tmp", "timeout": "soon"},
+			errs:   map[string]error{"timeout": ErrInvalidAttr},
+		},
+		{
+			name:   "negative duration",
+			config: map[string]interface{}{"path": "/tmp", "timeout": "-1s"},
+			errs:   map[string]error{"timeout": ErrInvalidAttr},
+		},
+		{
+			name:   "duration as a number",
+			config: map[string]interface{}{"path": "/tmp", "timeout": float64(30)},
+			errs:   map[string]error{"timeout": ErrInvalidAttr},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode(testRegistration, test.config)
+			configErrors, ok := err.(ConfigErrors)
+			if !ok {
+				t.Fatalf("decode: %v, want config errors", err)
+			}
+
+			errs := make(map[string]error, len(configErrors))
+			for _, configErr := range configErrors {
+				errs[configErr.Attr] = errors.Cause(configErr)
+				if configErr.Err == ErrInvalidAttr && configErr.Expected == "" {
+					t.Errorf("'%s': invalid, but doesn't tell what's expected", configErr.Attr)
+				}
+			}
+
+			if !reflect.DeepEqual(errs, test.errs) {
+				t.Errorf("errors: %v, want %v", errs, test.errs)
+			}
+		})
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, err := FromConfig(map[string]interface{}{"directory-path": "/tmp"}); err != ErrMissingStoreTypeAttr {
+		t.Errorf("missing type: %v, want %v", err, ErrMissingStoreTypeAttr)
+	}
+
+	if _, err := FromConfig(map[string]interface{}{"type": "nope"}); errors.Cause(err) != ErrInvalidStoreType {
+		t.Errorf("unknown type: %v, want %v", err, ErrInvalidStoreType)
+	}
+
+	if _, err := FromConfig(map[string]interface{}{"type": TypeLocalStore}); err == nil {
+		t.Error("local store without directory path created")
+	}
+
+	directory, err := ioutil.TempDir("", "gasper-registry-test")
+	if err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	store, err := FromConfig(map[string]interface{}{"type": TypeLocalStore, "directory-path": directory})
+	if err != nil {
+		t.Fatalf("create local store: %v", err)
+	} else if store.Type() != TypeLocalStore {
+		t.Errorf("store type: '%s', want '%s'", store.Type(), TypeLocalStore)
+	}
+}
+
+// Every registered store type has a valid schema, which is documented.
+func TestRegistrations(t *testing.T) {
+	for _, registration := range Registrations() {
+		attrs, err := Attrs(registration)
+		if err != nil {
+			t.Errorf("type '%s': %v", registration.Type, err)
+		}
+
+		for _, attr := range attrs {
+			if attr.Doc == "" {
+				t.Errorf("type '%s': attribute '%s' isn't documented", registration.Type, attr.Name)
+			}
+		}
+	}
+}
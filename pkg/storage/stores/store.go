@@ -7,6 +7,9 @@ type Store interface {
 	// Store type.
 	Type() string
 
+	// Store name, identifying it among stores of the same type.
+	Name() string
+
 	// Is store available?
 	// Useful especially for remote stores, such as ftp servers or s3 buckets.
 	Available() (bool, error)
@@ -21,4 +24,28 @@ type Store interface {
 	// Deletes a share from store.
 	// If no share with the given File ID exists, returns ErrShareNotExists.
 	Delete(fileID string) error
+
+	// Lists IDs of files which have a share in store.
+	List() ([]string, error)
+}
+
+// StrayLister is implemented by stores which can tell entries looking like Gasper's apart from actual shares.
+type StrayLister interface {
+	// Lists names of entries which aren't shares, though they look like Gasper's (e.g. leftover temporary files, or
+	// malformed share filenames).
+	ListStrays() ([]string, error)
+}
+
+// CapacityReporter is implemented by stores which can tell how much room they have left, for capacity-aware
+// placement.
+type CapacityReporter interface {
+	// Returns store's free space, in bytes.
+	FreeSpace() (uint64, error)
+}
+
+// SizeLister is implemented by stores which can tell how big the shares they hold are without reading them, e.g. for
+// quotas.
+type SizeLister interface {
+	// Returns the size of each held share as stored (encoded along with its manifest), by file ID.
+	ListSizes() (map[string]uint64, error)
 }
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/gasper/pkg/vss"
+	"github.com/pkg/errors"
+	"math/big"
+	"strconv"
+)
+
+// Commitments published along with a share set split using verifiable secret sharing, so that custodians can check
+// their shares against them.
+type Commitments struct {
+	FileID      string   `json:"file-id"`
+	Generation  uint64   `json:"generation"`
+	Commitments []string `json:"commitments"`
+}
+
+// Returns the commitments published along with a shared file, or nil if it wasn't split using verifiable secret
+// sharing.
+func PublishedCommitments(sharedFile *sharesPkg.SharedFile) *Commitments {
+	if sharedFile.Manifest == nil || sharedFile.Manifest.SchemeOrDefault() != sharesPkg.SchemeVSS {
+		return nil
+	}
+
+	return &Commitments{
+		FileID:      sharedFile.ID,
+		Generation:  sharedFile.Manifest.Generation,
+		Commitments: sharedFile.Manifest.Commitments,
+	}
+}
+
+// Checks a single share against the commitments, without reconstructing anything.
+// If published commitments are nil, the ones committed in the share's manifest are used.
+func VerifyShare(share *sharesPkg.Share, published *Commitments) error {
+	manifest := share.Manifest
+	if manifest == nil || manifest.SchemeOrDefault() != sharesPkg.SchemeVSS {
+		return ErrNotVerifiable
+	}
+
+	encodedCommitments := manifest.Commitments
+	if published != nil {
+		if published.FileID != share.FileID || published.Generation != manifest.Generation ||
+			!equalStrings(published.Commitments, manifest.Commitments) {
+			return ErrCommitmentsMismatch
+		}
+		encodedCommitments = published.Commitments
+	}
+
+	commitments, err := vss.DecodeCommitments(encodedCommitments)
+	if err != nil {
+		return errors.WithMessage(err, "decode commitments")
+	}
+
+	if !manifest.CheckShare(share) {
+		return errors.New("share's data doesn't match its integrity tag")
+	}
+
+	keyShare, ciphertext, err := parseVerifiableShare(share)
+	if err != nil {
+		return err
+	}
+
+	if !vss.Verify(keyShare, commitments) {
+		return ErrBadKeyShare
+	} else if sharesPkg.ShareDigest(ciphertext) != manifest.CiphertextDigest {
+		return ErrBadCiphertext
+	}
+	return nil
+}
+
+// Splits payload using verifiable secret sharing: payload is encrypted with a random key, which is split using
+// Feldman's scheme. Each share holds an encoded key share followed by the ciphertext. Commitments and ciphertext
+// digest are set in manifest.
+func splitVerifiable(payload []byte, manifest *sharesPkg.Manifest) (map[byte][]byte, error) {
+	key, ciphertext, err := encryptWithRandomKey(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	keyShares, commitments, err := vss.Split(key, int(manifest.ShareCount), int(manifest.MinSharesThreshold))
+	if err != nil {
+		return nil, errors.WithMessage(err, "split key")
+	}
+
+	manifest.Commitments = vss.EncodeCommitments(commitments)
+	manifest.CiphertextDigest = sharesPkg.ShareDigest(ciphertext)
+
+	sharesBytes := make(map[byte][]byte, len(keyShares))
+	for _, keyShare := range keyShares {
+		sharesBytes[byte(keyShare.X)] = verifiableShareData(keyShare.Y, ciphertext)
+	}
+	return sharesBytes, nil
+}
+
+// Recovers payload out of shares split using verifiable secret sharing.
+// Shares inconsistent with the commitments, or holding a bad ciphertext, are moved from recovery's used shares to its
+// bad shares.
+func recoverVerifiable(manifest *sharesPkg.Manifest, recovery *Recovery) ([]byte, error) {
+	commitments, err := vss.DecodeCommitments(manifest.Commitments)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode commitments")
+	}
+
+	var (
+		keyShares  []*vss.Share
+		ciphertext []byte
+		used       []*sharesPkg.Share
+	)
+
+	for _, share := range recovery.Used {
+		keyShare, shareCiphertext, err := parseVerifiableShare(share)
+		if err != nil || !vss.Verify(keyShare, commitments) ||
+			sharesPkg.ShareDigest(shareCiphertext) != manifest.CiphertextDigest {
+			recovery.Bad = append(recovery.Bad, share)
+			continue
+		}
+
+		keyShares = append(keyShares, keyShare)
+		ciphertext = shareCiphertext
+		used = append(used, share)
+	}
+
+	recovery.Used = used
+	if len(keyShares) < int(manifest.MinSharesThreshold) {
+		return nil, ErrNotEnoughShares
+	}
+
+	key, err := vss.Combine(keyShares[:manifest.MinSharesThreshold], randomKeySize)
+	if err != nil {
+		return nil, errors.WithMessage(err, "combine key")
+	}
+	return decryptWithKey(key, ciphertext)
+}
+
+// Regenerates the share data at x, out of (at least threshold) good shares split using verifiable secret sharing.
+func interpolateVerifiable(shares []*sharesPkg.Share, x byte) ([]byte, error) {
+	keyShares := make([]*vss.Share, 0, len(shares))
+	var ciphertext []byte
+	for _, share := range shares {
+		keyShare, shareCiphertext, err := parseVerifiableShare(share)
+		if err != nil {
+			return nil, err
+		}
+
+		keyShares = append(keyShares, keyShare)
+		ciphertext = shareCiphertext
+	}
+
+	y := vss.Interpolate(keyShares, int(x))
+	if y == nil {
+		return nil, vss.ErrDuplicateShareIDs
+	}
+	return verifiableShareData(y, ciphertext), nil
+}
+
+func verifiableShareData(y *big.Int, ciphertext []byte) []byte {
+	data := make([]byte, 0, vss.ValueSize+len(ciphertext))
+	data = append(data, vss.EncodeValue(y)...)
+	return append(data, ciphertext...)
+}
+
+func parseVerifiableShare(share *sharesPkg.Share) (*vss.Share, []byte, error) {
+	x, err := strconv.Atoi(share.ID)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "convert share ID from string to int")
+	}
+
+	if len(share.Data) < vss.ValueSize {
+		return nil, nil, errors.New("share is too short")
+	}
+
+	y, err := vss.DecodeValue(share.Data[:vss.ValueSize])
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "decode key share")
+	}
+
+	return &vss.Share{X: x, Y: y}, share.Data[vss.ValueSize:], nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
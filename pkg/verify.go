@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"strconv"
+)
+
+// FileHealth describes how recoverable a shared file is.
+type FileHealth struct {
+	FileID     string
+	Generation uint64
+
+	ShareCount         byte
+	MinSharesThreshold byte
+
+	// Healthy shares of the current generation.
+	Healthy []*Placement
+
+	// Shares which are corrupt, or belong to a stale generation.
+	Corrupt []*Placement
+	Stale   []*Placement
+
+	// Share IDs of the current generation which weren't found in any store.
+	Missing []string
+
+	// Why the file couldn't be recovered, or nil if it could.
+	Err error
+}
+
+// Whether file was successfully recovered (in memory).
+func (fh *FileHealth) Recoverable() bool {
+	return fh.Err == nil
+}
+
+// How many healthy shares there are above the minimum shares threshold.
+// A margin of 0 means losing any more share makes the file unrecoverable.
+// Note: under an access policy, the threshold is the minimum number of parties which can satisfy it, so losing some
+// shares may make the file unrecoverable even with a positive margin.
+func (fh *FileHealth) Margin() int {
+	return len(fh.Healthy) - int(fh.MinSharesThreshold)
+}
+
+// Verifies a shared file is recoverable, without writing anything.
+// Collected shares are combined and decrypted in memory, and checked against the checksum (if set). Corrupt shares
+// are told apart (see Recover). Share count and minimum shares threshold are taken from the shares' manifest, falling
+// back to the given ones for shares stored without a manifest.
+func (g *Gasper) Verify(fileID, checksum string, placements []*Placement, shareCount,
+	minSharesThreshold byte) *FileHealth {
+	current, stale := SelectGeneration(placements)
+
+	health := &FileHealth{
+		FileID:             fileID,
+		ShareCount:         shareCount,
+		MinSharesThreshold: minSharesThreshold,
+		Stale:              stale,
+	}
+
+	recovery, err := g.Recover(SharedFileFromPlacements(fileID, checksum, current))
+	health.Err = err
+	if recovery != nil {
+		health.Healthy = placementsOf(current, recovery.Used)
+		health.Corrupt = placementsOf(current, recovery.Bad)
+	}
+
+	if manifest := PlacementsManifest(current); manifest != nil {
+		health.Generation = manifest.Generation
+		health.ShareCount = manifest.ShareCount
+		health.MinSharesThreshold = manifest.MinSharesThreshold
+	}
+
+	found := make(map[string]bool, len(health.Healthy))
+	for _, placement := range health.Healthy {
+		found[placement.Share.ID] = true
+	}
+	for x := 1; x <= int(health.ShareCount); x++ {
+		if shareID := strconv.Itoa(x); !found[shareID] {
+			health.Missing = append(health.Missing, shareID)
+		}
+	}
+
+	return health
+}
@@ -0,0 +1,337 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	sharesPkg "github.com/gasper/pkg/shares"
+	storesPkg "github.com/gasper/pkg/storage/stores"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	historyIDPrefix = "history-"
+
+	// Purpose label of the subkey history IDs are derived with.
+	historyIDPurpose = "gasper-history-id"
+
+	// Length of history IDs' hex-encoded digest part.
+	historyIDDigestSize = 40
+)
+
+// Version is a stored version of a named object.
+type Version struct {
+	// 1-based, in order of storage. Numbers of pruned versions are never reused.
+	Number int `json:"number"`
+
+	FileID   string    `json:"file-id"`
+	Checksum string    `json:"checksum"`
+	Time     time.Time `json:"time"`
+}
+
+// History lists a named object's versions, oldest first.
+// It is stored as a regular shared file, under an ID derived from the object's name (see HistoryID), and re-split
+// under the next share generation whenever it changes. Rekeying a version rewrites it (see Rekey).
+type History struct {
+	Name     string     `json:"name"`
+	Versions []*Version `json:"versions"`
+}
+
+// RetentionPolicy tells which versions of a named object to keep: the last ones, and the latest one of each of the
+// last days, weeks and months having versions. A version is kept if any rule keeps it.
+type RetentionPolicy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// PruneReport describes a finished prune.
+type PruneReport struct {
+	Kept   []*Version
+	Pruned []*Version
+}
+
+// Derives the ID a named object's history is stored under. Like chunk IDs, it is keyed by a subkey of the encryption
+// salt, so that it doesn't reveal the name to those who don't hold the salt. Without encryption, it is keyed by a
+// public secret instead: anyone can then tell whether a given name has a history.
+func (g *Gasper) HistoryID(name string) string {
+	mac := hmac.New(sha256.New, g.historySecret)
+	_, _ = mac.Write([]byte(name))
+	return historyIDPrefix + hex.EncodeToString(mac.Sum(nil))[:historyIDDigestSize]
+}
+
+// Loads a named object's history from the given stores, alongside its placements.
+// Returns an empty history if the object has no version yet.
+func (g *Gasper) LoadHistory(name string, stores []storesPkg.Store) (*History, []*Placement, error) {
+	historyID := g.HistoryID(name)
+	placements, storeErrors := g.CollectShares(historyID, stores)
+	if len(placements) == 0 {
+		if len(storeErrors) > 0 {
+			return nil, nil, errors.WithMessagef(storeErrors[0], "collect history of '%s'", name)
+		}
+		return &History{Name: name}, nil, nil
+	}
+
+	current, _ := SelectGeneration(placements)
+	recovery, err := g.Recover(SharedFileFromPlacements(historyID, "", current))
+	if err != nil {
+		return nil, placements, errors.WithMessagef(err, "recover history of '%s'", name)
+	}
+
+	history := &History{}
+	if err := json.Unmarshal(recovery.Data, history); err != nil {
+		return nil, placements, errors.WithMessagef(err, "unmarshal history of '%s'", name)
+	} else if history.Name != name {
+		return nil, placements, errors.Errorf("history of '%s' holds '%s'", name, history.Name)
+	}
+	return history, placements, nil
+}
+
+// Saves a named object's history in the given stores, replacing its previous placements (see LoadHistory).
+// History is split according to the manifest template, under the next share generation, and verified readable before
+// the previous shares are deleted.
+// Returns the new placements, alongside non-fatal store failures.
+func (g *Gasper) SaveHistory(history *History, placements []*Placement, template *sharesPkg.Manifest,
+	stores []storesPkg.Store) ([]*Placement, []*StoreError, error) {
+	historyData, err := json.Marshal(history)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "marshal history")
+	}
+
+	historyTemplate := *template
+	historyTemplate.Content = sharesPkg.ContentHistory
+	historyTemplate.Generation = nextGeneration(placements)
+
+	historyID := g.HistoryID(history.Name)
+	sharedFile, err := g.Split(historyID, historyData, nil, &historyTemplate)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "split history")
+	}
+
+	return g.replaceShares(g, historyID, placements, sharedFile, sharedFile.Manifest.MinSharesThreshold, false,
+		stores)
+}
+
+// historyRef is a history found referencing a file, along with where it is stored.
+type historyRef struct {
+	id         string
+	history    *History
+	placements []*Placement
+}
+
+// Finds the histories holding a version of the given file, among the ones readable with Gasper's encryption settings
+// (or with the other given Gasper's, if any). Every store must be listed, so that none is missed.
+func (g *Gasper) findHistories(fileID string, stores []storesPkg.Store, other *Gasper) ([]*historyRef, error) {
+	fileIDs, storeErrors := g.ListFileIDs(stores)
+	if len(storeErrors) > 0 {
+		return nil, errors.WithMessagef(ErrHistoriesUnlisted, "store '%s' (%s): %s", storeErrors[0].Store.Name(),
+			storeErrors[0].Store.Type(), storeErrors[0].Err)
+	}
+
+	var refs []*historyRef
+	for _, id := range fileIDs {
+		if !strings.HasPrefix(id, historyIDPrefix) {
+			continue
+		}
+
+		placements, _ := g.CollectShares(id, stores)
+		current, _ := SelectGeneration(placements)
+		for _, reader := range []*Gasper{g, other} {
+			if reader == nil {
+				continue
+			}
+
+			// Histories of other salts can't be read, and aren't the file's.
+			recovery, err := reader.Recover(SharedFileFromPlacements(id, "", current))
+			if err != nil {
+				continue
+			}
+
+			history := &History{}
+			if err := json.Unmarshal(recovery.Data, history); err != nil || reader.HistoryID(history.Name) != id {
+				continue
+			}
+
+			for _, version := range history.Versions {
+				if version.FileID == fileID {
+					refs = append(refs, &historyRef{id: id, history: history, placements: placements})
+					break
+				}
+			}
+			break
+		}
+	}
+	return refs, nil
+}
+
+// Rewrites histories referencing a rekeyed file: their versions of it are pointed at its new file ID, and they are
+// saved using the rekeyed Gasper's encryption settings, under the history ID these derive - merged with the history
+// already stored there, if any - before their previous shares are deleted.
+func (g *Gasper) rewriteHistories(refs []*historyRef, rekeyed *Gasper, oldFileID, newFileID string,
+	stores []storesPkg.Store) ([]*StoreError, error) {
+	storeErrors := make([]*StoreError, 0)
+	for _, ref := range refs {
+		for _, version := range ref.history.Versions {
+			if version.FileID == oldFileID {
+				version.FileID = newFileID
+			}
+		}
+
+		newID, placements := rekeyed.HistoryID(ref.history.Name), ref.placements
+		if newID != ref.id {
+			existing, existingPlacements, err := rekeyed.LoadHistory(ref.history.Name, stores)
+			if err != nil {
+				return storeErrors, errors.WithMessagef(err, "load history of '%s' under new key", ref.history.Name)
+			}
+			ref.history.merge(existing)
+			placements = existingPlacements
+		}
+
+		current, _ := SelectGeneration(ref.placements)
+		manifest := PlacementsManifest(current)
+		if manifest == nil {
+			return storeErrors, errors.Errorf("history of '%s' has no manifest", ref.history.Name)
+		}
+
+		template := resplitTemplate(ref.placements, manifest.ShareCount, manifest.MinSharesThreshold)
+		_, saveErrors, err := rekeyed.SaveHistory(ref.history, placements, template, stores)
+		storeErrors = append(storeErrors, saveErrors...)
+		if err != nil {
+			return storeErrors, errors.WithMessagef(err, "save history of '%s'", ref.history.Name)
+		}
+
+		if newID != ref.id {
+			_, deleteErrors := g.DeleteShares(ref.id, stores)
+			storeErrors = append(storeErrors, deleteErrors...)
+		}
+	}
+	return storeErrors, nil
+}
+
+// Merges another history of the same object into history: versions it lacks are added, in order of number.
+func (h *History) merge(other *History) {
+	numbers := make(map[int]bool, len(h.Versions))
+	for _, version := range h.Versions {
+		numbers[version.Number] = true
+	}
+
+	for _, version := range other.Versions {
+		if !numbers[version.Number] {
+			h.Versions = append(h.Versions, version)
+		}
+	}
+	sort.SliceStable(h.Versions, func(i, j int) bool { return h.Versions[i].Number < h.Versions[j].Number })
+}
+
+// Appends a new version to history, stored at the given time.
+func (h *History) Add(fileID, checksum string, storedAt time.Time) *Version {
+	number := 1
+	if len(h.Versions) > 0 {
+		number = h.Versions[len(h.Versions)-1].Number + 1
+	}
+
+	version := &Version{Number: number, FileID: fileID, Checksum: checksum, Time: storedAt.UTC()}
+	h.Versions = append(h.Versions, version)
+	return version
+}
+
+// Finds a version by number if set, otherwise the latest version stored at or before the given time if set, otherwise
+// the latest version.
+func (h *History) Find(number int, at time.Time) (*Version, error) {
+	for i := len(h.Versions) - 1; i >= 0; i-- {
+		version := h.Versions[i]
+		if number > 0 && version.Number == number || number <= 0 && (at.IsZero() || !version.Time.After(at)) {
+			return version, nil
+		}
+	}
+	return nil, errors.WithMessagef(ErrVersionNotFound, "object '%s'", h.Name)
+}
+
+// Whether retention policy keeps every version.
+func (r *RetentionPolicy) KeepsAll() bool {
+	return r.KeepLast <= 0 && r.KeepDaily <= 0 && r.KeepWeekly <= 0 && r.KeepMonthly <= 0
+}
+
+// Splits versions (oldest first) into the ones retention policy keeps and the ones it prunes.
+// Days, weeks and months are taken in UTC.
+func (r *RetentionPolicy) Apply(versions []*Version) (kept, pruned []*Version) {
+	if r.KeepsAll() {
+		return versions, nil
+	}
+
+	keep := make(map[*Version]bool, len(versions))
+	for i := len(versions) - 1; i >= 0 && i >= len(versions)-r.KeepLast; i-- {
+		keep[versions[i]] = true
+	}
+
+	keepLatestPerPeriod(versions, r.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }, keep)
+	keepLatestPerPeriod(versions, r.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}, keep)
+	keepLatestPerPeriod(versions, r.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }, keep)
+
+	for _, version := range versions {
+		if keep[version] {
+			kept = append(kept, version)
+		} else {
+			pruned = append(pruned, version)
+		}
+	}
+	return kept, pruned
+}
+
+// Keeps the latest version of each of the last count periods having versions, walking versions from the newest.
+func keepLatestPerPeriod(versions []*Version, count int, period func(t time.Time) string, keep map[*Version]bool) {
+	seen := make(map[string]bool, count)
+	for i := len(versions) - 1; i >= 0 && len(seen) < count; i-- {
+		if key := period(versions[i].Time.UTC()); !seen[key] {
+			seen[key] = true
+			keep[versions[i]] = true
+		}
+	}
+}
+
+// Prunes a named object's versions according to retention policy: pruned versions' files are deleted from the given
+// stores, and the history is saved without them - or only reported on a dry run.
+// The history is saved first, so an interrupted prune leaves unreferenced files behind rather than dangling versions.
+func (g *Gasper) Prune(name string, retention *RetentionPolicy, stores []storesPkg.Store,
+	dryRun bool) (*PruneReport, []*StoreError, error) {
+	history, placements, err := g.LoadHistory(name, stores)
+	if err != nil {
+		return nil, nil, err
+	} else if len(placements) == 0 {
+		return nil, nil, errors.WithMessagef(ErrVersionNotFound, "object '%s'", name)
+	}
+
+	report := &PruneReport{}
+	report.Kept, report.Pruned = retention.Apply(history.Versions)
+	if dryRun || len(report.Pruned) == 0 {
+		return report, nil, nil
+	}
+
+	current, _ := SelectGeneration(placements)
+	manifest := PlacementsManifest(current)
+	if manifest == nil {
+		return nil, nil, errors.Errorf("history of '%s' has no manifest", name)
+	}
+
+	history.Versions = report.Kept
+	template := resplitTemplate(placements, manifest.ShareCount, manifest.MinSharesThreshold)
+	_, storeErrors, err := g.SaveHistory(history, placements, template, stores)
+	if err != nil {
+		return nil, storeErrors, errors.WithMessage(err, "save history")
+	}
+
+	for _, version := range report.Pruned {
+		_, deleteErrors := g.DeleteShares(version.FileID, stores)
+		storeErrors = append(storeErrors, deleteErrors...)
+	}
+	return report, storeErrors, nil
+}
@@ -0,0 +1,219 @@
+package pkg
+
+import (
+	"bytes"
+	sharesPkg "github.com/gasper/pkg/shares"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testVersionsStart = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// History of versions stored at the given offsets from testVersionsStart, of files file-a, file-b, and so on.
+func testHistory(name string, offsets ...time.Duration) *History {
+	history := &History{Name: name}
+	for i, offset := range offsets {
+		history.Add("file-"+string(rune('a'+i)), "checksum", testVersionsStart.Add(offset))
+	}
+	return history
+}
+
+func days(count int) time.Duration {
+	return time.Duration(count) * 24 * time.Hour
+}
+
+// History IDs are keyed by their own subkey of the salt, independent of chunk keys' one.
+func TestHistoryID(t *testing.T) {
+	g := newTestGasper(t, nil, true)
+
+	id := g.HistoryID("docs/report.txt")
+	if !strings.HasPrefix(id, historyIDPrefix) {
+		t.Errorf("history ID '%s' lacks prefix '%s'", id, historyIDPrefix)
+	} else if id != newTestGasper(t, nil, true).HistoryID("docs/report.txt") {
+		t.Error("same name and salt, different history IDs")
+	} else if id == g.HistoryID("docs/other.txt") {
+		t.Error("different names, same history ID")
+	} else if id == newTestGasper(t, nil, false).HistoryID("docs/report.txt") {
+		t.Error("same history ID with and without encryption")
+	}
+
+	if bytes.Equal(g.historySecret, g.chunkSecret) || bytes.Equal(g.historySecret, []byte(testSalt)) {
+		t.Error("history secret is shared with chunk keys or encryption")
+	}
+}
+
+func TestSaveLoadHistory(t *testing.T) {
+	stores, cleanup := newTestStores(t, 3)
+	defer cleanup()
+	g := newTestGasper(t, stores, true)
+
+	history, placements, err := g.LoadHistory("report", stores)
+	if err != nil {
+		t.Fatalf("load missing history: %v", err)
+	} else if len(history.Versions) != 0 || len(placements) != 0 {
+		t.Fatalf("missing history has %d versions", len(history.Versions))
+	}
+
+	template := &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}
+	for i := 0; i < 3; i++ {
+		history.Add("file-"+string(rune('a'+i)), "checksum", testVersionsStart.Add(days(i)))
+		if _, _, err := g.SaveHistory(history, placements, template, stores); err != nil {
+			t.Fatalf("save history: %v", err)
+		}
+
+		loaded, loadedPlacements, err := g.LoadHistory("report", stores)
+		if err != nil {
+			t.Fatalf("load history: %v", err)
+		} else if !reflect.DeepEqual(loaded, history) {
+			t.Fatalf("loaded history %+v, want %+v", loaded, history)
+		}
+		placements = loadedPlacements
+	}
+
+	// Each save replaces the history's shares with the next generation's.
+	current, stale := SelectGeneration(placements)
+	if len(current) != 3 || len(stale) != 0 || current[0].Share.Generation() != 3 {
+		t.Errorf("%d current shares of generation %d and %d stale, want 3 of generation 3", len(current),
+			current[0].Share.Generation(), len(stale))
+	}
+	if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, []string{g.HistoryID("report")}) {
+		t.Errorf("stored file IDs: %v, want the history's only", fileIDs)
+	}
+
+	// Other encryption settings look for it under another ID, and find none.
+	if other, _, err := newTestGasper(t, stores, false).LoadHistory("report", stores); err != nil {
+		t.Errorf("load history under other settings: %v", err)
+	} else if len(other.Versions) != 0 {
+		t.Errorf("history read under other settings")
+	}
+}
+
+func TestHistoryFind(t *testing.T) {
+	history := testHistory("report", 0, days(1), days(2))
+
+	tests := []struct {
+		name   string
+		number int
+		at     time.Time
+		want   string
+	}{
+		{name: "latest", want: "file-c"},
+		{name: "by number", number: 2, want: "file-b"},
+		{name: "number over date", number: 1, at: testVersionsStart.Add(days(2)), want: "file-a"},
+		{name: "unknown number", number: 4},
+		{name: "between versions", at: testVersionsStart.Add(days(1) + time.Hour), want: "file-b"},
+		{name: "exactly at a version", at: testVersionsStart.Add(days(2)), want: "file-c"},
+		{name: "after every version", at: testVersionsStart.Add(days(30)), want: "file-c"},
+		{name: "before every version", at: testVersionsStart.Add(-time.Second)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := history.Find(test.number, test.at)
+			if test.want == "" {
+				if errors.Cause(err) != ErrVersionNotFound {
+					t.Errorf("find: %v, want %v", err, ErrVersionNotFound)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("find: %v", err)
+			} else if version.FileID != test.want {
+				t.Errorf("found '%s', want '%s'", version.FileID, test.want)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyApply(t *testing.T) {
+	// Versions a: Monday, b and c: Tuesday, d: next Monday, e: a month later.
+	history := testHistory("report", 0, days(1), days(1)+time.Hour, days(7), days(31))
+
+	tests := []struct {
+		name      string
+		retention *RetentionPolicy
+		kept      []string
+	}{
+		{name: "keep all", retention: &RetentionPolicy{}, kept: []string{"file-a", "file-b", "file-c", "file-d",
+			"file-e"}},
+		{name: "last 2", retention: &RetentionPolicy{KeepLast: 2}, kept: []string{"file-d", "file-e"}},
+		{name: "daily 3", retention: &RetentionPolicy{KeepDaily: 3}, kept: []string{"file-c", "file-d", "file-e"}},
+		{name: "weekly 2", retention: &RetentionPolicy{KeepWeekly: 2}, kept: []string{"file-d", "file-e"}},
+		{name: "monthly 2", retention: &RetentionPolicy{KeepMonthly: 2}, kept: []string{"file-d", "file-e"}},
+		{name: "rules add up", retention: &RetentionPolicy{KeepLast: 1, KeepWeekly: 3},
+			kept: []string{"file-c", "file-d", "file-e"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kept, pruned := test.retention.Apply(history.Versions)
+			if len(kept)+len(pruned) != len(history.Versions) {
+				t.Fatalf("%d kept and %d pruned, out of %d", len(kept), len(pruned), len(history.Versions))
+			}
+
+			keptIDs := make([]string, 0, len(kept))
+			for _, version := range kept {
+				keptIDs = append(keptIDs, version.FileID)
+			}
+			if !reflect.DeepEqual(keptIDs, test.kept) {
+				t.Errorf("kept %v, want %v", keptIDs, test.kept)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	stores, cleanup := newTestStores(t, 3)
+	defer cleanup()
+	g := newTestGasper(t, stores, true)
+
+	history := testHistory("report", 0, days(1), days(2), days(3))
+	for i, version := range history.Versions {
+		storeTestData(t, g, version.FileID, testData(int64(20+i), 100), stores, 3, 2)
+	}
+	template := &sharesPkg.Manifest{ShareCount: 3, MinSharesThreshold: 2}
+	if _, _, err := g.SaveHistory(history, nil, template, stores); err != nil {
+		t.Fatalf("save history: %v", err)
+	}
+
+	retention := &RetentionPolicy{KeepLast: 2}
+	report, _, err := g.Prune("report", retention, stores, true)
+	if err != nil {
+		t.Fatalf("dry run prune: %v", err)
+	} else if len(report.Kept) != 2 || len(report.Pruned) != 2 {
+		t.Fatalf("dry run kept %d and pruned %d versions, want 2 of each", len(report.Kept), len(report.Pruned))
+	} else if fileIDs := listTestFileIDs(t, g, stores); len(fileIDs) != 5 {
+		t.Fatalf("dry run left %d file IDs, want 5", len(fileIDs))
+	}
+
+	if _, storeErrors, err := g.Prune("report", retention, stores, false); err != nil {
+		t.Fatalf("prune: %v", err)
+	} else if len(storeErrors) > 0 {
+		t.Fatalf("prune: %v", storeErrors[0])
+	}
+
+	want := []string{"file-c", "file-d", g.HistoryID("report")}
+	if fileIDs := listTestFileIDs(t, g, stores); !reflect.DeepEqual(fileIDs, want) {
+		t.Errorf("stored file IDs: %v, want %v", fileIDs, want)
+	}
+
+	pruned, _, err := g.LoadHistory("report", stores)
+	if err != nil {
+		t.Fatalf("load history: %v", err)
+	} else if len(pruned.Versions) != 2 || pruned.Versions[0].Number != 3 {
+		t.Errorf("pruned history: %+v, want versions 3 and 4", pruned.Versions)
+	}
+
+	// Numbers of pruned versions aren't reused.
+	if version := pruned.Add("file-e", "checksum", testVersionsStart.Add(days(4))); version.Number != 5 {
+		t.Errorf("new version numbered %d, want 5", version.Number)
+	}
+
+	if _, _, err := g.Prune("unknown", retention, stores, false); errors.Cause(err) != ErrVersionNotFound {
+		t.Errorf("prune unknown object: %v, want %v", err, ErrVersionNotFound)
+	}
+}
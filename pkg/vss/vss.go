@@ -0,0 +1,201 @@
+// Package vss implements Feldman's verifiable secret sharing.
+//
+// A secret is split using Shamir's scheme over Z_q, and the dealer publishes commitments to the polynomial's
+// coefficients (g^a_j mod p). Anyone holding a share can then check it is consistent with the commitments, without
+// reconstructing anything - so a dealer cannot hand out inconsistent shares unnoticed.
+//
+// Computations take place in the prime-order subgroup of the 2048-bit MODP group of RFC 3526 (p = 2q + 1).
+package vss
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// Size of encoded share values and commitments, in bytes.
+const ValueSize = 256
+
+var (
+	// RFC 3526, 2048-bit MODP group.
+	p, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D"+
+		"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F"+
+		"83655D23DCA3AD961C62F356208552BB9ED529077096966D"+
+		"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9"+
+		"DE2BCBF6955817183995497CEA956AE515D2261898FA0510"+
+		"15728E5A8AACAA68FFFFFFFFFFFFFFFF", 16)
+	q = new(big.Int).Rsh(p, 1)
+	g = big.NewInt(2) // Generates the order-q subgroup, as p = 7 (mod 8).
+
+	ErrInvalidThreshold  = errors.New("threshold must be > 1 and <= share count")
+	ErrSecretTooLarge    = errors.New("secret is too large for the group")
+	ErrInvalidShare      = errors.New("share is inconsistent with commitments")
+	ErrInvalidEncoding   = errors.New("invalid value encoding")
+	ErrDuplicateShareIDs = errors.New("duplicate share IDs")
+)
+
+// Share is a point on the secret polynomial.
+type Share struct {
+	X int
+	Y *big.Int
+}
+
+// Splits a secret into n shares, k of which are required to reconstruct it. Share IDs are 1..n.
+// Returns the shares, alongside commitments to the polynomial's coefficients.
+func Split(secret []byte, n, k int) ([]*Share, []*big.Int, error) {
+	if k <= 1 || k > n {
+		return nil, nil, ErrInvalidThreshold
+	}
+
+	s := new(big.Int).SetBytes(secret)
+	if s.Cmp(q) >= 0 {
+		return nil, nil, ErrSecretTooLarge
+	}
+
+	coefficients := make([]*big.Int, k)
+	coefficients[0] = s
+	for i := 1; i < k; i++ {
+		coefficient, err := rand.Int(rand.Reader, q)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "generate random coefficient")
+		}
+		coefficients[i] = coefficient
+	}
+
+	commitments := make([]*big.Int, k)
+	for i, coefficient := range coefficients {
+		commitments[i] = new(big.Int).Exp(g, coefficient, p)
+	}
+
+	shares := make([]*Share, n)
+	for x := 1; x <= n; x++ {
+		shares[x-1] = &Share{X: x, Y: evaluate(coefficients, x)}
+	}
+	return shares, commitments, nil
+}
+
+// Checks a share against the commitments: g^y == prod(C_j ^ (x^j)) (mod p).
+func Verify(share *Share, commitments []*big.Int) bool {
+	if share.X < 1 || share.Y.Sign() < 0 || share.Y.Cmp(q) >= 0 || len(commitments) == 0 {
+		return false
+	}
+
+	expected := new(big.Int).Exp(g, share.Y, p)
+
+	actual := big.NewInt(1)
+	x := big.NewInt(int64(share.X))
+	power := big.NewInt(1)
+	for _, commitment := range commitments {
+		term := new(big.Int).Exp(commitment, power, p)
+		actual.Mul(actual, term).Mod(actual, p)
+		power.Mul(power, x).Mod(power, q)
+	}
+
+	return expected.Cmp(actual) == 0
+}
+
+// Reconstructs the secret out of (at least k) shares, as a big-endian byte slice of the given size.
+func Combine(shares []*Share, secretSize int) ([]byte, error) {
+	secret := Interpolate(shares, 0)
+	if secret == nil {
+		return nil, ErrDuplicateShareIDs
+	}
+
+	secretBytes := secret.Bytes()
+	if len(secretBytes) > secretSize {
+		return nil, ErrSecretTooLarge
+	}
+
+	padded := make([]byte, secretSize)
+	copy(padded[secretSize-len(secretBytes):], secretBytes)
+	return padded, nil
+}
+
+// Evaluates at x the polynomial passing through the given shares.
+// Returns nil if shares have duplicate IDs.
+func Interpolate(shares []*Share, x int) *big.Int {
+	result := new(big.Int)
+	bigX := big.NewInt(int64(x))
+
+	for i, a := range shares {
+		numerator, denominator := big.NewInt(1), big.NewInt(1)
+		for j, b := range shares {
+			if i == j {
+				continue
+			} else if a.X == b.X {
+				return nil
+			}
+
+			bigB := big.NewInt(int64(b.X))
+			numerator.Mul(numerator, new(big.Int).Sub(bigX, bigB)).Mod(numerator, q)
+			denominator.Mul(denominator, big.NewInt(int64(a.X-b.X))).Mod(denominator, q)
+		}
+
+		weight := numerator.Mul(numerator, new(big.Int).ModInverse(denominator, q))
+		result.Add(result, weight.Mul(weight, a.Y)).Mod(result, q)
+	}
+	return result
+}
+
+// Encodes a share value or a commitment as a fixed-size big-endian byte slice.
+func EncodeValue(value *big.Int) []byte {
+	valueBytes := value.Bytes()
+	encoded := make([]byte, ValueSize)
+	copy(encoded[ValueSize-len(valueBytes):], valueBytes)
+	return encoded
+}
+
+// Decodes a share value or a commitment encoded by EncodeValue.
+func DecodeValue(encoded []byte) (*big.Int, error) {
+	if len(encoded) != ValueSize {
+		return nil, ErrInvalidEncoding
+	}
+	return new(big.Int).SetBytes(encoded), nil
+}
+
+// Encodes commitments as hex strings, as published along with the share set.
+func EncodeCommitments(commitments []*big.Int) []string {
+	encoded := make([]string, len(commitments))
+	for i, commitment := range commitments {
+		encoded[i] = hex.EncodeToString(EncodeValue(commitment))
+	}
+	return encoded
+}
+
+// Decodes commitments encoded by EncodeCommitments.
+func DecodeCommitments(encoded []string) ([]*big.Int, error) {
+	commitments := make([]*big.Int, len(encoded))
+	for i, encodedCommitment := range encoded {
+		commitmentBytes, err := hex.DecodeString(encodedCommitment)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode commitment %d", i)
+		}
+
+		commitment, err := DecodeValue(commitmentBytes)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode commitment %d", i)
+		}
+
+		if commitment.Sign() <= 0 || commitment.Cmp(p) >= 0 {
+			return nil, ErrInvalidEncoding
+		}
+		commitments[i] = commitment
+	}
+	return commitments, nil
+}
+
+func evaluate(coefficients []*big.Int, x int) *big.Int {
+	// Horner's scheme.
+	result := new(big.Int)
+	bigX := big.NewInt(int64(x))
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result.Mul(result, bigX).Add(result, coefficients[i]).Mod(result, q)
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package vss
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSplitCombine(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, k    int
+		subsets [][]int
+	}{
+		{name: "2 of 2", n: 2, k: 2, subsets: [][]int{{0, 1}}},
+		{name: "2 of 3", n: 3, k: 2, subsets: [][]int{{0, 1}, {0, 2}, {2, 1}}},
+		{name: "3 of 5", n: 5, k: 3, subsets: [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, commitments, err := Split(secret, test.n, test.k)
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			} else if len(shares) != test.n || len(commitments) != test.k {
+				t.Fatalf("split: %d shares and %d commitments, want %d and %d", len(shares), len(commitments),
+					test.n, test.k)
+			}
+
+			for _, share := range shares {
+				if !Verify(share, commitments) {
+					t.Errorf("share %d doesn't verify against its commitments", share.X)
+				}
+			}
+
+			for _, subset := range test.subsets {
+				subsetShares := make([]*Share, 0, len(subset))
+				for _, i := range subset {
+					subsetShares = append(subsetShares, shares[i])
+				}
+
+				combined, err := Combine(subsetShares, len(secret))
+				if err != nil {
+					t.Fatalf("combine %v: %v", subset, err)
+				} else if !bytes.Equal(combined, secret) {
+					t.Errorf("combine %v: %q, want %q", subset, combined, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	if _, _, err := Split(secret, 3, 1); err != ErrInvalidThreshold {
+		t.Errorf("threshold 1: %v, want %v", err, ErrInvalidThreshold)
+	} else if _, _, err := Split(secret, 2, 3); err != ErrInvalidThreshold {
+		t.Errorf("threshold above share count: %v, want %v", err, ErrInvalidThreshold)
+	} else if _, _, err := Split(bytes.Repeat([]byte{0xff}, ValueSize), 3, 2); err != ErrSecretTooLarge {
+		t.Errorf("secret too large: %v, want %v", err, ErrSecretTooLarge)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	shares, commitments, err := Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	tamperedShare := &Share{X: shares[0].X, Y: new(big.Int).Add(shares[0].Y, big.NewInt(1))}
+	if Verify(tamperedShare, commitments) {
+		t.Error("share with a tampered value verifies")
+	}
+
+	movedShare := &Share{X: shares[1].X, Y: shares[0].Y}
+	if Verify(movedShare, commitments) {
+		t.Error("share with another share's value verifies")
+	}
+
+	tamperedCommitments := append([]*big.Int{}, commitments...)
+	tamperedCommitments[1] = new(big.Int).Mul(commitments[1], g)
+	tamperedCommitments[1].Mod(tamperedCommitments[1], p)
+	for _, share := range shares {
+		if Verify(share, tamperedCommitments) {
+			t.Errorf("share %d verifies against a tampered commitment", share.X)
+		}
+	}
+
+	_, otherCommitments, err := Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	} else if Verify(shares[0], otherCommitments) {
+		t.Error("share verifies against another split's commitments")
+	}
+}
+
+func TestCombineDuplicateShares(t *testing.T) {
+	shares, _, err := Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	if _, err := Combine([]*Share{shares[0], shares[0]}, len(secret)); err != ErrDuplicateShareIDs {
+		t.Errorf("duplicate shares: %v, want %v", err, ErrDuplicateShareIDs)
+	}
+}
+
+func TestCommitmentsEncoding(t *testing.T) {
+	shares, commitments, err := Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	decoded, err := DecodeCommitments(EncodeCommitments(commitments))
+	if err != nil {
+		t.Fatalf("decode commitments: %v", err)
+	}
+
+	for i := range commitments {
+		if decoded[i].Cmp(commitments[i]) != 0 {
+			t.Errorf("commitment %d: decoded %x, want %x", i, decoded[i], commitments[i])
+		}
+	}
+
+	for _, share := range shares {
+		value, err := DecodeValue(EncodeValue(share.Y))
+		if err != nil {
+			t.Fatalf("decode share %d value: %v", share.X, err)
+		} else if !Verify(&Share{X: share.X, Y: value}, decoded) {
+			t.Errorf("share %d doesn't verify once decoded", share.X)
+		}
+	}
+
+	if _, err := DecodeCommitments([]string{"00"}); err == nil {
+		t.Error("short commitment decoded")
+	}
+}